@@ -0,0 +1,156 @@
+package Common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultDiffReport diff命令未指定 -out 时写入的对比报告
+const DefaultDiffReport = "n1ght-diff.json"
+
+// 类、方法和文件的变化类型
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// ArtifactDiff 两个版本制品之间的类、方法和文件变化
+type ArtifactDiff struct {
+	Old       string        `json:"old"`
+	New       string        `json:"new"`
+	OldSHA256 string        `json:"oldSha256"`
+	NewSHA256 string        `json:"newSha256"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Summary   DiffSummary   `json:"summary"`
+	Classes   []ClassChange `json:"classes"`
+	Files     []FileChange  `json:"files,omitempty"`
+}
+
+// DiffSummary 变化数量
+type DiffSummary struct {
+	AddedClasses     int `json:"addedClasses"`
+	RemovedClasses   int `json:"removedClasses"`
+	ChangedClasses   int `json:"changedClasses"`
+	UnchangedClasses int `json:"unchangedClasses"`
+	AddedMethods     int `json:"addedMethods"`
+	RemovedMethods   int `json:"removedMethods"`
+	ChangedMethods   int `json:"changedMethods"`
+	ChangedFiles     int `json:"changedFiles"`
+}
+
+// ClassChange 一个类的变化
+type ClassChange struct {
+	Class     string         `json:"class"`     // class条目，如 com/foo/Bar$Inner.class
+	Container string         `json:"container"` // 所在的jar或目录，如 WEB-INF/lib/foo-1.2.jar，删除的类为旧版本中的位置
+	Status    string         `json:"status"`
+	Structure bool           `json:"structure,omitempty"` // 父类、接口、字段或类访问标志有变化
+	Methods   []MethodChange `json:"methods,omitempty"`
+}
+
+// MethodChange 一个方法的变化
+type MethodChange struct {
+	Method    string `json:"method"` // 方法名和描述符，如 login(Ljava/lang/String;)Z
+	Status    string `json:"status"`
+	FirstLine int    `json:"firstLine,omitempty"` // 新版本中方法的原始行号范围（来自LineNumberTable）
+	LastLine  int    `json:"lastLine,omitempty"`
+}
+
+// FileChange JSP、配置等非class文件的变化
+type FileChange struct {
+	Path      string `json:"path"` // 在所在jar或制品根目录中的路径，如 WEB-INF/jsp/login.jsp
+	Container string `json:"container,omitempty"`
+	Status    string `json:"status"`
+}
+
+// WriteArtifactDiff 将对比报告写入文件
+func WriteArtifactDiff(path string, diff *ArtifactDiff) error {
+	data, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadArtifactDiff 读取diff命令生成的对比报告
+func LoadArtifactDiff(path string) (*ArtifactDiff, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	diff := &ArtifactDiff{}
+	if err := json.Unmarshal(data, diff); err != nil {
+		return nil, fmt.Errorf("解析对比报告失败: %v", err)
+	}
+	return diff, nil
+}
+
+// Touches 判断源码来源中的类（或JSP等文件）在原始行号line处是否属于新增或变化的代码，line为0表示行号未知。
+// 类需要所在的jar或目录也一致，不同jar中的同名类互不影响
+func (d *ArtifactDiff) Touches(origin *SourceOrigin, line int) bool {
+	if strings.HasSuffix(origin.Class, ".class") {
+		top := strings.TrimSuffix(origin.Class, ".class")
+		for i := range d.Classes {
+			change := &d.Classes[i]
+			name := strings.TrimSuffix(change.Class, ".class")
+			if name != top && !strings.HasPrefix(name, top+"$") {
+				continue
+			}
+			if !sameContainer(change.Container, origin.Artifact) {
+				continue
+			}
+			if change.touches(line) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, file := range d.Files {
+		if file.Status != ChangeRemoved && file.Path == origin.Class {
+			return true
+		}
+	}
+	return false
+}
+
+// sameContainer 判断对比报告中类所在的位置与源码来源的Artifact是否为同一个jar或目录。
+// 对比报告中嵌套包写作 app.war!/WEB-INF/lib/foo.jar，源码来源中解压的模块写作 app.war/WEB-INF/lib/foo.jar，统一后比较；
+// 制品根目录下的类在对比报告中没有位置，源码来源中为 "."（class目录）或制品本身的文件名（jar）
+func sameContainer(container, artifact string) bool {
+	if artifact == "." {
+		artifact = ""
+	}
+	container = strings.ReplaceAll(container, "!/", "/")
+	artifact = strings.ReplaceAll(artifact, "!/", "/")
+	if container == "" && artifact != "" && !strings.Contains(artifact, "/") {
+		ext := strings.ToLower(path.Ext(artifact))
+		return ext == ".jar" || ext == ".war"
+	}
+	return container == artifact
+}
+
+// touches 新增的类整体算作变化；变化的类按方法行号范围判断，行号未知或变化的方法没有行号时整个类算作变化
+func (c *ClassChange) touches(line int) bool {
+	switch c.Status {
+	case ChangeAdded:
+		return true
+	case ChangeRemoved:
+		return false
+	}
+	if line == 0 {
+		return true
+	}
+	for _, m := range c.Methods {
+		if m.Status == ChangeRemoved {
+			continue
+		}
+		if m.FirstLine == 0 || (line >= m.FirstLine && line <= m.LastLine) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package Common
+
+import "testing"
+
+func testDiff() *ArtifactDiff {
+	return &ArtifactDiff{
+		Classes: []ClassChange{
+			{Class: "com/acme/Login.class", Container: "WEB-INF/lib/app-shade.jar", Status: ChangeChanged, Methods: []MethodChange{
+				{Method: "check(Ljava/lang/String;)Z", Status: ChangeChanged, FirstLine: 20, LastLine: 30},
+				{Method: "old()V", Status: ChangeRemoved},
+			}},
+			{Class: "com/acme/Login$Helper.class", Container: "app.war!/WEB-INF/classes", Status: ChangeAdded},
+			{Class: "com/acme/Gone.class", Container: "WEB-INF/classes", Status: ChangeRemoved},
+			{Class: "com/acme/Main.class", Container: "", Status: ChangeChanged, Methods: []MethodChange{
+				{Method: "main([Ljava/lang/String;)V", Status: ChangeAdded},
+			}},
+		},
+		Files: []FileChange{
+			{Path: "WEB-INF/jsp/login.jsp", Status: ChangeChanged},
+			{Path: "WEB-INF/jsp/old.jsp", Status: ChangeRemoved},
+		},
+	}
+}
+
+func TestArtifactDiffTouches(t *testing.T) {
+	diff := testDiff()
+	tests := []struct {
+		name     string
+		artifact string
+		class    string
+		line     int
+		want     bool
+	}{
+		{"line inside changed method", "WEB-INF/lib/app-shade.jar", "com/acme/Login.class", 25, true},
+		{"line outside changed method", "WEB-INF/lib/app-shade.jar", "com/acme/Login.class", 40, false},
+		{"unknown line", "WEB-INF/lib/app-shade.jar", "com/acme/Login.class", 0, true},
+		{"same class in another jar", "WEB-INF/lib/app-core.jar", "com/acme/Login.class", 25, false},
+		{"added inner class in ear module", "app.war/WEB-INF/classes", "com/acme/Login.class", 5, true},
+		{"inner class of another module", "other.war/WEB-INF/classes", "com/acme/Login.class", 5, false},
+		{"removed class", "WEB-INF/classes", "com/acme/Gone.class", 0, false},
+		{"root class of jar input", "app.jar", "com/acme/Main.class", 7, true},
+		{"root class of class directory", ".", "com/acme/Main.class", 7, true},
+		{"root class compared with nested jar", "WEB-INF/lib/app.jar", "com/acme/Main.class", 7, false},
+		{"changed jsp", ".", "WEB-INF/jsp/login.jsp", 3, true},
+		{"removed jsp", ".", "WEB-INF/jsp/old.jsp", 3, false},
+	}
+	for _, tt := range tests {
+		origin := &SourceOrigin{Artifact: tt.artifact, Class: tt.class}
+		if got := diff.Touches(origin, tt.line); got != tt.want {
+			t.Errorf("%s: Touches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package Common
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MetadataFileName 数据库目录下记录建库信息的文件
+const MetadataFileName = "n1ght-db.json"
+
+// BuildInfo 数据库的建库清单：输入制品、反编译器、依赖、工具版本、javac设置、编译覆盖率和生效的配置，
+// 与建库时的其他元数据一起写入n1ght-db.json，扫描时读取以说明结果对应的数据库
+type BuildInfo struct {
+	Artifact             *ArtifactInfo      `json:"artifact,omitempty"`
+	CreatedAt            time.Time          `json:"createdAt"`
+	DurationSeconds      float64            `json:"durationSeconds,omitempty"`
+	ToolVersions         map[string]string  `json:"toolVersions,omitempty"`
+	Decompiled           []DecompiledInput  `json:"decompiled,omitempty"`
+	DecompileFailures    []DecompileFailure `json:"decompileFailures,omitempty"`
+	SelectedDependencies []string           `json:"selectedDependencies,omitempty"`
+	Javac                *JavacSettings     `json:"javac,omitempty"`
+	Coverage             *CompileCoverage   `json:"coverage,omitempty"`
+	Config               *EffectiveConfig   `json:"config,omitempty"`
+	Incremental          *IncrementalStats  `json:"incremental,omitempty"`
+	Inputs               []InputDigest      `json:"inputs,omitempty"`
+}
+
+// ArtifactInfo 建库的输入制品
+type ArtifactInfo struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Directory bool   `json:"directory,omitempty"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"` // 目录输入为按相对路径排序后逐个文件的摘要
+}
+
+// DecompiledInput 一个jar或class目录实际使用的反编译器
+type DecompiledInput struct {
+	Input      string `json:"input"`
+	Decompiler string `json:"decompiler"`
+	Version    string `json:"version"`
+	Fallback   bool   `json:"fallback,omitempty"` // 首选反编译器失败后换用
+	Reused     bool   `json:"reused,omitempty"`   // 增量模式下全部源码复用自上一个数据库
+}
+
+// DecompileFailure 所有反编译器都失败或超时的jar、class目录
+type DecompileFailure struct {
+	Input    string `json:"input"`
+	Reason   string `json:"reason"`
+	TimedOut bool   `json:"timedOut,omitempty"` // 超过-decompile-timeout或被判定为卡死
+}
+
+// InputDigest 一个反编译输入（jar或class目录）及其中每个类的SHA-256，用于增量重建和版本对比
+type InputDigest struct {
+	Input   string            `json:"input"`             // 在制品中的路径，如 WEB-INF/lib/foo.jar
+	SHA256  string            `json:"sha256"`            // jar为文件摘要，class目录为所有类摘要的摘要
+	Classes map[string]string `json:"classes"`           // 顶层类（如 com/foo/Bar.class，摘要包含其内部类）-> SHA-256
+	Renamed bool              `json:"renamed,omitempty"` // 反编译前被去混淆或按mapping改名
+	Root    bool              `json:"root,omitempty"`    // 制品本身（普通jar或裸class目录），文件名通常随版本变化
+}
+
+// IncrementalStats 增量重建时复用和重新反编译的数量
+type IncrementalStats struct {
+	Previous       string `json:"previous"`       // 上一个数据库
+	ReusedInputs   int    `json:"reusedInputs"`   // 全部复用的输入
+	ChangedInputs  int    `json:"changedInputs"`  // 部分类变化的输入
+	NewInputs      int    `json:"newInputs"`      // 上一次没有的输入
+	ReusedClasses  int    `json:"reusedClasses"`  // 复用源码的类
+	ChangedClasses int    `json:"changedClasses"` // 重新反编译的类
+}
+
+// JavacSettings build.xml中javac任务的设置
+type JavacSettings struct {
+	Source    string   `json:"source"`
+	Target    string   `json:"target"`
+	Debug     bool     `json:"debug"`
+	Optimize  bool     `json:"optimize"`
+	Classpath []string `json:"classpath"`
+}
+
+// CompileCoverage 源码根目录中编译出class文件的源码比例
+type CompileCoverage struct {
+	SourceFiles   int     `json:"sourceFiles"`
+	CompiledFiles int     `json:"compiledFiles"`
+	Percent       float64 `json:"percent"`
+}
+
+// EffectiveConfig 本次运行生效的配置（命令行与配置文件合并、自动推导之后的值）
+type EffectiveConfig struct {
+	Flags        map[string]string `json:"flags"`
+	Dependencies DependencyRules   `json:"dependencies"`
+	Resources    ResourceLimits    `json:"resources"`
+}
+
+// CurrentConfig 返回本次运行生效的全部参数值
+func CurrentConfig() *EffectiveConfig {
+	config := &EffectiveConfig{
+		Flags:        make(map[string]string),
+		Dependencies: DepRules,
+		Resources:    EffectiveResources,
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		config.Flags[f.Name] = f.Value.String()
+	})
+	return config
+}
+
+// LoadBuildInfo 读取数据库目录中的建库清单；文件不存在时返回nil
+func LoadBuildInfo(dbPath string) (*BuildInfo, error) {
+	data, err := os.ReadFile(filepath.Join(dbPath, MetadataFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := &BuildInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("解析%s失败: %v", MetadataFileName, err)
+	}
+	return info, nil
+}
@@ -5,10 +5,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 )
 
+// javaVersionPattern java -version 输出中的版本号，如 "1.8.0_392"、"11.0.21"、"17"
+var javaVersionPattern = regexp.MustCompile(`version "(\d+)(?:\.(\d+))?`)
+
 // CommandExecutor 命令执行器结构体
 type CommandExecutor struct {
 	ToolsPath string // tools目录路径
@@ -126,6 +131,23 @@ func (ce *CommandExecutor) GetJavaVersion() (string, error) {
 	return output, nil
 }
 
+// JavaMajorVersion 返回PATH中java的主版本号（Java 8及以前的1.x记为x），反编译器都通过它运行
+func JavaMajorVersion() (int, error) {
+	output, err := exec.Command("java", "-version").CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("执行java -version失败: %v", err)
+	}
+	match := javaVersionPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return 0, fmt.Errorf("无法识别Java版本: %s", strings.TrimSpace(string(output)))
+	}
+	major, _ := strconv.Atoi(match[1])
+	if major == 1 && match[2] != "" {
+		major, _ = strconv.Atoi(match[2])
+	}
+	return major, nil
+}
+
 // GetCodeQLVersion 获取CodeQL版本信息
 func (ce *CommandExecutor) GetCodeQLVersion() (string, error) {
 	output, err := ce.ExecuteCodeQLCommand("version")
@@ -1,5 +1,7 @@
 package Common
 
+import "time"
+
 var IsInstall bool
 var CreateJar string
 var DecompilerType string
@@ -27,5 +29,82 @@ var ExtraSourceDir string
 // 清理缓存配置
 var CleanCache bool
 
-// 新增：依赖选择模式（none|all；为空表示交互选择）
+// 新增：依赖选择模式（none|all|rules|first-party；为空表示交互选择）
 var DependencySelection string
+
+// 依赖选择规则（命令行与配置文件合并后的结果）
+var DepRules DependencyRules
+
+// 依赖选择规则的命令行原始值（逗号分隔）
+var depsIncludeFlag string
+var depsExcludeFlag string
+var depsCoordFlag string
+var depsCoordExcludeFlag string
+
+// 配置文件
+var ConfigFilePath string
+var SaveConfig bool
+
+// 批量建库配置
+var BatchSource string
+var BatchOutDir string
+var BatchWorkers int
+
+// 工作目录与输出配置
+var WorkspaceDir string
+var DatabaseOutPath string
+
+// SBOM配置
+var GenerateSbom bool
+
+// 本地漏洞库（OSV导出目录或压缩包）
+var VulnFeedPath string
+
+// 是否将XML、properties、JSP等资源文件加入源码根目录
+var IncludeResources bool
+
+// 是否对反编译失败的类逐个使用另一个反编译器重试
+var ClassFallback bool
+
+// -decompiler auto 时每个jar抽样评估的类数
+var AutoSampleSize int
+
+// 反编译时输出原始行号，用于把扫描结果映射回原始类和行号
+var LineNumbers bool
+
+// 反编译前检测混淆，并为冲突或非法的类名、成员名统一改名
+var Deobfuscate bool
+
+// ProGuard/R8混淆映射文件（mapping.txt），用于还原真实名称
+var MappingFile string
+
+// 多个jar中存在同名类时的处理策略（app|newest|separate）
+var DuplicatePolicy string
+
+// 在依赖jar和插件zip中递归查找嵌套jar的最大深度，0表示不查找
+var NestedDepth int
+
+// CodeQL和反编译器JVM的资源限制（MB），0表示根据cgroup和主机资源自动推导
+var RAMLimitMB int
+var ExtractorHeapMB int
+var JavaHeapMB int
+
+// 子命令（如 db bundle、db import）及其位置参数，为空时按 -database、-scan 等参数执行
+var Command string
+var CommandArgs []string
+
+// db bundle 是否打包原始制品和未压缩的反编译源码
+var BundleArtifact bool
+var BundleSources bool
+
+// 增量重建：上一个版本的数据库，未变化的类和jar复用其中的反编译源码
+var IncrementalBase string
+
+// 只报告涉及变化代码的结果：diff命令生成的对比报告
+var ChangedOnlyDiff string
+
+// 子进程的时间限制：每个反编译任务、每个查询和建库（0表示不限制），以及既没有输出也没有CPU进展多久判定为卡死（0表示不检测）
+var DecompileTimeout time.Duration
+var QueryTimeout time.Duration
+var CreateTimeout time.Duration
+var HangTimeout time.Duration
@@ -0,0 +1,136 @@
+package Common
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DependencyRules 依赖选择规则
+type DependencyRules struct {
+	Mode               string   `json:"mode,omitempty"`               // none|all|rules|first-party
+	Include            []string `json:"include,omitempty"`            // jar文件名glob，命中则选中
+	Exclude            []string `json:"exclude,omitempty"`            // jar文件名glob，命中则排除
+	IncludeCoordinates []string `json:"includeCoordinates,omitempty"` // groupId:artifactId:version正则，命中则选中
+	ExcludeCoordinates []string `json:"excludeCoordinates,omitempty"` // groupId:artifactId:version正则，命中则排除
+}
+
+// IsEmpty 是否未配置任何规则
+func (r DependencyRules) IsEmpty() bool {
+	return len(r.Include) == 0 && len(r.Exclude) == 0 &&
+		len(r.IncludeCoordinates) == 0 && len(r.ExcludeCoordinates) == 0
+}
+
+// ResourceSettings 内存和线程设置（MB），0表示自动推导
+type ResourceSettings struct {
+	RAM           int `json:"ram,omitempty"`           // codeql --ram
+	Threads       int `json:"threads,omitempty"`       // codeql --threads
+	ExtractorHeap int `json:"extractorHeap,omitempty"` // Java提取器JVM最大堆
+	JavaHeap      int `json:"javaHeap,omitempty"`      // 反编译器、javac最大堆
+}
+
+// FileConfig 配置文件内容
+type FileConfig struct {
+	Dependencies DependencyRules  `json:"dependencies"`
+	Resources    ResourceSettings `json:"resources"`
+}
+
+// LoadConfigFile 读取配置文件，文件不存在时返回空配置
+func LoadConfigFile(path string) (*FileConfig, error) {
+	config := &FileConfig{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	return config, nil
+}
+
+// SaveConfigFile 将配置写入文件
+func SaveConfigFile(path string, config *FileConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// splitList 拆分逗号分隔的参数值
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// applyConfigFile 加载配置文件，命令行显式指定的参数优先；指定-save-config时写回配置文件
+func applyConfigFile() error {
+	config, err := LoadConfigFile(ConfigFilePath)
+	if err != nil {
+		return err
+	}
+
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	// 依赖选择规则：命令行未指定时回放配置文件中保存的规则
+	rules := config.Dependencies
+	if setFlags["deps"] {
+		rules.Mode = DependencySelection
+	}
+	if setFlags["deps-include"] {
+		rules.Include = splitList(depsIncludeFlag)
+	}
+	if setFlags["deps-exclude"] {
+		rules.Exclude = splitList(depsExcludeFlag)
+	}
+	if setFlags["deps-coord"] {
+		rules.IncludeCoordinates = splitList(depsCoordFlag)
+	}
+	if setFlags["deps-coord-exclude"] {
+		rules.ExcludeCoordinates = splitList(depsCoordExcludeFlag)
+	}
+	if rules.Mode == "" && !rules.IsEmpty() {
+		rules.Mode = "rules"
+	}
+	DepRules = rules
+	DependencySelection = rules.Mode
+
+	// 资源设置：命令行未指定时使用配置文件中的值
+	resources := config.Resources
+	applyIntSetting(setFlags["ram"], &RAMLimitMB, &resources.RAM)
+	applyIntSetting(setFlags["threads"], &CodeQLThreads, &resources.Threads)
+	applyIntSetting(setFlags["extractor-heap"], &ExtractorHeapMB, &resources.ExtractorHeap)
+	applyIntSetting(setFlags["java-heap"], &JavaHeapMB, &resources.JavaHeap)
+
+	if SaveConfig {
+		config.Dependencies = rules
+		config.Resources = resources
+		if err := SaveConfigFile(ConfigFilePath, config); err != nil {
+			return fmt.Errorf("保存配置文件失败: %v", err)
+		}
+		LogInfo("已保存配置到 %s", ConfigFilePath)
+	}
+	return nil
+}
+
+// applyIntSetting 命令行指定时以命令行为准并记入配置，否则使用配置文件中的值
+func applyIntSetting(set bool, value *int, saved *int) {
+	if set {
+		*saved = *value
+	} else if *saved != 0 {
+		*value = *saved
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-
-	"github.com/fatih/color"
 )
 
 // SetupEnvironment 设置所有工具的环境变量
@@ -84,10 +82,8 @@ func setupCodeQLEnvironment(toolsDir string) error {
 		return fmt.Errorf("添加CodeQL到PATH失败: %v", err)
 	}
 
-	// 获取系统内存并计算一半
-	memoryGB := getHalfSystemMemoryGB()
-	color.Green("获取内存", memoryGB, "\n")
-	jvmArgs := fmt.Sprintf("-Xmx%dg -Xms%dg", memoryGB, memoryGB)
+	// 提取器堆由 -extractor-heap 决定，默认取 -ram 的一半
+	jvmArgs := fmt.Sprintf("-Xmx%dm -Xms%dm", ExtractorHeapMB, ExtractorHeapMB)
 
 	// 设置SEMMLE_JAVA_EXTRACTOR_JVM_ARGS
 	if err := os.Setenv("SEMMLE_JAVA_EXTRACTOR_JVM_ARGS", jvmArgs); err != nil {
@@ -254,20 +250,12 @@ func PrintToolVersions() {
 	fmt.Println("===================")
 }
 
-// getHalfSystemMemoryGB 获取系统内存的一半（以GB为单位）
-func getHalfSystemMemoryGB() int {
-	if runtime.GOOS == "windows" {
-		return getWindowsMemoryGB() / 2
-	}
-	return getLinuxMemoryGB() / 2
-}
-
-// getLinuxMemoryGB 获取Linux系统的总内存（GB）
-func getLinuxMemoryGB() int {
+// getLinuxMemoryMB 获取Linux系统的总内存（MB）
+func getLinuxMemoryMB() int {
 	data, err := os.ReadFile("/proc/meminfo")
 	if err != nil {
-		fmt.Printf("读取/proc/meminfo失败，使用默认值16GB: %v\n", err)
-		return 16
+		fmt.Printf("读取/proc/meminfo失败，使用默认值%dMB: %v\n", defaultMemoryMB, err)
+		return defaultMemoryMB
 	}
 
 	lines := strings.Split(string(data), "\n")
@@ -277,9 +265,9 @@ func getLinuxMemoryGB() int {
 			if len(fields) >= 2 {
 				memoryKB, err := strconv.ParseInt(fields[1], 10, 64)
 				if err == nil {
-					memoryGB := int(memoryKB / (1024 * 1024))
-					if memoryGB > 0 {
-						return memoryGB
+					memoryMB := int(memoryKB / 1024)
+					if memoryMB > 0 {
+						return memoryMB
 					}
 				}
 			}
@@ -287,8 +275,8 @@ func getLinuxMemoryGB() int {
 		}
 	}
 
-	fmt.Println("解析系统内存失败，使用默认值16GB")
-	return 16
+	fmt.Printf("解析系统内存失败，使用默认值%dMB\n", defaultMemoryMB)
+	return defaultMemoryMB
 }
 
 // getWindowsMemoryGB 获取Windows系统的总内存（GB）
@@ -4,13 +4,25 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 )
 
+// subcommands 支持的子命令及其动作
+var subcommands = map[string][]string{
+	"db":   {"bundle", "import"},
+	"diff": {},
+}
+
 func InitFlag() {
+	// 子命令写在最前面，如 codeql_n1ght db bundle <db>
+	args := parseSubcommand(os.Args[1:])
+
 	// 主要功能参数
 	flag.BoolVar(&IsInstall, "install", false, "一键安装环境")
-	flag.StringVar(&CreateJar, "database", "", "通过jar包一键生成数据库")
+	flag.StringVar(&CreateJar, "database", "", "通过jar/war/ear包或解压后的目录一键生成数据库")
 	flag.BoolVar(&ScanMode, "scan", false, "启用扫描模式")
+	flag.StringVar(&BatchSource, "batch", "", "批量建库：指定制品目录或清单文件（每行一个路径）")
 
 	// 安装模式专用参数（只能与-install一起使用）
 	flag.StringVar(&JDKDownloadURL, "jdk", "", "指定JDK下载地址（仅限-install模式）")
@@ -21,6 +33,7 @@ func InitFlag() {
 	flag.StringVar(&DatabasePath, "db", "./lib", "指定CodeQL数据库路径（仅限-scan模式）")
 	flag.StringVar(&QLLibsPath, "ql", "./qlLibs", "指定QL查询库路径（仅限-scan模式）")
 	flag.BoolVar(&CleanCache, "clean-cache", false, "扫描前清理缓存，确保修改的QL文件生效（仅限-scan模式）")
+	flag.StringVar(&ChangedOnlyDiff, "changed-only", "", "只报告位置或数据流步骤涉及新增、变化代码的结果，指定diff命令生成的对比报告（仅限-scan模式）")
 
 	// 保持向后兼容
 	flag.StringVar(&ScanDirectory, "d", "", "【已弃用】指定要扫描的目录，请使用-db和-ql参数")
@@ -28,37 +41,159 @@ func InitFlag() {
 	// 数据库模式专用参数（只能与-database一起使用）
 	flag.StringVar(&ExtraSourceDir, "dir", "", "指定额外的源码目录，将复制到src1中一起生成数据库（仅限-database模式）")
 	// 新增：控制依赖选择模式（none=空依赖, all=全依赖；不指定则进入交互选择）
-	flag.StringVar(&DependencySelection, "deps", "", "数据库生成时依赖选择：none=空依赖, all=全依赖；不指定进入交互选择（仅限-database模式）")
+	flag.StringVar(&DependencySelection, "deps", "", "数据库生成时依赖选择：none=空依赖, all=全依赖, rules=按规则, first-party=与主程序包名前缀相同的依赖；不指定进入交互选择（仅限-database模式）")
+	flag.StringVar(&depsIncludeFlag, "deps-include", "", "按jar文件名glob选中依赖，多个用逗号分隔（仅限-database模式）")
+	flag.StringVar(&depsExcludeFlag, "deps-exclude", "", "按jar文件名glob排除依赖，多个用逗号分隔（仅限-database模式）")
+	flag.StringVar(&depsCoordFlag, "deps-coord", "", "按Maven坐标groupId:artifactId:version正则选中依赖，多个用逗号分隔（仅限-database模式）")
+	flag.StringVar(&depsCoordExcludeFlag, "deps-coord-exclude", "", "按Maven坐标正则排除依赖，多个用逗号分隔（仅限-database模式）")
+
+	flag.BoolVar(&GenerateSbom, "sbom", true, "在数据库旁生成CycloneDX和SPDX格式的SBOM（-sbom=false关闭）")
+	flag.BoolVar(&IncludeResources, "resources", true, "将XML、properties、YAML、JSP等资源文件复制到源码根目录一起提取（-resources=false关闭）")
+	flag.BoolVar(&LineNumbers, "line-numbers", true, "反编译时输出原始行号（Procyon -dl、Fernflower/Vineflower -bsm），扫描结果可映射回原始jar、类和行号（-line-numbers=false关闭）")
+	flag.BoolVar(&ClassFallback, "class-fallback", true, "对反编译失败的类逐个使用另一个反编译器重试，按类保留较好的结果（-class-fallback=false关闭）")
+	flag.BoolVar(&Deobfuscate, "deobfuscate", false, "反编译前检测混淆，在制品的所有jar和class目录中为大小写冲突或非法的类名、成员名统一改名")
+	flag.StringVar(&MappingFile, "mapping", "", "ProGuard/R8混淆映射文件mapping.txt，反编译前还原真实类名和成员名（仅限-database模式）")
+	flag.IntVar(&NestedDepth, "nested-depth", 2, "在依赖jar和插件zip中递归查找嵌套jar的最大深度，0表示不查找")
+	flag.StringVar(&DuplicatePolicy, "duplicates", "app", "多个jar中存在同名类时的处理策略：app=主程序和自有依赖优先, newest=按版本取最新, separate=其余副本放到独立源码根目录")
+	flag.StringVar(&VulnFeedPath, "vuln-db", "", "本地漏洞库（OSV导出目录或zip），匹配依赖中的已知漏洞（仅限-database模式）")
+	flag.StringVar(&IncrementalBase, "incremental", "", "增量重建：指定上一个版本的数据库，未变化的类和jar复用其中的反编译源码，只反编译变化的部分（仅限-database模式）")
+	flag.StringVar(&DatabaseOutPath, "out", "", "数据库输出路径，默认 ./databases/<制品名>（仅限-database模式和db import）；db bundle时为数据库包路径，diff时为对比报告路径")
+
+	// 子命令参数
+	flag.BoolVar(&BundleArtifact, "bundle-artifact", false, "db bundle时把原始制品一起打包（按建库清单中的SHA-256校验）")
+	flag.BoolVar(&BundleSources, "bundle-sources", false, "db bundle时包含未压缩的反编译源码")
+
+	// 批量模式专用参数（只能与-batch一起使用）
+	flag.StringVar(&BatchOutDir, "batch-out", "./databases", "批量建库的输出目录，每个制品生成一个同名数据库（仅限-batch模式）")
+	flag.IntVar(&BatchWorkers, "batch-workers", 2, "批量建库的并发制品数（仅限-batch模式）")
 
 	// 通用配置参数
-	flag.StringVar(&DecompilerType, "decompiler", "procyon", "选择反编译器类型 (procyon|fernflower)")
+	flag.StringVar(&DecompilerType, "decompiler", "procyon", "选择反编译器类型 (procyon|fernflower|cfr|vineflower|auto)")
+	flag.IntVar(&AutoSampleSize, "auto-sample", 30, "-decompiler auto时每个jar抽样评估的类数")
 	flag.BoolVar(&UseGoroutine, "goroutine", false, "启用goroutine并发处理")
 	flag.IntVar(&MaxGoroutines, "max-goroutines", 4, "最大goroutine数量（需要-goroutine）")
-	flag.BoolVar(&KeepTempFiles, "keep-temp", false, "保留临时文件和目录")
-	flag.IntVar(&CodeQLThreads, "threads", 20, "CodeQL处理时的线程数")
+	flag.BoolVar(&KeepTempFiles, "keep-temp", false, "保留临时文件和目录，建库失败或被中断时也不删除")
+	flag.StringVar(&WorkspaceDir, "workspace", "", "工作目录根路径，每次运行在其下创建独立子目录（默认位于用户缓存目录）")
+	flag.IntVar(&CodeQLThreads, "threads", 0, "CodeQL处理时的线程数，0表示按CPU限制（含cgroup）自动推导")
+	flag.IntVar(&RAMLimitMB, "ram", 0, "codeql database create/analyze 的内存上限（MB），0表示按内存限制（含cgroup）自动推导")
+	flag.IntVar(&ExtractorHeapMB, "extractor-heap", 0, "Java提取器JVM的最大堆（MB），0表示取-ram的一半")
+	flag.IntVar(&JavaHeapMB, "java-heap", 0, "反编译器和javac等辅助JVM的最大堆（MB），0表示按-ram和并发数平分")
+	flag.DurationVar(&DecompileTimeout, "decompile-timeout", 30*time.Minute, "每个jar或class目录反编译任务的时间限制，超时记为失败并继续，0表示不限制")
+	flag.DurationVar(&QueryTimeout, "query-timeout", 0, "每个查询的时间限制，超时记为失败并继续下一个查询，0表示不限制")
+	flag.DurationVar(&CreateTimeout, "create-timeout", 0, "codeql database create 的时间限制，0表示不限制")
+	flag.DurationVar(&HangTimeout, "hang-timeout", 10*time.Minute, "子进程既没有输出也没有CPU进展超过该时间时判定为卡死并终止，0表示不检测")
+
+	// 配置文件
+	flag.StringVar(&ConfigFilePath, "config", "n1ght.json", "配置文件路径，保存的依赖选择规则会在下次运行时回放")
+	flag.BoolVar(&SaveConfig, "save-config", false, "将本次的依赖选择规则和资源设置保存到配置文件")
 
 	// 自定义help信息
 	flag.Usage = printUsage
 
-	flag.Parse()
+	parseArgs(args)
+
+	// 合并配置文件
+	if err := applyConfigFile(); err != nil {
+		LogError("加载配置文件失败: %v", err)
+		os.Exit(1)
+	}
+}
+
+// parseSubcommand 识别命令行开头的子命令，返回剩余参数
+func parseSubcommand(args []string) []string {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return args
+	}
+	actions, ok := subcommands[args[0]]
+	if !ok {
+		LogError("未知子命令: %s，可用 -h 查看用法", args[0])
+		os.Exit(1)
+	}
+	if len(actions) == 0 {
+		Command = args[0]
+		return args[1:]
+	}
+	if len(args) < 2 || !containsString(actions, args[1]) {
+		LogError("子命令 %s 需要指定动作: %s", args[0], strings.Join(actions, "|"))
+		os.Exit(1)
+	}
+	Command = args[0] + " " + args[1]
+	return args[2:]
+}
+
+// parseArgs 解析参数；子命令的位置参数和参数可以交替出现
+func parseArgs(args []string) {
+	for {
+		flag.CommandLine.Parse(args)
+		rest := flag.Args()
+		if Command == "" || len(rest) == 0 {
+			return
+		}
+		CommandArgs = append(CommandArgs, rest[0])
+		args = rest[1:]
+	}
+}
+
+// containsString 判断列表中是否包含指定字符串
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
 }
 
 // printUsage 自定义使用说明
 func printUsage() {
 	fmt.Println("Usage: codeql_n1ght [options]")
+	fmt.Println("       codeql_n1ght db bundle <db> [-out <bundle.zip>] [-bundle-artifact] [-bundle-sources]")
+	fmt.Println("       codeql_n1ght db import <bundle.zip> [-out <db>]")
+	fmt.Println("       codeql_n1ght diff <old> <new> [-out <report.json>]")
 	fmt.Println("\n主要功能：")
 	fmt.Println("  -install                   一键安装环境")
-	fmt.Println("  -database <jar>            通过jar包一键生成数据库")
+	fmt.Println("  -database <jar|dir>        通过jar/war/ear包或解压后的目录一键生成数据库")
 	fmt.Println("  -scan                      启用扫描模式")
+	fmt.Println("  -batch <dir|file>          批量建库：制品目录或清单文件（每行一个路径）")
 
 	fmt.Println("\n数据库模式参数（仅与 -database 一起使用）：")
 	fmt.Println("  -dir <path>                指定额外源码目录，复制到src1中一起生成数据库")
-	fmt.Println("  -deps <none|all>           依赖选择：none=空依赖, all=全依赖；不指定进入交互选择")
+	fmt.Println("  -deps <mode>               依赖选择：none=空依赖, all=全依赖, rules=按规则, first-party=与主程序同包名前缀；不指定进入交互选择")
+	fmt.Println("  -deps-include <globs>      按jar文件名glob选中依赖（逗号分隔）")
+	fmt.Println("  -deps-exclude <globs>      按jar文件名glob排除依赖（逗号分隔）")
+	fmt.Println("  -deps-coord <regexes>      按Maven坐标 groupId:artifactId:version 正则选中依赖（逗号分隔）")
+	fmt.Println("  -deps-coord-exclude <re>   按Maven坐标正则排除依赖（逗号分隔）")
+	fmt.Println("  -out <path>                数据库输出路径（默认 ./databases/<制品名>）")
+	fmt.Println("  -incremental <db>          增量重建：与上一个版本的数据库比对类和jar的摘要，只反编译变化的部分")
+	fmt.Println("  -line-numbers=false        不输出原始行号（默认开启，扫描结果映射回原始jar、类和行号）")
+	fmt.Println("  -class-fallback=false      关闭逐类回退（默认对含失败标记或javac报错的类用另一个反编译器重试）")
+	fmt.Println("  -mapping <path>            ProGuard/R8混淆映射文件mapping.txt，反编译前还原真实类名和成员名")
+	fmt.Println("  -deobfuscate               检测混淆，在制品所有输入中为大小写冲突、非法标识符的类和成员统一改名")
+	fmt.Println("  -nested-depth <n>          在依赖jar和插件zip中递归查找嵌套jar的最大深度（默认 2，0 不查找）")
+	fmt.Println("  -duplicates <policy>       同名类处理策略：app=主程序和自有依赖优先（默认）, newest=按版本取最新, separate=其余副本放到独立源码根目录")
+	fmt.Println("  -resources=false           不提取XML、properties、JSP等资源文件（默认复制到源码根目录的resources下）")
+	fmt.Println("  -vuln-db <path>            本地OSV漏洞库（目录或zip），报告写入 <db>.vulns.json，并在依赖选择中标记")
+	fmt.Println("  -sbom=false                不生成SBOM（默认在数据库旁生成 <db>.cdx.json 和 <db>.spdx.json）")
+
+	fmt.Println("\n子命令：")
+	fmt.Println("  db bundle <db>             用codeql database bundle打包数据库，附带n1ght-db.json、源码来源和SBOM（默认输出 <db>.n1ght.zip）")
+	fmt.Println("    -out <path>              数据库包路径")
+	fmt.Println("    -bundle-artifact         一起打包原始制品（按建库清单中的SHA-256校验）")
+	fmt.Println("    -bundle-sources          包含未压缩的反编译源码")
+	fmt.Println("  db import <bundle>         校验数据库包中每个条目的SHA-256后解包（默认输出 ./databases/<数据库名>）")
+	fmt.Println("    -out <path>              数据库输出路径，原始制品解压到 <db>.artifact/")
+	fmt.Println("  diff <old> <new>           对比两个版本的jar/war/ear，列出新增、删除和变化的类和方法")
+	fmt.Println("    -out <path>              对比报告路径（默认 n1ght-diff.json），扫描时用 -changed-only 指定")
+
+	fmt.Println("\n批量模式参数（仅与 -batch 一起使用，同时支持 -dir/-deps 等数据库模式参数）：")
+	fmt.Println("  -batch-out <path>          输出目录，每个制品生成一个同名数据库（默认 ./databases）")
+	fmt.Println("  -batch-workers <n>         并发处理的制品数（默认 2）")
 
 	fmt.Println("\n扫描模式参数（仅与 -scan 一起使用）：")
 	fmt.Println("  -db <path>                 指定CodeQL数据库路径")
 	fmt.Println("  -ql <path>                 指定QL查询库路径")
 	fmt.Println("  -clean-cache               扫描前清理缓存，确保修改的QL文件生效")
+	fmt.Println("  -changed-only <report>     只报告涉及新增、变化代码的结果（位置或数据流步骤落在变化的方法上），报告由diff命令生成")
 
 	fmt.Println("\n安装模式参数（仅与 -install 一起使用）：")
 	fmt.Println("  -jdk <url>                 指定JDK下载地址")
@@ -66,14 +201,29 @@ func printUsage() {
 	fmt.Println("  -codeql <url>              指定CodeQL下载地址")
 
 	fmt.Println("\n通用配置：")
-	fmt.Println("  -decompiler <type>         选择反编译器类型 (procyon|fernflower)")
+	fmt.Println("  -decompiler <type>         选择反编译器类型 (procyon|fernflower|cfr|vineflower|auto)，CFR和Vineflower首次使用时自动下载")
+	fmt.Println("                             auto: 用所有已安装的反编译器反编译样本类并打分，为每个jar选择最好的")
+	fmt.Println("  -auto-sample <n>           auto模式下每个jar抽样评估的类数（默认 30）")
 	fmt.Println("  -goroutine                 启用goroutine并发处理")
 	fmt.Println("  -max-goroutines <n>        最大goroutine数量（需要-goroutine）")
-	fmt.Println("  -keep-temp                 保留临时文件和目录")
-	fmt.Println("  -threads <n>               CodeQL处理时的线程数")
+	fmt.Println("  -keep-temp                 保留临时文件和目录，建库失败或被中断时也不删除")
+	fmt.Println("  -workspace <path>          工作目录根路径（默认位于用户缓存目录）")
+	fmt.Println("  -config <path>             配置文件路径（默认 n1ght.json）")
+	fmt.Println("  -save-config               将本次的依赖选择规则和资源设置保存到配置文件")
+	fmt.Println("  -threads <n>               CodeQL处理时的线程数（默认 0，按CPU限制自动推导，并发扫描时平分）")
+	fmt.Println("  -ram <mb>                  codeql database create/analyze 的内存上限（默认 0，按容器/主机内存自动推导）")
+	fmt.Println("  -extractor-heap <mb>       Java提取器JVM的最大堆（默认 0，取 -ram 的一半）")
+	fmt.Println("  -java-heap <mb>            反编译器和javac的最大堆（默认 0，按 -ram 和并发JVM数平分）")
+	fmt.Println("  -decompile-timeout <d>     每个jar或class目录反编译任务的时间限制（默认 30m，0 不限制），超时记为失败并继续")
+	fmt.Println("  -query-timeout <d>         每个查询的时间限制（默认 0 不限制），超时记为失败并继续下一个查询")
+	fmt.Println("  -create-timeout <d>        codeql database create 的时间限制（默认 0 不限制）")
+	fmt.Println("  -hang-timeout <d>          子进程既没有输出也没有CPU进展多久判定为卡死并终止（默认 10m，0 不检测）")
 
 	fmt.Println("\n示例：")
 	fmt.Println("  codeql_n1ght -database app.jar -deps none")
 	fmt.Println("  codeql_n1ght -database app.jar -deps all")
+	fmt.Println("  codeql_n1ght -database app.war -deps-include 'acme-*' -deps-exclude '*-test*' -save-config")
+	fmt.Println("  codeql_n1ght -database ./webapps/app -deps none")
+	fmt.Println("  codeql_n1ght -batch ./deploy -batch-out ./databases -deps none")
 	os.Exit(0)
 }
@@ -0,0 +1,87 @@
+package Common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// OriginsFileName 数据库目录下记录反编译源码来源的文件
+const OriginsFileName = "n1ght-origins.json"
+
+// maxLineMappingDistance 没有精确映射时向前查找最近映射行的最大距离
+const maxLineMappingDistance = 5
+
+// SourceOrigin 反编译生成的源码文件的来源
+type SourceOrigin struct {
+	Source     string      `json:"source"`               // 源码根目录下的路径，如 src1/com/foo/Bar.java
+	Artifact   string      `json:"artifact"`             // 来源jar或class目录，如 WEB-INF/lib/foo.jar
+	Class      string      `json:"class"`                // class条目（如 com/foo/Bar.class）或JSP文件（如 WEB-INF/jsp/index.jsp）
+	Decompiler string      `json:"decompiler,omitempty"` // 生成该文件的反编译器或编译器
+	Lines      map[int]int `json:"lines,omitempty"`      // 反编译后的行号 -> 原始源码行号（来自字节码行号表或SMAP）
+
+	Includes map[int]string `json:"includes,omitempty"` // 来自被包含文件（如JSP的 <%@ include %>）的行 -> 文件
+}
+
+// OriginalLine 返回反编译行对应的原始行号，没有精确映射时取前面最近的映射行，找不到时返回0
+func (o *SourceOrigin) OriginalLine(line int) int {
+	_, original := o.OriginalPosition(line)
+	return original
+}
+
+// OriginalPosition 返回反编译行对应的原始文件和行号，找不到行号时返回Class和0
+func (o *SourceOrigin) OriginalPosition(line int) (string, int) {
+	for l := line; l > 0 && line-l <= maxLineMappingDistance; l-- {
+		if original, ok := o.Lines[l]; ok {
+			if file, ok := o.Includes[l]; ok {
+				return file, original
+			}
+			return o.Class, original
+		}
+	}
+	return o.Class, 0
+}
+
+// Location 返回 jar!class:原始行号 形式的原始位置，制品根目录下的文件省略制品部分
+func (o *SourceOrigin) Location(line int) string {
+	file, original := o.OriginalPosition(line)
+	location := file
+	if o.Artifact != "" && o.Artifact != "." {
+		location = o.Artifact + "!" + file
+	}
+	if original > 0 {
+		location = fmt.Sprintf("%s:%d", location, original)
+	}
+	return location
+}
+
+// WriteOrigins 将源码来源写入数据库目录
+func WriteOrigins(dbPath string, origins []SourceOrigin) error {
+	data, err := json.MarshalIndent(origins, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dbPath, OriginsFileName), data, 0644)
+}
+
+// LoadOrigins 读取数据库目录中的源码来源，按源码路径索引；文件不存在时返回nil
+func LoadOrigins(dbPath string) (map[string]*SourceOrigin, error) {
+	data, err := os.ReadFile(filepath.Join(dbPath, OriginsFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var origins []SourceOrigin
+	if err := json.Unmarshal(data, &origins); err != nil {
+		return nil, fmt.Errorf("解析%s失败: %v", OriginsFileName, err)
+	}
+	index := make(map[string]*SourceOrigin, len(origins))
+	for i := range origins {
+		index[origins[i].Source] = &origins[i]
+	}
+	return index, nil
+}
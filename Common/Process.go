@@ -0,0 +1,91 @@
+package Common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// processWaitDelay 进程被终止后等待其输出管道关闭的最长时间
+const processWaitDelay = 5 * time.Second
+
+// SignalContext 返回收到SIGINT/SIGTERM时取消的context，第二次收到信号时立即退出
+func SignalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		LogWarn("收到 %v 信号，正在终止子进程并清理，再次发送可强制退出", sig)
+		cancel(fmt.Errorf("收到 %v 信号", sig))
+		if _, ok := <-signals; ok {
+			os.Exit(130)
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
+
+// Interrupted 判断操作是否已被取消（收到信号或超过时间限制）
+func Interrupted(ctx context.Context) bool {
+	return ctx.Err() != nil
+}
+
+// InterruptReason 返回取消的原因（如收到的信号）
+func InterruptReason(ctx context.Context) string {
+	if cause := context.Cause(ctx); cause != nil {
+		return cause.Error()
+	}
+	return ""
+}
+
+// CommandContext 创建随ctx取消的命令，取消时终止子进程及其启动的整个进程树
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessTree(cmd)
+	}
+	cmd.WaitDelay = processWaitDelay
+	return cmd
+}
+
+// StageSnapshot 操作被中断时所处的阶段，写入 <输出>.interrupted.json 供排查和重新运行
+type StageSnapshot struct {
+	Operation     string    `json:"operation"` // database、scan或install
+	Target        string    `json:"target"`    // 制品、数据库或工具
+	Output        string    `json:"output,omitempty"`
+	Stage         string    `json:"stage"`               // 中断时正在进行的阶段
+	Completed     []string  `json:"completed,omitempty"` // 已完成的阶段
+	Finished      []string  `json:"finished,omitempty"`  // 已完成的反编译输入或查询
+	Pending       []string  `json:"pending,omitempty"`   // 尚未完成的查询
+	Workspace     string    `json:"workspace,omitempty"`
+	WorkspaceKept bool      `json:"workspaceKept,omitempty"`
+	Reason        string    `json:"reason"`
+	StartedAt     time.Time `json:"startedAt"`
+	InterruptedAt time.Time `json:"interruptedAt"`
+}
+
+// InterruptedStatePath 返回输出路径对应的中断快照文件
+func InterruptedStatePath(output string) string {
+	return output + ".interrupted.json"
+}
+
+// WriteStageSnapshot 将中断快照写入path
+func WriteStageSnapshot(path string, snapshot *StageSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
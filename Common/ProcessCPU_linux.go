@@ -0,0 +1,42 @@
+package Common
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cpuSampling 能否读取进程树的CPU时间，不能时不做卡死检测
+const cpuSampling = true
+
+// processTreeCPU 返回进程组pgid中所有进程累计的CPU时间（时钟滴答数），用于判断进程是否仍在运行
+func processTreeCPU(pgid int) (uint64, bool) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, false
+	}
+	var total uint64
+	found := false
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// 进程名可能包含空格和括号，从最后一个右括号之后开始按字段解析：
+		// state ppid pgrp session tty_nr tpgid flags minflt cminflt majflt cmajflt utime stime
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 13 || fields[2] != strconv.Itoa(pgid) {
+			continue
+		}
+		utime, _ := strconv.ParseUint(fields[11], 10, 64)
+		stime, _ := strconv.ParseUint(fields[12], 10, 64)
+		total += utime + stime
+		found = true
+	}
+	return total, found
+}
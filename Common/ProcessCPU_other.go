@@ -0,0 +1,11 @@
+//go:build !linux
+
+package Common
+
+// cpuSampling 非Linux系统不读取CPU时间，不做卡死检测，避免只依据输出误杀长时间不输出的ant、javac
+const cpuSampling = false
+
+// processTreeCPU 非Linux系统不读取CPU时间
+func processTreeCPU(pgid int) (uint64, bool) {
+	return 0, false
+}
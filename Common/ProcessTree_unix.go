@@ -0,0 +1,27 @@
+//go:build !windows
+
+package Common
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让子进程成为新进程组的组长，终止时可以连同它启动的java、ant等进程一起结束
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// killProcessTree 向子进程所在的整个进程组发送SIGKILL
+func killProcessTree(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
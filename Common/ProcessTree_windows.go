@@ -0,0 +1,20 @@
+package Common
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup Windows下用taskkill /T按进程树终止，无需额外设置
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessTree 用taskkill /T /F终止子进程及其启动的所有进程
+func killProcessTree(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
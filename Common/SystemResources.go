@@ -0,0 +1,264 @@
+package Common
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cgroup文件系统的挂载点和记录当前进程所在cgroup的文件
+const (
+	cgroupRoot     = "/sys/fs/cgroup"
+	procSelfCgroup = "/proc/self/cgroup"
+)
+
+// 内存分配的下限和预留
+const (
+	minHeapMB        = 256   // 任何JVM堆的最小值
+	minReserveMB     = 512   // 至少为系统和其他进程预留的内存
+	reservePercent   = 10    // 按总内存比例预留的内存
+	defaultMemoryMB  = 16384 // 无法检测内存时使用的默认值
+	unlimitedCgroupB = 1 << 62
+)
+
+// ResourceLimits 检测到的可用内存和CPU
+type ResourceLimits struct {
+	MemoryMB int    `json:"memoryMB"`
+	CPUs     int    `json:"cpus"`
+	Source   string `json:"source"` // host、cgroup v1或cgroup v2
+}
+
+// EffectiveResources 本次运行检测到的资源限制，由ResolveResources填充
+var EffectiveResources ResourceLimits
+
+// threadsAuto -threads未指定时按CPU数推导，并发扫描时平均分给每个查询
+var threadsAuto bool
+
+// ResolveResources 检测cgroup和主机的内存、CPU限制，为未指定的 -ram、-threads、-extractor-heap、-java-heap 推导默认值
+func ResolveResources() error {
+	if RAMLimitMB < 0 || CodeQLThreads < 0 || ExtractorHeapMB < 0 || JavaHeapMB < 0 {
+		return fmt.Errorf("内存和线程参数不能为负数")
+	}
+
+	limits := DetectResourceLimits()
+	EffectiveResources = limits
+
+	if CodeQLThreads == 0 {
+		threadsAuto = true
+		CodeQLThreads = limits.CPUs
+	}
+	if RAMLimitMB == 0 {
+		reserve := limits.MemoryMB * reservePercent / 100
+		if reserve < minReserveMB {
+			reserve = minReserveMB
+		}
+		RAMLimitMB = atLeast(limits.MemoryMB-reserve, minHeapMB)
+	}
+	if ExtractorHeapMB == 0 {
+		ExtractorHeapMB = atLeast(RAMLimitMB/2, minHeapMB)
+	}
+	if JavaHeapMB == 0 {
+		JavaHeapMB = atLeast(RAMLimitMB/concurrentJVMs(), minHeapMB)
+	}
+	return nil
+}
+
+// QueryThreads 返回每个codeql database analyze进程使用的线程数，自动推导时并发查询平分CPU
+func QueryThreads() int {
+	if threadsAuto && UseGoroutine && MaxGoroutines > 1 {
+		return atLeast(CodeQLThreads/MaxGoroutines, 1)
+	}
+	return CodeQLThreads
+}
+
+// QueryRAM 返回每个codeql database analyze进程的内存上限，并发查询时平分 -ram
+func QueryRAM() int {
+	if UseGoroutine && MaxGoroutines > 1 {
+		return atLeast(RAMLimitMB/MaxGoroutines, minHeapMB)
+	}
+	return RAMLimitMB
+}
+
+// JavaHeapArg 返回反编译器等辅助JVM的最大堆参数
+func JavaHeapArg() string {
+	return fmt.Sprintf("-Xmx%dm", JavaHeapMB)
+}
+
+// ResourceSummary 返回资源设置的说明
+func ResourceSummary() string {
+	return fmt.Sprintf("可用内存 %dMB、CPU %d（%s），CodeQL --ram=%d --threads=%d，提取器堆 %dMB，反编译器堆 %dMB",
+		EffectiveResources.MemoryMB, EffectiveResources.CPUs, EffectiveResources.Source,
+		RAMLimitMB, CodeQLThreads, ExtractorHeapMB, JavaHeapMB)
+}
+
+// concurrentJVMs 同时运行的反编译器JVM数量
+func concurrentJVMs() int {
+	jvms := 1
+	if UseGoroutine && MaxGoroutines > 1 {
+		jvms = MaxGoroutines
+	}
+	if BatchSource != "" && BatchWorkers > 1 {
+		jvms *= BatchWorkers
+	}
+	return jvms
+}
+
+// DetectResourceLimits 检测可用内存和CPU，Linux下取主机和cgroup（v1/v2）限制中较小的值
+func DetectResourceLimits() ResourceLimits {
+	limits := ResourceLimits{MemoryMB: hostMemoryMB(), CPUs: runtime.NumCPU(), Source: "host"}
+	if runtime.GOOS != "linux" {
+		return limits
+	}
+	return applyCgroupLimits(limits, cgroupRoot, procSelfCgroup)
+}
+
+// applyCgroupLimits 读取挂载在root下的cgroup（v1/v2）限制，比主机更严格时替换limits中的值；selfCgroup为/proc/self/cgroup格式的文件
+func applyCgroupLimits(limits ResourceLimits, root, selfCgroup string) ResourceLimits {
+	var memoryBytes int64
+	var cpus float64
+	var source string
+	if FileExists(filepath.Join(root, "cgroup.controllers")) {
+		memoryBytes, cpus = cgroupV2Limits(root, selfCgroup)
+		source = "cgroup v2"
+	} else {
+		memoryBytes, cpus = cgroupV1Limits(root, selfCgroup)
+		source = "cgroup v1"
+	}
+
+	if memoryBytes > 0 && memoryBytes < unlimitedCgroupB {
+		if mb := int(memoryBytes / (1024 * 1024)); mb > 0 && mb < limits.MemoryMB {
+			limits.MemoryMB = mb
+			limits.Source = source
+		}
+	}
+	if cpus > 0 {
+		if n := int(math.Ceil(cpus)); n < limits.CPUs {
+			limits.CPUs = n
+			limits.Source = source
+		}
+	}
+	return limits
+}
+
+// cgroupV2Limits 读取当前进程所在cgroup及其上级的memory.max和cpu.max，取最严格的限制
+func cgroupV2Limits(root, selfCgroup string) (int64, float64) {
+	var memory int64
+	var cpus float64
+	for _, dir := range cgroupDirs(root, selfCgroupPath(selfCgroup, "")) {
+		if value, ok := readCgroupInt(filepath.Join(dir, "memory.max")); ok && (memory == 0 || value < memory) {
+			memory = value
+		}
+		// cpu.max: "<quota> <period>"，quota为max表示不限制
+		if data, err := os.ReadFile(filepath.Join(dir, "cpu.max")); err == nil {
+			fields := strings.Fields(string(data))
+			if len(fields) == 2 && fields[0] != "max" {
+				quota, err1 := strconv.ParseFloat(fields[0], 64)
+				period, err2 := strconv.ParseFloat(fields[1], 64)
+				if err1 == nil && err2 == nil && period > 0 && (cpus == 0 || quota/period < cpus) {
+					cpus = quota / period
+				}
+			}
+		}
+	}
+	return memory, cpus
+}
+
+// cgroupV1Limits 读取memory.limit_in_bytes和cpu.cfs_quota_us/cpu.cfs_period_us
+func cgroupV1Limits(root, selfCgroup string) (int64, float64) {
+	var memory int64
+	for _, dir := range cgroupDirs(filepath.Join(root, "memory"), selfCgroupPath(selfCgroup, "memory")) {
+		if value, ok := readCgroupInt(filepath.Join(dir, "memory.limit_in_bytes")); ok && (memory == 0 || value < memory) {
+			memory = value
+		}
+	}
+
+	var cpus float64
+	for _, mount := range []string{"cpu", "cpu,cpuacct", "cpuacct,cpu"} {
+		for _, dir := range cgroupDirs(filepath.Join(root, mount), selfCgroupPath(selfCgroup, "cpu")) {
+			quota, ok1 := readCgroupInt(filepath.Join(dir, "cpu.cfs_quota_us"))
+			period, ok2 := readCgroupInt(filepath.Join(dir, "cpu.cfs_period_us"))
+			if ok1 && ok2 && quota > 0 && period > 0 {
+				if value := float64(quota) / float64(period); cpus == 0 || value < cpus {
+					cpus = value
+				}
+			}
+		}
+	}
+	return memory, cpus
+}
+
+// selfCgroupPath 从/proc/self/cgroup格式的文件读取当前进程的cgroup路径，controller为空时读取v2的统一层级
+func selfCgroupPath(file, controller string) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式：hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if controller == "" && parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if controller != "" && c == controller {
+				return parts[2]
+			}
+		}
+	}
+	return ""
+}
+
+// cgroupDirs 返回进程cgroup目录及其所有上级目录（容器内只挂载了自身cgroup时只有挂载点）
+func cgroupDirs(mount, cgroupPath string) []string {
+	var dirs []string
+	for p := filepath.Clean("/" + cgroupPath); ; p = filepath.Dir(p) {
+		dir := filepath.Join(mount, p)
+		if IsDirectory(dir) {
+			dirs = append(dirs, dir)
+		}
+		if p == "/" {
+			break
+		}
+	}
+	return dirs
+}
+
+// readCgroupInt 读取cgroup文件中的整数值，"max"或无法解析时返回false
+func readCgroupInt(path string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// hostMemoryMB 获取主机的总内存（MB）
+func hostMemoryMB() int {
+	if runtime.GOOS == "windows" {
+		return getWindowsMemoryGB() * 1024
+	}
+	return getLinuxMemoryMB()
+}
+
+// atLeast 返回value和min中较大的值
+func atLeast(value, min int) int {
+	if value < min {
+		return min
+	}
+	return value
+}
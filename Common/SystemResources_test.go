@@ -0,0 +1,75 @@
+package Common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCgroupFixture 在dir下按相对路径写入cgroup文件
+func writeCgroupFixture(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestApplyCgroupLimits(t *testing.T) {
+	host := ResourceLimits{MemoryMB: 16384, CPUs: 8, Source: "host"}
+	tests := []struct {
+		name  string
+		self  string
+		files map[string]string
+		want  ResourceLimits
+	}{
+		{"v2 limits on the process cgroup", "0::/docker/abc\n", map[string]string{
+			"cgroup.controllers":    "cpu memory\n",
+			"docker/memory.max":     "max\n",
+			"docker/cpu.max":        "max 100000\n",
+			"docker/abc/memory.max": "2147483648\n",
+			"docker/abc/cpu.max":    "150000 100000\n",
+		}, ResourceLimits{MemoryMB: 2048, CPUs: 2, Source: "cgroup v2"}},
+		{"v2 limit on a parent cgroup", "0::/kubepods/pod/ctr\n", map[string]string{
+			"cgroup.controllers":          "cpu memory\n",
+			"kubepods/pod/memory.max":     "1073741824\n",
+			"kubepods/pod/ctr/memory.max": "max\n",
+			"kubepods/pod/ctr/cpu.max":    "max 100000\n",
+		}, ResourceLimits{MemoryMB: 1024, CPUs: 8, Source: "cgroup v2"}},
+		{"v2 unlimited", "0::/\n", map[string]string{
+			"cgroup.controllers": "cpu memory\n",
+			"memory.max":         "max\n",
+			"cpu.max":            "max 100000\n",
+		}, host},
+		{"v1 quota and memory limit", "12:memory:/kube/pod\n11:cpu,cpuacct:/kube/pod\n", map[string]string{
+			"memory/kube/pod/memory.limit_in_bytes":  "1073741824\n",
+			"cpu,cpuacct/kube/pod/cpu.cfs_quota_us":  "50000\n",
+			"cpu,cpuacct/kube/pod/cpu.cfs_period_us": "100000\n",
+		}, ResourceLimits{MemoryMB: 1024, CPUs: 1, Source: "cgroup v1"}},
+		{"v1 unlimited", "12:memory:/\n11:cpu,cpuacct:/\n", map[string]string{
+			"memory/memory.limit_in_bytes":  "9223372036854771712\n",
+			"cpu,cpuacct/cpu.cfs_quota_us":  "-1\n",
+			"cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+		}, host},
+		{"limit above the host", "0::/\n", map[string]string{
+			"cgroup.controllers": "cpu memory\n",
+			"memory.max":         "68719476736\n",
+			"cpu.max":            "1600000 100000\n",
+		}, host},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		root := filepath.Join(dir, "cgroup")
+		self := filepath.Join(dir, "self-cgroup")
+		writeCgroupFixture(t, root, tt.files)
+		writeCgroupFixture(t, dir, map[string]string{"self-cgroup": tt.self})
+		if got := applyCgroupLimits(host, root, self); got != tt.want {
+			t.Errorf("%s: limits = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package Common
 
 import (
 	"archive/zip"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -74,9 +75,13 @@ func IsDirectory(path string) bool {
 	return info.IsDir()
 }
 
-// DownloadFile 下载文件的通用函数（带进度条）
-func DownloadFile(url, filepath string) error {
-	resp, err := http.Get(url)
+// DownloadFile 下载文件的通用函数（带进度条），ctx取消时中止下载并删除未完成的文件
+func DownloadFile(ctx context.Context, url, filepath string) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -90,7 +95,12 @@ func DownloadFile(url, filepath string) error {
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(filepath)
+		}
+	}()
 
 	// 获取文件大小和文件名
 	fileSize := resp.ContentLength
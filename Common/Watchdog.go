@@ -0,0 +1,211 @@
+package Common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProcessTimeoutError 进程或任务超过时间限制，或长时间没有输出和CPU进展而被终止
+type ProcessTimeoutError struct {
+	Name  string
+	Limit time.Duration
+	Hang  bool // true表示卡死（无输出且无CPU进展），false表示超过总时间限制
+}
+
+// Error 返回超时说明
+func (e *ProcessTimeoutError) Error() string {
+	if e.Hang {
+		return fmt.Sprintf("%s 已 %v 没有输出和CPU进展，判定为卡死", e.Name, e.Limit)
+	}
+	return fmt.Sprintf("%s 超过时间限制 %v", e.Name, e.Limit)
+}
+
+// IsTimeout 判断错误是否为超时或卡死
+func IsTimeout(err error) bool {
+	var timeout *ProcessTimeoutError
+	return errors.As(err, &timeout)
+}
+
+// WithTimeout 返回超过limit后取消的context，取消原因为ProcessTimeoutError，limit<=0表示不限制
+func WithTimeout(ctx context.Context, name string, limit time.Duration) (context.Context, context.CancelFunc) {
+	if limit <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, limit, &ProcessTimeoutError{Name: name, Limit: limit})
+}
+
+// WatchedCommand 带总时间限制和卡死检测的子进程
+type WatchedCommand struct {
+	*exec.Cmd
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	stop     context.CancelFunc
+	name     string
+	activity atomic.Int64 // 最近一次有输出或CPU进展的时间（UnixNano）
+	done     chan struct{}
+}
+
+// WatchCommand 创建子进程：ctx取消、超过timeout（<=0表示不限制），或-hang-timeout内既没有输出也没有CPU进展时终止整个进程树，
+// Wait返回ProcessTimeoutError
+func WatchCommand(ctx context.Context, timeout time.Duration, name string, args ...string) *WatchedCommand {
+	label := filepath.Base(name)
+	ctx, stop := WithTimeout(ctx, label, timeout)
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &WatchedCommand{
+		Cmd:    CommandContext(ctx, name, args...),
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   stop,
+		name:   label,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start 启动进程并开始卡死检测
+func (w *WatchedCommand) Start() error {
+	if err := w.Cmd.Start(); err != nil {
+		w.release()
+		return err
+	}
+	w.touch()
+	if HangTimeout > 0 {
+		go w.watch()
+	}
+	return nil
+}
+
+// Wait 等待进程结束，因超时或卡死被终止时返回ProcessTimeoutError
+func (w *WatchedCommand) Wait() error {
+	err := w.Cmd.Wait()
+	var cause error
+	if w.ctx.Err() != nil {
+		cause = context.Cause(w.ctx)
+	}
+	w.release()
+	if err != nil && IsTimeout(cause) {
+		return cause
+	}
+	return err
+}
+
+// Run 启动进程并等待结束
+func (w *WatchedCommand) Run() error {
+	if err := w.Start(); err != nil {
+		return err
+	}
+	return w.Wait()
+}
+
+// CombinedOutput 运行进程并返回标准输出和标准错误
+func (w *WatchedCommand) CombinedOutput() ([]byte, error) {
+	var output bytes.Buffer
+	tracked := &trackedWriter{writer: &output, touch: w.touch}
+	w.Stdout = tracked
+	w.Stderr = tracked
+	if err := w.Start(); err != nil {
+		return nil, err
+	}
+	err := w.Wait()
+	return output.Bytes(), err
+}
+
+// TrackOutput 包装输出管道，读到输出时记为有进展
+func (w *WatchedCommand) TrackOutput(reader io.Reader) io.Reader {
+	return &trackedReader{reader: reader, touch: w.touch}
+}
+
+// touch 记录一次进展
+func (w *WatchedCommand) touch() {
+	w.activity.Store(time.Now().UnixNano())
+}
+
+// release 停止卡死检测并释放context
+func (w *WatchedCommand) release() {
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
+	}
+	w.cancel(nil)
+	w.stop()
+}
+
+// hangDetectionOff 无法读取CPU时间时只提示一次
+var hangDetectionOff sync.Once
+
+// watch 定期检查输出和进程树的CPU时间，超过-hang-timeout都没有变化时终止进程；
+// 系统不支持读取CPU时间时只看输出会误杀长时间静默编译的进程，因此不做卡死检测
+func (w *WatchedCommand) watch() {
+	if !cpuSampling {
+		hangDetectionOff.Do(func() {
+			LogWarn("当前系统无法读取子进程的CPU时间，不做卡死检测（-hang-timeout），只按总时间限制终止")
+		})
+		return
+	}
+
+	interval := HangTimeout / 10
+	if interval < time.Second {
+		interval = time.Second
+	} else if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pid := w.Process.Pid
+	lastCPU, _ := processTreeCPU(pid)
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+		if cpu, ok := processTreeCPU(pid); ok && cpu != lastCPU {
+			lastCPU = cpu
+			w.touch()
+		}
+		if time.Since(time.Unix(0, w.activity.Load())) >= HangTimeout {
+			err := &ProcessTimeoutError{Name: w.name, Limit: HangTimeout, Hang: true}
+			LogWarn("%v，终止进程 %d", err, pid)
+			w.cancel(err)
+			return
+		}
+	}
+}
+
+// trackedReader 读到数据时记录进展
+type trackedReader struct {
+	reader io.Reader
+	touch  func()
+}
+
+// Read 从输出管道读取
+func (t *trackedReader) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	if n > 0 {
+		t.touch()
+	}
+	return n, err
+}
+
+// trackedWriter 写入数据时记录进展
+type trackedWriter struct {
+	writer io.Writer
+	touch  func()
+}
+
+// Write 写入输出目标
+func (t *trackedWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		t.touch()
+	}
+	return t.writer.Write(p)
+}
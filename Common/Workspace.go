@@ -0,0 +1,60 @@
+package Common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// unsafeLabelChars 工作目录名中不允许出现的字符
+var unsafeLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// GetWorkspaceRoot 获取工作目录根路径，未指定-workspace时使用用户缓存目录
+func GetWorkspaceRoot() (string, error) {
+	if WorkspaceDir != "" {
+		return filepath.Abs(WorkspaceDir)
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		// 无法获取缓存目录时退回系统临时目录
+		cacheDir = os.TempDir()
+	}
+	return filepath.Join(cacheDir, "codeql_n1ght", "workspace"), nil
+}
+
+// NewRunWorkspace 为一次建库创建唯一的工作目录
+func NewRunWorkspace(label string) (string, error) {
+	root, err := GetWorkspaceRoot()
+	if err != nil {
+		return "", fmt.Errorf("获取工作目录失败: %v", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", fmt.Errorf("创建工作目录失败: %v", err)
+	}
+
+	label = unsafeLabelChars.ReplaceAllString(label, "_")
+	prefix := fmt.Sprintf("%s-%s-", label, time.Now().Format("20060102-150405"))
+	dir, err := os.MkdirTemp(root, prefix)
+	if err != nil {
+		return "", fmt.Errorf("创建工作目录失败: %v", err)
+	}
+	return dir, nil
+}
+
+// DefaultDatabasePath 未指定-out时的数据库输出路径：当前目录下的databases/<制品名>
+func DefaultDatabasePath(artifact string) string {
+	base := filepath.Base(artifact)
+	name := base[:len(base)-len(filepath.Ext(base))]
+	if name == "" {
+		name = base
+	}
+	return filepath.Join("databases", name)
+}
+
+// IsCodeQLDatabase 判断目录是否为CodeQL数据库
+func IsCodeQLDatabase(path string) bool {
+	return FileExists(filepath.Join(path, "codeql-database.yml"))
+}
@@ -0,0 +1,241 @@
+package Database
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// BatchResult 单个制品的建库结果
+type BatchResult struct {
+	Name     string
+	Artifact string
+	Database string
+	Success  bool
+	Error    error
+	Duration time.Duration
+}
+
+// batchArtifactExts 批量模式下识别的制品扩展名
+var batchArtifactExts = map[string]bool{
+	".jar": true,
+	".war": true,
+	".ear": true,
+	".zip": true,
+}
+
+// RunBatch 为目录或清单文件中的每个制品分别创建数据库
+func RunBatch(ctx context.Context, source, outDir string) ([]BatchResult, error) {
+	artifacts, err := collectBatchArtifacts(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(artifacts) == 0 {
+		return nil, fmt.Errorf("未在 %s 中找到可处理的制品", source)
+	}
+
+	outDir, _ = filepath.Abs(outDir)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建批量输出目录失败: %v", err)
+	}
+
+	names := batchDatabaseNames(artifacts)
+
+	// 环境变量是进程级的，在启动并发任务前设置一次
+	Common.SetupEnvironment()
+	if err := checkDecompilerRuntime(); err != nil {
+		return nil, err
+	}
+
+	workers := Common.BatchWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	color.Green("批量模式：共 %d 个制品，%d 个并发任务，输出目录 %s", len(artifacts), workers, outDir)
+
+	type batchTask struct {
+		index    int
+		artifact string
+	}
+	tasks := make(chan batchTask, len(artifacts))
+	results := make([]BatchResult, len(artifacts))
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for task := range tasks {
+				name := names[task.index]
+				if Common.Interrupted(ctx) {
+					// 中断后不再开始新的制品
+					results[task.index] = BatchResult{Name: name, Artifact: task.artifact, Error: fmt.Errorf("已中断: %s", Common.InterruptReason(ctx))}
+					continue
+				}
+				fmt.Printf("[Batch %d] 开始处理 %s\n", workerID, name)
+				results[task.index] = buildBatchArtifact(ctx, task.artifact, name, outDir)
+				fmt.Printf("[Batch %d] 完成 %s\n", workerID, name)
+			}
+		}(i)
+	}
+
+	for i, artifact := range artifacts {
+		tasks <- batchTask{index: i, artifact: artifact}
+	}
+	close(tasks)
+	wg.Wait()
+
+	printBatchSummary(results)
+	return results, nil
+}
+
+// buildBatchArtifact 在独立的工作目录中为单个制品建库
+func buildBatchArtifact(ctx context.Context, artifact, name, outDir string) BatchResult {
+	startTime := time.Now()
+	result := BatchResult{
+		Name:     name,
+		Artifact: artifact,
+		Database: filepath.Join(outDir, name),
+	}
+
+	// 每个制品使用各自的工作目录，互不干扰
+	workDir, err := Common.NewRunWorkspace(name)
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
+	err = Common.SafeExecute(func() error {
+		return Build(ctx, artifact, workDir, result.Database)
+	}, fmt.Sprintf("制品 %s 建库失败", name))
+
+	// Build成功或返回错误时已清理工作目录，这里兜底处理panic等未清理的情况
+	if !Common.KeepTempFiles {
+		Common.RemoveFile(workDir)
+	}
+
+	result.Duration = time.Since(startTime)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+// collectBatchArtifacts 收集制品列表：目录下的jar/war/ear/zip，或清单文件中每行一个路径
+func collectBatchArtifacts(source string) ([]string, error) {
+	if Common.IsDirectory(source) {
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			return nil, fmt.Errorf("读取目录失败: %v", err)
+		}
+		var artifacts []string
+		for _, entry := range entries {
+			if entry.IsDir() || !batchArtifactExts[strings.ToLower(filepath.Ext(entry.Name()))] {
+				continue
+			}
+			path, _ := filepath.Abs(filepath.Join(source, entry.Name()))
+			artifacts = append(artifacts, path)
+		}
+		return artifacts, nil
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("打开清单文件失败: %v", err)
+	}
+	defer file.Close()
+
+	// 清单中的相对路径以清单文件所在目录为基准，#开头为注释
+	baseDir := filepath.Dir(source)
+	var artifacts []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(baseDir, line)
+		}
+		if !Common.FileExists(line) {
+			color.Yellow("清单中的制品不存在，已跳过: %s", line)
+			continue
+		}
+		path, _ := filepath.Abs(line)
+		artifacts = append(artifacts, path)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取清单文件失败: %v", err)
+	}
+	return artifacts, nil
+}
+
+// batchDatabaseNames 按制品文件名生成数据库目录名，重名时追加序号，序号跳过其他制品已使用的名称
+func batchDatabaseNames(artifacts []string) []string {
+	names := make([]string, len(artifacts))
+	bases := make([]string, len(artifacts))
+	used := make(map[string]bool)
+
+	// 每个文件名的第一个制品直接使用原名
+	for i, artifact := range artifacts {
+		base := filepath.Base(artifact)
+		bases[i] = strings.TrimSuffix(base, filepath.Ext(base))
+		if !used[bases[i]] {
+			used[bases[i]] = true
+			names[i] = bases[i]
+		}
+	}
+
+	// 重名的制品追加序号，避开所有已占用的名称（如真实存在的app_2.jar）
+	for i, base := range bases {
+		if names[i] != "" {
+			continue
+		}
+		name := base
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// printBatchSummary 打印批量建库结果表
+func printBatchSummary(results []BatchResult) {
+	successCount := 0
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	Common.LogInfo("批量建库总结:")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "制品\t状态\t耗时\t数据库/错误")
+	for _, result := range results {
+		status := "成功"
+		detail := result.Database
+		if result.Success {
+			successCount++
+		} else {
+			status = "失败"
+			detail = fmt.Sprintf("%v", result.Error)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", result.Name, status, result.Duration.Round(time.Second), detail)
+	}
+	w.Flush()
+
+	color.Green("成功: %d", successCount)
+	color.Red("失败: %d", len(results)-successCount)
+	fmt.Println(strings.Repeat("=", 60))
+}
@@ -1,54 +1,62 @@
 package Database
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"codeql_n1ght/Common"
 )
 
-// Createdatabase 创建CodeQL数据库
-func Createdatabase(location string) {
-	Common.SetupEnvironment()
-	cmd := exec.Command(
+// Createdatabase 创建CodeQL数据库，受-create-timeout和卡死检测限制，环境变量由Build的调用方设置
+func Createdatabase(ctx context.Context, location string) error {
+	cmd := Common.WatchCommand(ctx, Common.CreateTimeout,
 		"codeql",
 		"database", "create", "temp",
 		"--language=java",
 		"--command=ant -f build.xml",
 		"--source-root", "./",
 		"--overwrite",
-		"--ram=51200",
+		"--ram="+strconv.Itoa(Common.RAMLimitMB),
 		"--threads="+strconv.Itoa(Common.CodeQLThreads),
 	)
 	cmd.Dir = location
+	if Common.IncludeResources {
+		// 让Java提取器索引全部XML和properties文件，而不只是默认的少数几类
+		cmd.Env = append(os.Environ(), "LGTM_INDEX_XML_MODE=all", "LGTM_INDEX_PROPERTIES_FILES=true")
+	}
 	// 获取标准输出管道
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		fmt.Println("获取 StdoutPipe 失败:", err)
-		return
+		return fmt.Errorf("获取 StdoutPipe 失败: %v", err)
 	}
 	// 获取标准错误管道（可选）
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		fmt.Println("获取 StderrPipe 失败:", err)
-		return
+		return fmt.Errorf("获取 StderrPipe 失败: %v", err)
 	}
 	// 启动命令
 	if err := cmd.Start(); err != nil {
-		fmt.Println("启动命令失败:", err)
-		return
+		return fmt.Errorf("启动命令失败: %v", err)
 	}
 	// 创建协程并发读取标准输出
-	go streamOutput(stdout, "STDOUT")
+	go streamOutput(cmd.TrackOutput(stdout), "STDOUT")
 	// 创建协程并发读取标准错误
-	go streamOutput(stderr, "STDERR")
+	go streamOutput(cmd.TrackOutput(stderr), "STDERR")
 	// 等待命令结束
 	if err := cmd.Wait(); err != nil {
-		fmt.Println("命令执行异常:", err)
+		if Common.Interrupted(ctx) {
+			return context.Cause(ctx)
+		}
+		if Common.IsTimeout(err) {
+			return err
+		}
+		return fmt.Errorf("命令执行异常: %v", err)
 	}
+	return nil
 }
 
 // GenerateBuildXML 生成Ant构建文件
@@ -58,6 +66,7 @@ func GenerateBuildXML(location string) error {
   <property name="src.dir" value="src1"/>
   <property name="web.dir" value="default"/>
   <property name="build.dir" value="build_classes"/>
+  <property name="lib.dir" value="lib"/>
   <property name="tomcat.dir" value="%s"/>
   <path id="master-classpath">
     <pathelement path="${tomcat.dir}/lib"/>
@@ -67,18 +76,21 @@ func GenerateBuildXML(location string) error {
     <fileset dir="${tomcat.dir}/bin">
       <include name="*.jar"/>
     </fileset>
+    <fileset dir="${lib.dir}" erroronmissingdir="false">
+      <include name="**/*.jar"/>
+    </fileset>
   </path>
   <target name="build" description="Compile source tree java files">
     <mkdir dir="${build.dir}"/>
-    <javac destdir="${build.dir}" source="8" target="8" fork="true" optimize="off" debug="on" failonerror="false">
+    <javac destdir="${build.dir}" %s>
       <src path="${src.dir}"/>
       <classpath refid="master-classpath"/>
-    </javac>
+    </javac>%s
   </target>
 </project>
-`, os.Getenv("CATALINA_HOME"))
+`, os.Getenv("CATALINA_HOME"), javacTaskOptions(), duplicateRootTargets(location))
 
-	f, err := os.OpenFile(filepath.Join(location, "build.xml"), os.O_CREATE|os.O_WRONLY, 0644)
+	f, err := os.OpenFile(filepath.Join(location, "build.xml"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return fmt.Errorf("create build.xml failed: %v", err)
 	}
@@ -87,3 +99,26 @@ func GenerateBuildXML(location string) error {
 	_, err = f.Write([]byte(buildxml))
 	return err
 }
+
+// duplicateRootTargets 为separate策略生成的每个独立源码根目录（src-dup/<jar名>）生成单独的javac任务，
+// 避免与src1中的同名类一起编译时报重复类错误
+func duplicateRootTargets(location string) string {
+	roots, _ := filepath.Glob(filepath.Join(location, duplicateRootsDir, "*"))
+	var targets strings.Builder
+	for _, root := range roots {
+		if !Common.IsDirectory(root) {
+			continue
+		}
+		rel := filepath.ToSlash(filepath.Join(duplicateRootsDir, filepath.Base(root)))
+		fmt.Fprintf(&targets, `
+    <mkdir dir="${build.dir}_dup/%[2]s"/>
+    <javac destdir="${build.dir}_dup/%[2]s" %[3]s>
+      <src path="%[1]s"/>
+      <classpath>
+        <path refid="master-classpath"/>
+        <pathelement location="${build.dir}"/>
+      </classpath>
+    </javac>`, rel, filepath.Base(root), javacTaskOptions())
+	}
+	return targets.String()
+}
@@ -0,0 +1,432 @@
+package Database
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// 数据库包中的条目
+const (
+	bundleManifestName = "n1ght-bundle.json" // 包清单，最后写入
+	bundleDatabaseName = "database.zip"      // codeql database bundle 生成的数据库包
+	bundleArtifactDir  = "artifact"          // 原始制品
+	artifactMarkerName = ".n1ght-artifact"   // 写在 <db>.artifact/ 中，标记目录由导入生成，可以覆盖
+	bundleFormat       = 1
+)
+
+// bundleSidecars 数据库旁的附属文件（SBOM、漏洞报告），包内以 database<后缀> 保存
+var bundleSidecars = []string{".cdx.json", ".spdx.json", ".vulns.json"}
+
+// BundleManifest 数据库包的清单
+type BundleManifest struct {
+	Format    int               `json:"format"`
+	Database  string            `json:"database"`           // 数据库名称，导入时的默认目录名
+	CreatedAt time.Time         `json:"createdAt"`          // 打包时间
+	Artifact  string            `json:"artifact,omitempty"` // 包含原始制品时为制品在包中的路径
+	Sources   bool              `json:"sources"`            // 是否包含未压缩的反编译源码
+	Files     map[string]string `json:"files"`              // 条目 -> SHA-256
+}
+
+// bundleWriter 写入数据库包并记录每个条目的摘要
+type bundleWriter struct {
+	w      *zip.Writer
+	hashes map[string]string
+}
+
+// addFile 将文件写入包中的name条目
+func (b *bundleWriter) addFile(name, file string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := b.create(name)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, h), src); err != nil {
+		return err
+	}
+	b.hashes[name] = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// create 创建带修改时间的压缩条目
+func (b *bundleWriter) create(name string) (io.Writer, error) {
+	return b.w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+}
+
+// addTree 将目录下的所有文件按相对路径写入包中的prefix目录
+func (b *bundleWriter) addTree(prefix, dir string) error {
+	return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		return b.addFile(path.Join(prefix, filepath.ToSlash(rel)), file)
+	})
+}
+
+// BundleDatabase 用codeql database bundle打包数据库，并加入建库清单、源码来源、SBOM，可选加入原始制品和未压缩的反编译源码
+func BundleDatabase(ctx context.Context, dbPath, output string, includeArtifact, includeSources bool) error {
+	dbPath, _ = filepath.Abs(dbPath)
+	if !Common.IsCodeQLDatabase(dbPath) {
+		return fmt.Errorf("不是CodeQL数据库: %s", dbPath)
+	}
+	name := filepath.Base(dbPath)
+	if output == "" {
+		output = dbPath + ".n1ght.zip"
+	}
+	output, _ = filepath.Abs(output)
+
+	info, err := Common.LoadBuildInfo(dbPath)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		color.Yellow("数据库中没有%s，包中将不包含建库清单", Common.MetadataFileName)
+	}
+	var artifact *Common.ArtifactInfo
+	if includeArtifact {
+		if info == nil || info.Artifact == nil {
+			return fmt.Errorf("建库清单中没有记录原始制品，无法打包制品")
+		}
+		artifact = info.Artifact
+		if err := verifyArtifact(artifact.Path, artifact); err != nil {
+			return err
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "n1ght-bundle-")
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	Common.SetupEnvironment()
+	databaseZip := filepath.Join(tmpDir, bundleDatabaseName)
+	args := []string{"database", "bundle", "--output=" + databaseZip, "--name=" + name}
+	if includeSources {
+		args = append(args, "--include-uncompressed-source")
+	}
+	color.Green("打包数据库: %s", dbPath)
+	if result, err := Common.CommandContext(ctx, "codeql", append(args, dbPath)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("codeql database bundle失败: %v\n%s", err, result)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("创建数据库包失败: %v", err)
+	}
+	defer out.Close()
+	b := &bundleWriter{w: zip.NewWriter(out), hashes: make(map[string]string)}
+
+	if err := b.addFile(bundleDatabaseName, databaseZip); err != nil {
+		return fmt.Errorf("写入数据库失败: %v", err)
+	}
+	for _, file := range []string{Common.MetadataFileName, Common.OriginsFileName} {
+		if Common.FileExists(filepath.Join(dbPath, file)) {
+			if err := b.addFile(file, filepath.Join(dbPath, file)); err != nil {
+				return fmt.Errorf("写入%s失败: %v", file, err)
+			}
+		}
+	}
+	for _, suffix := range bundleSidecars {
+		if Common.FileExists(dbPath + suffix) {
+			if err := b.addFile("database"+suffix, dbPath+suffix); err != nil {
+				return fmt.Errorf("写入%s失败: %v", filepath.Base(dbPath+suffix), err)
+			}
+		}
+	}
+
+	manifest := BundleManifest{
+		Format:    bundleFormat,
+		Database:  name,
+		CreatedAt: time.Now(),
+		Sources:   includeSources,
+	}
+	if artifact != nil {
+		manifest.Artifact = path.Join(bundleArtifactDir, artifact.Name)
+		if artifact.Directory {
+			err = b.addTree(manifest.Artifact, artifact.Path)
+		} else {
+			err = b.addFile(manifest.Artifact, artifact.Path)
+		}
+		if err != nil {
+			return fmt.Errorf("写入原始制品失败: %v", err)
+		}
+	}
+	manifest.Files = b.hashes
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := b.create(bundleManifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := b.w.Close(); err != nil {
+		return fmt.Errorf("写入数据库包失败: %v", err)
+	}
+	color.Green("数据库包已生成: %s（%d 个条目）", output, len(manifest.Files)+1)
+	return nil
+}
+
+// ImportBundle 校验并解开数据库包，数据库输出到dest（为空时为 ./databases/<数据库名>），返回数据库路径；
+// 原始制品解压到 <db>.artifact/ 下
+func ImportBundle(ctx context.Context, bundlePath, dest string) (string, error) {
+	r, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return "", fmt.Errorf("打开数据库包失败: %v", err)
+	}
+	defer r.Close()
+
+	manifest, entries, err := readBundleManifest(&r.Reader)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyBundleEntries(manifest, entries); err != nil {
+		return "", err
+	}
+	color.Green("数据库包校验通过: %d 个条目", len(manifest.Files))
+
+	if dest == "" {
+		dest = Common.DefaultDatabasePath(manifest.Database)
+	}
+	dest, _ = filepath.Abs(dest)
+	if err := checkDatabaseOutput(dest); err != nil {
+		return "", err
+	}
+	if manifest.Artifact != "" {
+		if err := checkArtifactOutput(dest + ".artifact"); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	// 在输出目录旁解包，完成后改名，失败时不会留下不完整的数据库
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dest), ".n1ght-import-")
+	if err != nil {
+		return "", fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	databaseZip := filepath.Join(tmpDir, bundleDatabaseName)
+	if err := extractZipEntry(entries[bundleDatabaseName], databaseZip); err != nil {
+		return "", fmt.Errorf("解压数据库失败: %v", err)
+	}
+	Common.SetupEnvironment()
+	unpacked := filepath.Join(tmpDir, "db")
+	cmd := Common.CommandContext(ctx, "codeql", "database", "unbundle", databaseZip, "--target="+unpacked, "--name="+manifest.Database)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("codeql database unbundle失败: %v\n%s", err, output)
+	}
+	dbDir := filepath.Join(unpacked, manifest.Database)
+	if !Common.IsCodeQLDatabase(dbDir) {
+		return "", fmt.Errorf("数据库包中的数据库不完整: 缺少codeql-database.yml")
+	}
+	for _, file := range []string{Common.MetadataFileName, Common.OriginsFileName} {
+		if f, ok := entries[file]; ok {
+			if err := extractZipEntry(f, filepath.Join(dbDir, file)); err != nil {
+				return "", fmt.Errorf("解压%s失败: %v", file, err)
+			}
+		}
+	}
+
+	if Common.IsCodeQLDatabase(dest) {
+		Common.RemoveFile(dest)
+	}
+	if err := os.Rename(dbDir, dest); err != nil {
+		return "", fmt.Errorf("移动数据库失败: %v", err)
+	}
+	for _, suffix := range bundleSidecars {
+		if f, ok := entries["database"+suffix]; ok {
+			if err := extractZipEntry(f, dest+suffix); err != nil {
+				color.Red("解压%s失败: %v", filepath.Base(dest+suffix), err)
+			}
+		}
+	}
+
+	info, err := Common.LoadBuildInfo(dest)
+	if err != nil {
+		color.Red("读取建库清单失败: %v", err)
+	}
+	if manifest.Artifact != "" {
+		if err := importBundleArtifact(manifest, entries, dest, info); err != nil {
+			return "", err
+		}
+	}
+	if info != nil && info.Artifact != nil {
+		color.Green("数据库制品: %s (sha256 %s)，建库时间 %s", info.Artifact.Name, info.Artifact.SHA256,
+			info.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	color.Green("数据库已导入: %s", dest)
+	return dest, nil
+}
+
+// readBundleManifest 读取包清单，并按名称索引包中的条目
+func readBundleManifest(r *zip.Reader) (*BundleManifest, map[string]*zip.File, error) {
+	entries := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		// 拒绝绝对路径和跳出解压目录的条目
+		if path.IsAbs(f.Name) || strings.Contains(f.Name, `\`) || path.Clean(f.Name) != f.Name || strings.HasPrefix(f.Name, "../") {
+			return nil, nil, fmt.Errorf("数据库包中有非法路径: %s", f.Name)
+		}
+		entries[f.Name] = f
+	}
+	f, ok := entries[bundleManifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("不是数据库包: 缺少%s", bundleManifestName)
+	}
+	data, err := readZipEntry(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest := &BundleManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, nil, fmt.Errorf("解析%s失败: %v", bundleManifestName, err)
+	}
+	if manifest.Format > bundleFormat {
+		return nil, nil, fmt.Errorf("数据库包格式版本 %d 高于当前支持的版本 %d，请升级工具", manifest.Format, bundleFormat)
+	}
+	if manifest.Database == "" || strings.ContainsAny(manifest.Database, `/\`) || manifest.Database == ".." {
+		return nil, nil, fmt.Errorf("数据库包中的数据库名称非法: %q", manifest.Database)
+	}
+	delete(entries, bundleManifestName)
+	return manifest, entries, nil
+}
+
+// verifyBundleEntries 校验包中的条目与清单一致：不缺少、没有多余条目、SHA-256相同
+func verifyBundleEntries(manifest *BundleManifest, entries map[string]*zip.File) error {
+	if _, ok := manifest.Files[bundleDatabaseName]; !ok {
+		return fmt.Errorf("数据库包中没有%s", bundleDatabaseName)
+	}
+	var problems []string
+	for name := range entries {
+		if _, ok := manifest.Files[name]; !ok {
+			problems = append(problems, "清单中没有记录: "+name)
+		}
+	}
+	for name, expected := range manifest.Files {
+		f, ok := entries[name]
+		if !ok {
+			problems = append(problems, "缺少条目: "+name)
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("无法读取 %s: %v", name, err))
+			continue
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil || hex.EncodeToString(h.Sum(nil)) != expected {
+			problems = append(problems, "摘要不一致: "+name)
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("数据库包校验失败:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// checkArtifactOutput 原始制品目录已存在时，只有带导入标记（之前导入的制品）才允许覆盖
+func checkArtifactOutput(artifactDir string) error {
+	if !Common.FileExists(artifactDir) {
+		return nil
+	}
+	if !Common.FileExists(filepath.Join(artifactDir, artifactMarkerName)) {
+		return fmt.Errorf("原始制品路径已存在且不是之前导入的制品，拒绝覆盖: %s", artifactDir)
+	}
+	color.Yellow("将覆盖之前导入的原始制品: %s", artifactDir)
+	return nil
+}
+
+// importBundleArtifact 将包中的原始制品解压到 <db>.artifact/，并与建库清单中的摘要比对
+func importBundleArtifact(manifest *BundleManifest, entries map[string]*zip.File, dbPath string, info *Common.BuildInfo) error {
+	artifactDir := dbPath + ".artifact"
+	if err := checkArtifactOutput(artifactDir); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(artifactDir); err != nil {
+		return fmt.Errorf("删除之前导入的原始制品失败: %v", err)
+	}
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		return fmt.Errorf("创建原始制品目录失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(artifactDir, artifactMarkerName), []byte(manifest.Database+"\n"), 0644); err != nil {
+		return fmt.Errorf("写入导入标记失败: %v", err)
+	}
+	prefix := manifest.Artifact + "/"
+	for name, f := range entries {
+		if name != manifest.Artifact && !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(name, bundleArtifactDir+"/")
+		if err := extractZipEntry(f, filepath.Join(artifactDir, filepath.FromSlash(rel))); err != nil {
+			return fmt.Errorf("解压原始制品失败: %v", err)
+		}
+	}
+
+	artifactPath := filepath.Join(artifactDir, path.Base(manifest.Artifact))
+	if info == nil || info.Artifact == nil {
+		color.Yellow("建库清单中没有制品摘要，跳过原始制品校验")
+		return nil
+	}
+	if err := verifyArtifact(artifactPath, info.Artifact); err != nil {
+		return err
+	}
+	color.Green("原始制品已解压到 %s，摘要与建库清单一致", artifactPath)
+	return nil
+}
+
+// verifyArtifact 检查制品的SHA-256与建库清单中记录的一致
+func verifyArtifact(artifactPath string, expected *Common.ArtifactInfo) error {
+	var sum string
+	var err error
+	if expected.Directory {
+		sum, _, err = directoryHash(artifactPath)
+	} else {
+		sum, _, err = fileHash(artifactPath)
+	}
+	if err != nil {
+		return fmt.Errorf("读取原始制品失败: %v", err)
+	}
+	if sum != expected.SHA256 {
+		return fmt.Errorf("原始制品 %s 的SHA-256与建库清单不一致（%s != %s）", artifactPath, sum, expected.SHA256)
+	}
+	return nil
+}
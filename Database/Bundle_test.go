@@ -0,0 +1,50 @@
+package Database
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportBundleArtifactKeepsForeignDirectory(t *testing.T) {
+	data := zipBytes(t, map[string][]byte{"artifact/app.jar": []byte("jar")})
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]*zip.File{}
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+	manifest := &BundleManifest{Database: "app", Artifact: "artifact/app.jar"}
+
+	dbPath := filepath.Join(t.TempDir(), "app")
+	artifactDir := dbPath + ".artifact"
+	foreign := filepath.Join(artifactDir, "notes.txt")
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(foreign, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 不是之前导入生成的目录，拒绝删除
+	if err := importBundleArtifact(manifest, entries, dbPath, nil); err == nil {
+		t.Fatal("foreign artifact directory was overwritten")
+	}
+	if _, err := os.Stat(foreign); err != nil {
+		t.Fatalf("foreign file removed: %v", err)
+	}
+
+	// 之前导入的目录带标记，可以重复导入
+	os.RemoveAll(artifactDir)
+	for i := 0; i < 2; i++ {
+		if err := importBundleArtifact(manifest, entries, dbPath, nil); err != nil {
+			t.Fatalf("import %d: %v", i+1, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(artifactDir, "app.jar")); err != nil || string(data) != "jar" {
+		t.Errorf("artifact = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,265 @@
+package Database
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// 操作数为常量池索引的指令
+var cpIndexOpcodes = map[byte]bool{
+	0x12: true, 0x13: true, 0x14: true, // ldc、ldc_w、ldc2_w
+	0xb2: true, 0xb3: true, 0xb4: true, 0xb5: true, // getstatic、putstatic、getfield、putfield
+	0xb6: true, 0xb7: true, 0xb8: true, 0xb9: true, 0xba: true, // invoke*
+	0xbb: true, 0xbd: true, 0xc0: true, 0xc1: true, 0xc5: true, // new、anewarray、checkcast、instanceof、multianewarray
+}
+
+// operandLength 返回定长指令的操作数字节数，tableswitch、lookupswitch和wide单独处理
+func operandLength(op byte) int {
+	switch {
+	case op == 0x10, op == 0x12, op >= 0x15 && op <= 0x19, op >= 0x36 && op <= 0x3a, op == 0xa9, op == 0xbc:
+		return 1
+	case op == 0x11, op == 0x13, op == 0x14, op == 0x84, op >= 0x99 && op <= 0xa8,
+		op >= 0xb2 && op <= 0xb8, op == 0xbb, op == 0xbd, op == 0xc0, op == 0xc1, op == 0xc6, op == 0xc7:
+		return 2
+	case op == 0xc5:
+		return 3
+	case op == 0xb9, op == 0xba, op == 0xc8, op == 0xc9:
+		return 4
+	}
+	return 0
+}
+
+// maxBootstrapDepth 引导方法参数中嵌套动态常量的最大展开层数，防止构造的class文件循环引用
+const maxBootstrapDepth = 4
+
+// constantText 返回常量池条目的符号化内容，与条目在常量池中的位置无关
+func (cf *classFile) constantText(i uint16) string {
+	return cf.symbolText(i, 0)
+}
+
+func (cf *classFile) symbolText(i uint16, depth int) string {
+	if int(i) >= len(cf.pool) || cf.pool[i] == nil {
+		return fmt.Sprintf("#%d", i)
+	}
+	e := cf.pool[i]
+	switch e.tag {
+	case cpUtf8:
+		return e.utf8
+	case cpInteger, cpFloat, cpLong, cpDouble:
+		return hex.EncodeToString(e.raw)
+	case cpClass:
+		return cf.classAt(i)
+	case cpString:
+		return `"` + cf.utf8At(e.a)
+	case cpMethodType:
+		return cf.utf8At(e.a)
+	case cpFieldref, cpMethodref, cpInterfaceMethodref:
+		return cf.classAt(e.a) + "." + cf.symbolText(e.b, depth)
+	case cpNameAndType:
+		return cf.utf8At(e.a) + ":" + cf.utf8At(e.b)
+	case cpMethodHandle:
+		return fmt.Sprintf("%d/%s", e.kind, cf.symbolText(e.a, depth))
+	case cpDynamic, cpInvokeDynamic:
+		return cf.bootstrapText(e.a, depth) + "/" + cf.symbolText(e.b, depth)
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+// bootstrapText 返回BootstrapMethods中第index个引导方法的方法句柄和静态参数，
+// 只改动其他lambda使引导方法的序号变化时内容不变；无法解析时退回序号
+func (cf *classFile) bootstrapText(index uint16, depth int) string {
+	if depth < maxBootstrapDepth {
+		if handle, args, ok := cf.bootstrapMethod(index); ok {
+			texts := make([]string, len(args))
+			for i, arg := range args {
+				texts[i] = cf.symbolText(arg, depth+1)
+			}
+			return "bsm{" + cf.symbolText(handle, depth+1) + "(" + strings.Join(texts, ",") + ")}"
+		}
+	}
+	return fmt.Sprintf("bsm%d", index)
+}
+
+// bootstrapMethod 读取BootstrapMethods属性中第index个引导方法的方法句柄和静态参数
+func (cf *classFile) bootstrapMethod(index uint16) (uint16, []uint16, bool) {
+	data := cf.attribute(cf.attrs, "BootstrapMethods")
+	if data == nil {
+		return 0, nil, false
+	}
+	r := &classReader{data: data}
+	count := r.u2()
+	for i := uint16(0); i < count && r.err == nil; i++ {
+		handle, n := r.u2(), r.u2()
+		args := make([]uint16, 0, n)
+		for j := uint16(0); j < n && r.err == nil; j++ {
+			args = append(args, r.u2())
+		}
+		if i == index {
+			return handle, args, r.err == nil
+		}
+	}
+	return 0, nil, false
+}
+
+// attribute 返回成员或类中指定名称的属性
+func (cf *classFile) attribute(attrs []classAttribute, name string) []byte {
+	for _, a := range attrs {
+		if cf.utf8At(a.name) == name {
+			return a.data
+		}
+	}
+	return nil
+}
+
+// methodFingerprint 计算方法的摘要：访问标志、异常表和字节码，常量池索引替换为符号化内容，不包含行号表等调试信息，
+// 只改动其他方法或行号偏移时摘要不变
+func (cf *classFile) methodFingerprint(m classMember) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%x\n", m.access)
+	code := cf.attribute(m.attrs, "Code")
+	if len(code) < 8 {
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	length := int(binary.BigEndian.Uint32(code[4:8]))
+	if 8+length > len(code) {
+		h.Write(code)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	cf.writeInstructions(h, code[8:8+length])
+
+	// 异常表：start、end、handler、catch_type
+	rest := code[8+length:]
+	if len(rest) >= 2 {
+		count := int(binary.BigEndian.Uint16(rest))
+		for i := 0; i < count && 2+i*8+8 <= len(rest); i++ {
+			e := rest[2+i*8:]
+			fmt.Fprintf(h, "catch %x %s\n", e[:6], cf.constantText(binary.BigEndian.Uint16(e[6:8])))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeInstructions 逐条写入指令，常量池索引写成符号化内容
+func (cf *classFile) writeInstructions(h hash.Hash, code []byte) {
+	for pc := 0; pc < len(code); {
+		op := code[pc]
+		switch op {
+		case 0xaa, 0xab: // tableswitch、lookupswitch：4字节对齐后是跳转表
+			start := pc + 1 + (4-(pc+1)%4)%4
+			if start+12 > len(code) {
+				h.Write(code[pc:])
+				return
+			}
+			n := 0
+			if op == 0xaa {
+				low := int32(binary.BigEndian.Uint32(code[start+4:]))
+				high := int32(binary.BigEndian.Uint32(code[start+8:]))
+				n = 12 + int(high-low+1)*4
+			} else {
+				n = 8 + int(binary.BigEndian.Uint32(code[start+4:]))*8
+			}
+			if n < 0 || start+n > len(code) {
+				h.Write(code[pc:])
+				return
+			}
+			fmt.Fprintf(h, "%02x %x\n", op, code[start:start+n])
+			pc = start + n
+			continue
+		case 0xc4: // wide
+			n := 4
+			if pc+1 < len(code) && code[pc+1] == 0x84 {
+				n = 6
+			}
+			if pc+n > len(code) {
+				h.Write(code[pc:])
+				return
+			}
+			fmt.Fprintf(h, "%x\n", code[pc:pc+n])
+			pc += n
+			continue
+		}
+
+		n := operandLength(op)
+		if pc+1+n > len(code) {
+			h.Write(code[pc:])
+			return
+		}
+		operands := code[pc+1 : pc+1+n]
+		switch {
+		case op == 0x12:
+			// ldc和ldc_w只是索引宽度不同
+			fmt.Fprintf(h, "ldc %s\n", cf.constantText(uint16(operands[0])))
+		case op == 0x13:
+			fmt.Fprintf(h, "ldc %s\n", cf.constantText(binary.BigEndian.Uint16(operands)))
+		case cpIndexOpcodes[op]:
+			fmt.Fprintf(h, "%02x %s %x\n", op, cf.constantText(binary.BigEndian.Uint16(operands)), operands[2:])
+		default:
+			fmt.Fprintf(h, "%02x %x\n", op, operands)
+		}
+		pc += 1 + n
+	}
+}
+
+// methodLines 返回方法LineNumberTable中的最小和最大原始行号，没有行号表时返回0
+func (cf *classFile) methodLines(m classMember) (int, int) {
+	code := cf.attribute(m.attrs, "Code")
+	if len(code) < 8 {
+		return 0, 0
+	}
+	length := int(binary.BigEndian.Uint32(code[4:8]))
+	pos := 8 + length
+	if pos+2 > len(code) {
+		return 0, 0
+	}
+	pos += 2 + int(binary.BigEndian.Uint16(code[pos:]))*8
+	if pos+2 > len(code) {
+		return 0, 0
+	}
+	attrs := readAttributes(&classReader{data: code, pos: pos})
+
+	first, last := 0, 0
+	for _, a := range attrs {
+		if cf.utf8At(a.name) != "LineNumberTable" || len(a.data) < 2 {
+			continue
+		}
+		count := int(binary.BigEndian.Uint16(a.data))
+		for i := 0; i < count && 2+i*4+4 <= len(a.data); i++ {
+			line := int(binary.BigEndian.Uint16(a.data[2+i*4+2:]))
+			if first == 0 || line < first {
+				first = line
+			}
+			if line > last {
+				last = line
+			}
+		}
+	}
+	return first, last
+}
+
+// structureFingerprint 计算方法以外的类结构摘要：访问标志、父类、接口和字段（含常量值）
+func (cf *classFile) structureFingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%x %s\n", cf.access, cf.superName())
+	interfaces := cf.interfaceNames()
+	sort.Strings(interfaces)
+	fmt.Fprintln(h, strings.Join(interfaces, ","))
+
+	fields := make([]string, 0, len(cf.fields))
+	for _, f := range cf.fields {
+		value := ""
+		if data := cf.attribute(f.attrs, "ConstantValue"); len(data) == 2 {
+			value = cf.constantText(binary.BigEndian.Uint16(data))
+		}
+		fields = append(fields, fmt.Sprintf("%x %s %s %s", f.access, cf.utf8At(f.name), cf.utf8At(f.desc), value))
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		fmt.Fprintln(h, f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,263 @@
+package Database
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// javacErrorLine javac输出中的错误行，如 /path/Foo.java:12: error: ...
+var javacErrorLine = regexp.MustCompile(`^(.+\.java):\d+: (?:error|错误): (.*)$`)
+
+// ignoredJavacErrors 缺少依赖导致的错误，与反编译质量无关
+var ignoredJavacErrors = []string{
+	"cannot find symbol",
+	"does not exist",
+	"cannot access",
+	"找不到符号",
+	"不存在",
+	"无法访问",
+}
+
+// countFailureMarkers 统计单个源码文件中生成它的反编译器留下的失败标记数量
+func countFailureMarkers(path string, d Decompiler) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, marker := range d.FailureMarkers() {
+		count += bytes.Count(data, []byte(marker))
+	}
+	return count
+}
+
+// listJavaFiles 列出目录下所有.java文件的相对路径
+func listJavaFiles(dir string) []string {
+	var files []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".java") {
+			return nil
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files
+}
+
+// javacDiagnostics 用javac检查反编译结果，返回每个文件的错误信息（忽略缺少依赖的错误），javac不可用时第二个返回值为false
+func javacDiagnostics(ctx context.Context, dir string, files []string, classpath string) (map[string][]string, bool) {
+	if len(files) == 0 {
+		return nil, false
+	}
+	if _, err := exec.LookPath("javac"); err != nil {
+		return nil, false
+	}
+
+	tmpDir, err := os.MkdirTemp("", "n1ght-javac-")
+	if err != nil {
+		return nil, false
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 文件较多时通过参数文件传递，避免命令行过长
+	var args bytes.Buffer
+	for _, file := range files {
+		args.WriteString("\"" + filepath.ToSlash(filepath.Join(dir, file)) + "\"\n")
+	}
+	argFile := filepath.Join(tmpDir, "sources.txt")
+	if err := os.WriteFile(argFile, args.Bytes(), 0644); err != nil {
+		return nil, false
+	}
+
+	cmd := Common.WatchCommand(ctx, 0, "javac",
+		"-J"+Common.JavaHeapArg(), "-nowarn", "-proc:none", "-encoding", "UTF-8", "-Xmaxerrs", "100000",
+		// 出现语法错误时继续做类型检查
+		"-XDshouldStopPolicyIfError=FLOW", "-XDshould-stop.ifError=FLOW",
+		"-cp", classpath, "-sourcepath", dir, "-d", filepath.Join(tmpDir, "out"),
+		"@"+argFile)
+	os.MkdirAll(filepath.Join(tmpDir, "out"), 0755)
+	output, _ := cmd.CombinedOutput()
+
+	diagnostics := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		match := javacErrorLine.FindStringSubmatch(scanner.Text())
+		if match == nil || isIgnoredJavacError(match[2]) {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, filepath.FromSlash(match[1])); err == nil {
+			diagnostics[rel] = append(diagnostics[rel], match[2])
+		}
+	}
+	return diagnostics, true
+}
+
+// isIgnoredJavacError 是否为缺少依赖导致的错误
+func isIgnoredJavacError(message string) bool {
+	for _, ignored := range ignoredJavacErrors {
+		if strings.Contains(message, ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreDecompiledFiles 为反编译器d输出的每个文件打分（失败标记数+javac错误数），分数越高质量越差
+func scoreDecompiledFiles(ctx context.Context, d Decompiler, dir string, files []string, classpath string) map[string]int {
+	scores := make(map[string]int, len(files))
+	for _, file := range files {
+		scores[file] = countFailureMarkers(filepath.Join(dir, file), d)
+	}
+	diagnostics, _ := javacDiagnostics(ctx, dir, files, classpath)
+	for file, messages := range diagnostics {
+		if _, ok := scores[file]; ok {
+			scores[file] += len(messages)
+		}
+	}
+	return scores
+}
+
+// repairFailedClasses 找出反编译失败的类，只用另一个反编译器重新反编译这些类，并按类保留得分更好的结果，class目录先打包成jar
+func repairFailedClasses(ctx context.Context, input, stageDir string, used Decompiler) {
+	err := withJarInput(input, stageDir, func(jarFile string) error {
+		repairFailedJarClasses(ctx, jarFile, stageDir, used)
+		return nil
+	})
+	if err != nil {
+		color.Red("准备逐类回退失败: %v", err)
+	}
+}
+
+// repairFailedJarClasses 对jar执行逐类回退
+func repairFailedJarClasses(ctx context.Context, jarFile, stageDir string, used Decompiler) {
+	files := listJavaFiles(stageDir)
+	scores := scoreDecompiledFiles(ctx, used, stageDir, files, jarFile)
+
+	var failed []string
+	for _, file := range files {
+		if scores[file] > 0 {
+			failed = append(failed, file)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	alt := fallbackDecompiler(used)
+	name := filepath.Base(jarFile)
+	color.Yellow("%s 中有 %d 个类反编译失败，使用%s重新反编译这些类", name, len(failed), alt.Name())
+
+	retryDir := stageDir + ".retry"
+	defer os.RemoveAll(retryDir)
+
+	// 把失败的类（含内部类）打成一个小jar交给另一个反编译器
+	retryJar := filepath.Join(retryDir, "retry-"+name)
+	if err := extractClassesToJar(jarFile, retryJar, failed); err != nil {
+		color.Red("提取失败的类出错: %v", err)
+		return
+	}
+	altDir := filepath.Join(retryDir, "src")
+	if err := alt.Decompile(ctx, retryJar, altDir); err != nil {
+		color.Red("%s重新反编译失败: %v", alt.Name(), err)
+		return
+	}
+
+	var altFiles []string
+	for _, file := range failed {
+		if _, err := os.Stat(filepath.Join(altDir, file)); err == nil {
+			altFiles = append(altFiles, file)
+		}
+	}
+	// 原jar在classpath上，重新反编译的类可以引用同一jar中的其他类
+	altScores := scoreDecompiledFiles(ctx, alt, altDir, altFiles, jarFile)
+
+	replaced := 0
+	for _, file := range altFiles {
+		if altScores[file] < scores[file] {
+			if err := copyFile(filepath.Join(altDir, file), filepath.Join(stageDir, file)); err == nil {
+				replaced++
+			}
+		}
+	}
+	color.Green("%s: %d/%d 个失败的类已替换为%s的结果", name, replaced, len(failed), alt.Name())
+}
+
+// extractClassesToJar 从jar中提取指定源码文件对应的class（含内部类）并写入新的jar
+func extractClassesToJar(jarFile, destJar string, sourceFiles []string) error {
+	prefixes := make(map[string]bool, len(sourceFiles))
+	for _, file := range sourceFiles {
+		prefixes[strings.TrimSuffix(filepath.ToSlash(file), ".java")] = true
+	}
+	return filterJarClasses(jarFile, destJar, func(className string) bool {
+		return prefixes[className]
+	})
+}
+
+// filterJarClasses 将jar中keep返回true的顶层类（含内部类）写入新的jar，keep的参数为不带.class的顶层类名
+func filterJarClasses(jarFile, destJar string, keep func(className string) bool) error {
+	r, err := zip.OpenReader(jarFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(filepath.Dir(destJar), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(destJar)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := zip.NewWriter(out)
+	defer w.Close()
+
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".class") {
+			continue
+		}
+		// Foo$Inner.class 属于 Foo.java
+		className := strings.TrimSuffix(f.Name, ".class")
+		if idx := strings.Index(path.Base(className), "$"); idx >= 0 {
+			className = className[:len(className)-len(path.Base(className))+idx]
+		}
+		if !keep(className) {
+			continue
+		}
+		if err := copyZipEntry(f, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyZipEntry 将zip条目复制到新的zip中
+func copyZipEntry(f *zip.File, w *zip.Writer) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	dst, err := w.Create(f.Name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, rc)
+	return err
+}
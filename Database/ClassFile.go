@@ -0,0 +1,777 @@
+package Database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// 常量池标签
+const (
+	cpUtf8               = 1
+	cpInteger            = 3
+	cpFloat              = 4
+	cpLong               = 5
+	cpDouble             = 6
+	cpClass              = 7
+	cpString             = 8
+	cpFieldref           = 9
+	cpMethodref          = 10
+	cpInterfaceMethodref = 11
+	cpNameAndType        = 12
+	cpMethodHandle       = 15
+	cpMethodType         = 16
+	cpDynamic            = 17
+	cpInvokeDynamic      = 18
+	cpModule             = 19
+	cpPackage            = 20
+)
+
+// maxConstantPool constant_pool_count为u2，常量池最多65535个槽位（含不使用的0号）；Utf8长度同样为u2
+const (
+	maxConstantPool = 0xFFFF
+	maxUtf8Length   = 0xFFFF
+)
+
+// 访问标志
+const (
+	accPrivate   = 0x0002
+	accStatic    = 0x0008
+	accBridge    = 0x0040
+	accSynthetic = 0x1000
+)
+
+// cpEntry 常量池条目，Utf8保存原始字节，其余类型保存引用的索引或原始数据
+type cpEntry struct {
+	tag  byte
+	utf8 string
+	a, b uint16 // Class/String/MethodType/Module/Package用a；Fieldref等和NameAndType用a、b
+	kind byte   // MethodHandle的reference_kind
+	raw  []byte // Integer/Float/Long/Double的原始数据
+}
+
+// classAttribute 未解析的属性
+type classAttribute struct {
+	name uint16
+	data []byte
+}
+
+// classMember 字段或方法
+type classMember struct {
+	access uint16
+	name   uint16
+	desc   uint16
+	attrs  []classAttribute
+}
+
+// classFile 解析后的class文件，只解析改名需要的部分，其余内容原样保留
+type classFile struct {
+	minor, major uint16
+	pool         []*cpEntry // 下标0不使用，Long/Double的第二个槽位为nil
+	access       uint16
+	this, super  uint16
+	interfaces   []uint16
+	fields       []classMember
+	methods      []classMember
+	attrs        []classAttribute
+}
+
+// classReader 按大端序读取class文件
+type classReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *classReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("class文件被截断")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *classReader) u1() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *classReader) u2() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *classReader) u4() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// parseClassFile 解析class文件
+func parseClassFile(data []byte) (*classFile, error) {
+	r := &classReader{data: data}
+	if r.u4() != 0xCAFEBABE {
+		return nil, fmt.Errorf("不是有效的class文件")
+	}
+	cf := &classFile{minor: r.u2(), major: r.u2()}
+
+	count := int(r.u2())
+	cf.pool = make([]*cpEntry, count)
+	for i := 1; i < count && r.err == nil; i++ {
+		e := &cpEntry{tag: r.u1()}
+		switch e.tag {
+		case cpUtf8:
+			e.utf8 = string(r.bytes(int(r.u2())))
+		case cpInteger, cpFloat:
+			e.raw = r.bytes(4)
+		case cpLong, cpDouble:
+			e.raw = r.bytes(8)
+		case cpClass, cpString, cpMethodType, cpModule, cpPackage:
+			e.a = r.u2()
+		case cpFieldref, cpMethodref, cpInterfaceMethodref, cpNameAndType, cpDynamic, cpInvokeDynamic:
+			e.a, e.b = r.u2(), r.u2()
+		case cpMethodHandle:
+			e.kind, e.a = r.u1(), r.u2()
+		default:
+			return nil, fmt.Errorf("未知的常量池标签: %d", e.tag)
+		}
+		cf.pool[i] = e
+		if e.tag == cpLong || e.tag == cpDouble {
+			i++
+		}
+	}
+
+	cf.access, cf.this, cf.super = r.u2(), r.u2(), r.u2()
+	cf.interfaces = make([]uint16, r.u2())
+	for i := range cf.interfaces {
+		cf.interfaces[i] = r.u2()
+	}
+	cf.fields = readMembers(r)
+	cf.methods = readMembers(r)
+	cf.attrs = readAttributes(r)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return cf, nil
+}
+
+func readMembers(r *classReader) []classMember {
+	members := make([]classMember, r.u2())
+	for i := range members {
+		members[i] = classMember{access: r.u2(), name: r.u2(), desc: r.u2(), attrs: readAttributes(r)}
+	}
+	return members
+}
+
+func readAttributes(r *classReader) []classAttribute {
+	attrs := make([]classAttribute, r.u2())
+	for i := range attrs {
+		attrs[i].name = r.u2()
+		attrs[i].data = r.bytes(int(r.u4()))
+	}
+	return attrs
+}
+
+// bytes 序列化class文件
+func (cf *classFile) bytes() []byte {
+	var buf bytes.Buffer
+	w := func(v interface{}) { binary.Write(&buf, binary.BigEndian, v) }
+
+	w(uint32(0xCAFEBABE))
+	w(cf.minor)
+	w(cf.major)
+	w(uint16(len(cf.pool)))
+	for _, e := range cf.pool[1:] {
+		if e == nil {
+			continue
+		}
+		buf.WriteByte(e.tag)
+		switch e.tag {
+		case cpUtf8:
+			w(uint16(len(e.utf8)))
+			buf.WriteString(e.utf8)
+		case cpInteger, cpFloat, cpLong, cpDouble:
+			buf.Write(e.raw)
+		case cpClass, cpString, cpMethodType, cpModule, cpPackage:
+			w(e.a)
+		case cpFieldref, cpMethodref, cpInterfaceMethodref, cpNameAndType, cpDynamic, cpInvokeDynamic:
+			w(e.a)
+			w(e.b)
+		case cpMethodHandle:
+			buf.WriteByte(e.kind)
+			w(e.a)
+		}
+	}
+
+	w(cf.access)
+	w(cf.this)
+	w(cf.super)
+	w(uint16(len(cf.interfaces)))
+	for _, i := range cf.interfaces {
+		w(i)
+	}
+	for _, members := range [][]classMember{cf.fields, cf.methods} {
+		w(uint16(len(members)))
+		for _, m := range members {
+			w(m.access)
+			w(m.name)
+			w(m.desc)
+			writeAttributes(&buf, m.attrs)
+		}
+	}
+	writeAttributes(&buf, cf.attrs)
+	return buf.Bytes()
+}
+
+func writeAttributes(buf *bytes.Buffer, attrs []classAttribute) {
+	binary.Write(buf, binary.BigEndian, uint16(len(attrs)))
+	for _, a := range attrs {
+		binary.Write(buf, binary.BigEndian, a.name)
+		binary.Write(buf, binary.BigEndian, uint32(len(a.data)))
+		buf.Write(a.data)
+	}
+}
+
+// utf8At 返回常量池中的Utf8字符串
+func (cf *classFile) utf8At(i uint16) string {
+	if int(i) < len(cf.pool) && cf.pool[i] != nil && cf.pool[i].tag == cpUtf8 {
+		return cf.pool[i].utf8
+	}
+	return ""
+}
+
+// classAt 返回常量池中Class条目的内部类名
+func (cf *classFile) classAt(i uint16) string {
+	if int(i) < len(cf.pool) && cf.pool[i] != nil && cf.pool[i].tag == cpClass {
+		return cf.utf8At(cf.pool[i].a)
+	}
+	return ""
+}
+
+// name 返回类的内部名称
+func (cf *classFile) name() string {
+	return cf.classAt(cf.this)
+}
+
+// superName 返回父类的内部名称
+func (cf *classFile) superName() string {
+	return cf.classAt(cf.super)
+}
+
+// interfaceNames 返回实现的接口
+func (cf *classFile) interfaceNames() []string {
+	names := make([]string, 0, len(cf.interfaces))
+	for _, i := range cf.interfaces {
+		names = append(names, cf.classAt(i))
+	}
+	return names
+}
+
+// memberRef 常量池中的字段或方法引用
+type memberRef struct {
+	Owner, Name, Desc string
+	Method            bool
+}
+
+// memberRefs 返回常量池中引用的字段和方法
+func (cf *classFile) memberRefs() []memberRef {
+	var refs []memberRef
+	for _, e := range cf.pool {
+		if e == nil || (e.tag != cpFieldref && e.tag != cpMethodref && e.tag != cpInterfaceMethodref) {
+			continue
+		}
+		nat := cf.pool[e.b]
+		if nat == nil || nat.tag != cpNameAndType {
+			continue
+		}
+		refs = append(refs, memberRef{
+			Owner:  cf.classAt(e.a),
+			Name:   cf.utf8At(nat.a),
+			Desc:   cf.utf8At(nat.b),
+			Method: e.tag != cpFieldref,
+		})
+	}
+	return refs
+}
+
+// stringConstants 返回字符串常量
+func (cf *classFile) stringConstants() []string {
+	var values []string
+	for _, e := range cf.pool {
+		if e != nil && e.tag == cpString {
+			values = append(values, cf.utf8At(e.a))
+		}
+	}
+	return values
+}
+
+// classRenamer 类和成员的改名规则，返回新名称以及是否需要改名
+type classRenamer interface {
+	renameClass(internal string) (string, bool)
+	renameMember(owner, name, desc string, method bool) (string, bool)
+}
+
+// remapClass 按改名规则改写class文件。被字符串常量共用的Utf8不会被修改，改为追加新条目并重新指向；
+// 追加后超出常量池或Utf8长度上限时返回错误，此时cf已部分改写，不能再使用
+func remapClass(cf *classFile, renamer classRenamer) error {
+	// 改名前的原始值
+	original := make([]string, len(cf.pool))
+	for i, e := range cf.pool {
+		if e != nil && e.tag == cpUtf8 {
+			original[i] = e.utf8
+		}
+	}
+	originalClasses := make(map[uint16]string)
+	for i, e := range cf.pool {
+		if e != nil && e.tag == cpClass {
+			originalClasses[uint16(i)] = original[e.a]
+		}
+	}
+	origClass := func(i uint16) string {
+		return originalClasses[i]
+	}
+
+	// 字符串常量和注解中的字符串可能与类名同值，只改写明确是描述符的Utf8
+	literals := cf.annotationStrings()
+	for _, e := range cf.pool {
+		if e != nil && e.tag == cpString {
+			literals[e.a] = true
+		}
+	}
+	classNames := make(map[uint16]bool)
+	for _, e := range cf.pool {
+		if e != nil && e.tag == cpClass {
+			classNames[e.a] = true
+		}
+	}
+
+	// 追加条目失败时记录第一个错误，返回0号索引，最后统一返回错误
+	className := cf.name()
+	var poolErr error
+	addEntry := func(e *cpEntry) uint16 {
+		if len(cf.pool) >= maxConstantPool {
+			if poolErr == nil {
+				poolErr = fmt.Errorf("改名后 %s 的常量池超过 %d 个条目", className, maxConstantPool-1)
+			}
+			return 0
+		}
+		cf.pool = append(cf.pool, e)
+		return uint16(len(cf.pool) - 1)
+	}
+	appended := make(map[string]uint16)
+	addUtf8 := func(s string) uint16 {
+		if i, ok := appended[s]; ok {
+			return i
+		}
+		if len(s) > maxUtf8Length {
+			if poolErr == nil {
+				poolErr = fmt.Errorf("改名后的字符串超过 %d 字节", maxUtf8Length)
+			}
+			return 0
+		}
+		i := addEntry(&cpEntry{tag: cpUtf8, utf8: s})
+		if i != 0 {
+			appended[s] = i
+		}
+		return i
+	}
+	natIndex := make(map[[2]uint16]uint16)
+	addNameAndType := func(name, desc uint16) uint16 {
+		key := [2]uint16{name, desc}
+		if i, ok := natIndex[key]; ok {
+			return i
+		}
+		i := addEntry(&cpEntry{tag: cpNameAndType, a: name, b: desc})
+		if i != 0 {
+			natIndex[key] = i
+		}
+		return i
+	}
+	// descIndex 返回改写后的描述符索引：普通Utf8已在第1步原地改写，与字符串常量或类名共用且未改写的追加新条目
+	descIndex := func(i uint16) uint16 {
+		mapped := remapSignature(original[i], renamer)
+		if mapped == original[i] || cf.pool[i].utf8 == mapped {
+			return i
+		}
+		if literals[i] || classNames[i] {
+			return addUtf8(mapped)
+		}
+		return i
+	}
+
+	// 1. 原地改写描述符和签名（Signature、LocalVariableTable等属性中的Utf8也在其中）。
+	// 数组类名本身就是描述符（如 [La/b;），javac会让数组类常量和同值的描述符共用一个Utf8，一并改写
+	count := len(cf.pool)
+	for i := 1; i < count; i++ {
+		e := cf.pool[i]
+		if e == nil || e.tag != cpUtf8 || literals[uint16(i)] || (classNames[uint16(i)] && !strings.HasPrefix(e.utf8, "[")) {
+			continue
+		}
+		e.utf8 = remapSignature(e.utf8, renamer)
+		if len(e.utf8) > maxUtf8Length && poolErr == nil {
+			poolErr = fmt.Errorf("改名后的描述符超过 %d 字节", maxUtf8Length)
+		}
+	}
+
+	// 2. 类引用指向新的类名
+	for i := 1; i < count; i++ {
+		e := cf.pool[i]
+		if e == nil || e.tag != cpClass {
+			continue
+		}
+		name := original[e.a]
+		var mapped string
+		if strings.HasPrefix(name, "[") {
+			mapped = remapSignature(name, renamer)
+		} else if newName, ok := renamer.renameClass(name); ok {
+			mapped = newName
+		}
+		if mapped != "" && mapped != name && cf.pool[e.a].utf8 != mapped {
+			e.a = addUtf8(mapped)
+		}
+	}
+
+	// 3. 字段和方法引用
+	for i := 1; i < count; i++ {
+		e := cf.pool[i]
+		if e == nil || (e.tag != cpFieldref && e.tag != cpMethodref && e.tag != cpInterfaceMethodref) {
+			continue
+		}
+		nat := cf.pool[e.b]
+		if nat == nil || nat.tag != cpNameAndType {
+			continue
+		}
+		nameIdx, descIdx := nat.a, descIndex(nat.b)
+		if newName, ok := renamer.renameMember(origClass(e.a), original[nat.a], original[nat.b], e.tag != cpFieldref); ok {
+			nameIdx = addUtf8(newName)
+		}
+		if nameIdx != nat.a || descIdx != nat.b {
+			e.b = addNameAndType(nameIdx, descIdx)
+		}
+	}
+	for i := 1; i < count; i++ {
+		if e := cf.pool[i]; e != nil && e.tag == cpMethodType {
+			e.a = descIndex(e.a)
+		}
+	}
+
+	// 4. 本类声明的字段和方法
+	owner := origClass(cf.this)
+	for _, members := range []struct {
+		list   []classMember
+		method bool
+	}{{cf.fields, false}, {cf.methods, true}} {
+		for j := range members.list {
+			m := &members.list[j]
+			if newName, ok := renamer.renameMember(owner, original[m.name], original[m.desc], members.method); ok {
+				m.name = addUtf8(newName)
+			}
+			m.desc = descIndex(m.desc)
+		}
+	}
+
+	// 5. InnerClasses中的内部类简单名称与新类名保持一致
+	for j := range cf.attrs {
+		if original[cf.attrs[j].name] == "InnerClasses" {
+			remapInnerClassNames(cf.attrs[j].data, original, origClass, renamer, addUtf8)
+		}
+	}
+	return poolErr
+}
+
+// annotationStrings 返回注解中字符串元素值（tag为s）使用的Utf8索引，这些是字符串字面量，不能当作描述符改写
+func (cf *classFile) annotationStrings() map[uint16]bool {
+	literals := make(map[uint16]bool)
+	var scan func(attrs []classAttribute)
+	scan = func(attrs []classAttribute) {
+		for _, attr := range attrs {
+			r := &classReader{data: attr.data}
+			switch cf.utf8At(attr.name) {
+			case "RuntimeVisibleAnnotations", "RuntimeInvisibleAnnotations":
+				readAnnotations(r, literals, false)
+			case "RuntimeVisibleTypeAnnotations", "RuntimeInvisibleTypeAnnotations":
+				readAnnotations(r, literals, true)
+			case "RuntimeVisibleParameterAnnotations", "RuntimeInvisibleParameterAnnotations":
+				for n := int(r.u1()); n > 0 && r.err == nil; n-- {
+					readAnnotations(r, literals, false)
+				}
+			case "AnnotationDefault":
+				readElementValue(r, literals)
+			case "Code":
+				// 代码中的类型注解在Code属性的子属性里
+				r.bytes(4)
+				r.bytes(int(r.u4()))
+				r.bytes(int(r.u2()) * 8)
+				if r.err == nil {
+					scan(readAttributes(r))
+				}
+			}
+		}
+	}
+	scan(cf.attrs)
+	for _, m := range cf.fields {
+		scan(m.attrs)
+	}
+	for _, m := range cf.methods {
+		scan(m.attrs)
+	}
+	return literals
+}
+
+// readAnnotations 读取注解列表，typed为true时每个注解前有类型注解的target_info和type_path
+func readAnnotations(r *classReader, literals map[uint16]bool, typed bool) {
+	for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+		if typed {
+			switch target := r.u1(); {
+			case target == 0x00, target == 0x01, target == 0x16:
+				r.bytes(1)
+			case target >= 0x10 && target <= 0x12, target == 0x17, target >= 0x42 && target <= 0x46:
+				r.bytes(2)
+			case target == 0x40 || target == 0x41:
+				r.bytes(int(r.u2()) * 6)
+			case target >= 0x47 && target <= 0x4B:
+				r.bytes(3)
+			}
+			r.bytes(int(r.u1()) * 2)
+		}
+		readAnnotation(r, literals)
+	}
+}
+
+// readAnnotation 读取一个注解：type_index和各元素值
+func readAnnotation(r *classReader, literals map[uint16]bool) {
+	r.u2()
+	for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+		r.u2()
+		readElementValue(r, literals)
+	}
+}
+
+// readElementValue 读取注解元素值，记录字符串常量的索引
+func readElementValue(r *classReader, literals map[uint16]bool) {
+	switch tag := r.u1(); tag {
+	case 's':
+		literals[r.u2()] = true
+	case 'e':
+		r.bytes(4)
+	case '@':
+		readAnnotation(r, literals)
+	case '[':
+		for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+			readElementValue(r, literals)
+		}
+	default:
+		// 基本类型常量和c（类描述符）
+		r.u2()
+	}
+}
+
+// remapInnerClassNames 改写InnerClasses属性中的inner_name_index，类引用已指向新名称，按改名前的类名查找
+func remapInnerClassNames(data []byte, original []string, origClass func(uint16) string, renamer classRenamer, addUtf8 func(string) uint16) {
+	if len(data) < 2 {
+		return
+	}
+	n := int(binary.BigEndian.Uint16(data))
+	for k := 0; k < n && 2+k*8+8 <= len(data); k++ {
+		entry := data[2+k*8 : 2+k*8+8]
+		innerClass := binary.BigEndian.Uint16(entry[0:2])
+		innerName := binary.BigEndian.Uint16(entry[4:6])
+		if innerName == 0 {
+			continue
+		}
+		newName, ok := renamer.renameClass(origClass(innerClass))
+		if !ok {
+			continue
+		}
+		simple := newName[strings.LastIndexAny(newName, "$/")+1:]
+		if simple != original[innerName] {
+			binary.BigEndian.PutUint16(entry[4:6], addUtf8(simple))
+		}
+	}
+}
+
+// remapSignature 改写描述符或泛型签名中的类名，无法按签名语法解析的字符串原样返回
+func remapSignature(s string, renamer classRenamer) string {
+	if s == "" || !strings.ContainsAny(s, "L") {
+		return s
+	}
+	p := &signatureParser{s: s, renamer: renamer}
+	if p.parse() && p.pos == len(s) {
+		return p.out.String()
+	}
+	return s
+}
+
+// signatureParser 按JVM描述符/签名语法解析并重写类名
+type signatureParser struct {
+	s       string
+	pos     int
+	out     strings.Builder
+	renamer classRenamer
+}
+
+func (p *signatureParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *signatureParser) emit() {
+	p.out.WriteByte(p.s[p.pos])
+	p.pos++
+}
+
+func (p *signatureParser) parse() bool {
+	if p.peek() == '<' && !p.formalTypeParams() {
+		return false
+	}
+	if p.peek() == '(' {
+		p.emit()
+		for p.peek() != ')' {
+			if !p.typeSig() {
+				return false
+			}
+		}
+		p.emit()
+		if !p.typeSig() {
+			return false
+		}
+		for p.peek() == '^' {
+			p.emit()
+			if !p.typeSig() {
+				return false
+			}
+		}
+		return true
+	}
+	// 字段描述符或类签名（父类+接口）
+	for p.pos < len(p.s) {
+		if !p.typeSig() {
+			return false
+		}
+	}
+	return true
+}
+
+// formalTypeParams <T:Ljava/lang/Object;U::Ljava/lang/Comparable<TU;>;>
+func (p *signatureParser) formalTypeParams() bool {
+	p.emit()
+	for p.peek() != '>' {
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] != ':' {
+			p.pos++
+		}
+		if p.pos == start || p.pos >= len(p.s) {
+			return false
+		}
+		p.out.WriteString(p.s[start:p.pos])
+		for p.peek() == ':' {
+			p.emit()
+			if c := p.peek(); c == 'L' || c == 'T' || c == '[' {
+				if !p.typeSig() {
+					return false
+				}
+			}
+		}
+	}
+	p.emit()
+	return true
+}
+
+func (p *signatureParser) typeSig() bool {
+	switch p.peek() {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 'V', '*':
+		p.emit()
+		return true
+	case '[', '+', '-':
+		p.emit()
+		return p.typeSig()
+	case 'T':
+		end := strings.IndexByte(p.s[p.pos:], ';')
+		if end < 0 {
+			return false
+		}
+		p.out.WriteString(p.s[p.pos : p.pos+end+1])
+		p.pos += end + 1
+		return true
+	case 'L':
+		return p.classTypeSig()
+	}
+	return false
+}
+
+// classTypeSig Lpkg/Outer<TT;>.Inner;
+func (p *signatureParser) classTypeSig() bool {
+	p.emit()
+	name := p.ident()
+	if name == "" {
+		return false
+	}
+	newName := name
+	if mapped, ok := p.renamer.renameClass(name); ok {
+		newName = mapped
+	}
+	p.out.WriteString(newName)
+
+	for {
+		if p.peek() == '<' && !p.typeArgs() {
+			return false
+		}
+		if p.peek() != '.' {
+			break
+		}
+		p.pos++
+		inner := p.ident()
+		if inner == "" {
+			return false
+		}
+		// 内部类名按 外部类$内部类 查找改名
+		name = name + "$" + inner
+		simple := inner
+		if mapped, ok := p.renamer.renameClass(name); ok {
+			simple = mapped[strings.LastIndexAny(mapped, "$/")+1:]
+		}
+		p.out.WriteByte('.')
+		p.out.WriteString(simple)
+	}
+	if p.peek() != ';' {
+		return false
+	}
+	p.emit()
+	return true
+}
+
+func (p *signatureParser) typeArgs() bool {
+	p.emit()
+	for p.peek() != '>' {
+		if !p.typeSig() {
+			return false
+		}
+	}
+	p.emit()
+	return true
+}
+
+func (p *signatureParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ';', '<', '.', '>':
+			return p.s[start:p.pos]
+		}
+		p.pos++
+	}
+	return ""
+}
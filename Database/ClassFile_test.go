@@ -0,0 +1,312 @@
+package Database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testClassBuilder 按JVM规范直接拼出class文件字节，不依赖被测的序列化代码
+type testClassBuilder struct {
+	pool  bytes.Buffer
+	count uint16
+	index map[string]uint16
+}
+
+type testMember struct {
+	access     uint16
+	name, desc string
+	attrs      []testAttribute
+}
+
+type testAttribute struct {
+	name string
+	data []byte
+}
+
+func newTestClassBuilder() *testClassBuilder {
+	return &testClassBuilder{count: 1, index: make(map[string]uint16)}
+}
+
+func (b *testClassBuilder) entry(key string, width uint16, write func()) uint16 {
+	if i, ok := b.index[key]; ok {
+		return i
+	}
+	write()
+	i := b.count
+	b.count += width
+	b.index[key] = i
+	return i
+}
+
+func (b *testClassBuilder) u2(v uint16) {
+	binary.Write(&b.pool, binary.BigEndian, v)
+}
+
+func (b *testClassBuilder) utf8(s string) uint16 {
+	return b.entry("utf8:"+s, 1, func() {
+		b.pool.WriteByte(cpUtf8)
+		b.u2(uint16(len(s)))
+		b.pool.WriteString(s)
+	})
+}
+
+func (b *testClassBuilder) class(name string) uint16 {
+	n := b.utf8(name)
+	return b.entry("class:"+name, 1, func() {
+		b.pool.WriteByte(cpClass)
+		b.u2(n)
+	})
+}
+
+func (b *testClassBuilder) str(s string) uint16 {
+	n := b.utf8(s)
+	return b.entry("string:"+s, 1, func() {
+		b.pool.WriteByte(cpString)
+		b.u2(n)
+	})
+}
+
+func (b *testClassBuilder) long(v int64) uint16 {
+	return b.entry(fmt.Sprintf("long:%d", v), 2, func() {
+		b.pool.WriteByte(cpLong)
+		binary.Write(&b.pool, binary.BigEndian, v)
+	})
+}
+
+func (b *testClassBuilder) nameAndType(name, desc string) uint16 {
+	n, d := b.utf8(name), b.utf8(desc)
+	return b.entry("nat:"+name+":"+desc, 1, func() {
+		b.pool.WriteByte(cpNameAndType)
+		b.u2(n)
+		b.u2(d)
+	})
+}
+
+func (b *testClassBuilder) ref(tag byte, owner, name, desc string) uint16 {
+	c, nat := b.class(owner), b.nameAndType(name, desc)
+	return b.entry(fmt.Sprintf("ref%d:%s.%s%s", tag, owner, name, desc), 1, func() {
+		b.pool.WriteByte(tag)
+		b.u2(c)
+		b.u2(nat)
+	})
+}
+
+// build 生成class文件，常量池需在调用前准备好（属性名和成员名会在这里补充）
+func (b *testClassBuilder) build(name, super string, interfaces []string, fields, methods []testMember, attrs []testAttribute) []byte {
+	this, superIdx := b.class(name), uint16(0)
+	if super != "" {
+		superIdx = b.class(super)
+	}
+	var ifaces []uint16
+	for _, iface := range interfaces {
+		ifaces = append(ifaces, b.class(iface))
+	}
+	var body bytes.Buffer
+	w := func(v interface{}) { binary.Write(&body, binary.BigEndian, v) }
+	writeAttrs := func(attrs []testAttribute) {
+		w(uint16(len(attrs)))
+		for _, a := range attrs {
+			w(b.utf8(a.name))
+			w(uint32(len(a.data)))
+			body.Write(a.data)
+		}
+	}
+	w(uint16(0x0021))
+	w(this)
+	w(superIdx)
+	w(uint16(len(ifaces)))
+	for _, i := range ifaces {
+		w(i)
+	}
+	for _, members := range [][]testMember{fields, methods} {
+		w(uint16(len(members)))
+		for _, m := range members {
+			w(m.access)
+			w(b.utf8(m.name))
+			w(b.utf8(m.desc))
+			writeAttrs(m.attrs)
+		}
+	}
+	writeAttrs(attrs)
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, uint32(0xCAFEBABE))
+	binary.Write(&out, binary.BigEndian, uint16(0))
+	binary.Write(&out, binary.BigEndian, uint16(52))
+	binary.Write(&out, binary.BigEndian, b.count)
+	out.Write(b.pool.Bytes())
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func u2Bytes(values ...uint16) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// obfuscatedOuterClass 模拟ProGuard处理后的 com.acme.Outer（a.b），内部类 com.acme.Outer$Inner 为 a.b$c
+func obfuscatedOuterClass() []byte {
+	b := newTestClassBuilder()
+	b.str("a/b") // 与类名同值的字符串常量，不能被改写
+	b.long(1 << 40)
+	b.ref(cpMethodref, "a/b$c", "e", "()V")
+	b.ref(cpFieldref, "a/b", "c", "La/b$c;")
+	code := u2Bytes(0, 0, 0, 0, 0, 0)
+	signature := u2Bytes(b.utf8("Ljava/util/List<La/b$c;>;"))
+	innerClasses := u2Bytes(1, b.class("a/b$c"), b.class("a/b"), b.utf8("c"), 0x0009)
+	fields := []testMember{
+		{access: 0x0002, name: "c", desc: "La/b$c;"},
+		{access: 0x0002, name: "f", desc: "Ljava/util/List;", attrs: []testAttribute{{"Signature", signature}}},
+	}
+	methods := []testMember{
+		{access: 0x0001, name: "d", desc: "(La/b$c;I)Ljava/lang/String;", attrs: []testAttribute{{"Code", code}}},
+	}
+	return b.build("a/b", "java/lang/Object", []string{"java/io/Serializable"}, fields, methods,
+		[]testAttribute{{"InnerClasses", innerClasses}})
+}
+
+func TestClassFileRoundTrip(t *testing.T) {
+	data := obfuscatedOuterClass()
+	cf, err := parseClassFile(data)
+	if err != nil {
+		t.Fatalf("parseClassFile: %v", err)
+	}
+	if got := cf.bytes(); !bytes.Equal(got, data) {
+		t.Fatalf("round trip changed the class file: %d bytes -> %d bytes", len(data), len(got))
+	}
+	if cf.name() != "a/b" || cf.superName() != "java/lang/Object" {
+		t.Errorf("name = %q, super = %q", cf.name(), cf.superName())
+	}
+	if got := cf.interfaceNames(); len(got) != 1 || got[0] != "java/io/Serializable" {
+		t.Errorf("interfaces = %v", got)
+	}
+}
+
+func TestClassFileRejectsTruncatedData(t *testing.T) {
+	data := obfuscatedOuterClass()
+	if _, err := parseClassFile(data[:len(data)-3]); err == nil {
+		t.Error("parseClassFile accepted a truncated class file")
+	}
+}
+
+func TestRemapClassRenamesDescriptorsSignaturesAndInnerClasses(t *testing.T) {
+	mapping := loadTestMapping(t)
+	cf, err := parseClassFile(obfuscatedOuterClass())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remapClass(cf, mapping.renamerFor([]*classFile{cf})); err != nil {
+		t.Fatalf("remapClass: %v", err)
+	}
+
+	// 改写后的字节仍然是合法的class文件
+	cf, err = parseClassFile(cf.bytes())
+	if err != nil {
+		t.Fatalf("reparse: %v", err)
+	}
+	if cf.name() != "com/acme/Outer" {
+		t.Errorf("class name = %q", cf.name())
+	}
+	if name, desc := cf.utf8At(cf.fields[0].name), cf.utf8At(cf.fields[0].desc); name != "inner" || desc != "Lcom/acme/Outer$Inner;" {
+		t.Errorf("field = %s %s", name, desc)
+	}
+	signature := cf.utf8At(binary.BigEndian.Uint16(cf.fields[1].attrs[0].data))
+	if signature != "Ljava/util/List<Lcom/acme/Outer$Inner;>;" {
+		t.Errorf("Signature = %q", signature)
+	}
+	if name, desc := cf.utf8At(cf.methods[0].name), cf.utf8At(cf.methods[0].desc); name != "name" || desc != "(Lcom/acme/Outer$Inner;I)Ljava/lang/String;" {
+		t.Errorf("method = %s%s", name, desc)
+	}
+
+	refs := make(map[memberRef]bool)
+	for _, ref := range cf.memberRefs() {
+		refs[ref] = true
+	}
+	for _, want := range []memberRef{
+		{Owner: "com/acme/Outer$Inner", Name: "run", Desc: "()V", Method: true},
+		{Owner: "com/acme/Outer", Name: "inner", Desc: "Lcom/acme/Outer$Inner;"},
+	} {
+		if !refs[want] {
+			t.Errorf("missing reference %+v in %v", want, cf.memberRefs())
+		}
+	}
+	if got := cf.stringConstants(); len(got) != 1 || got[0] != "a/b" {
+		t.Errorf("string constants = %v, want the literal unchanged", got)
+	}
+
+	var innerName string
+	for _, attr := range cf.attrs {
+		if cf.utf8At(attr.name) == "InnerClasses" {
+			innerName = cf.utf8At(binary.BigEndian.Uint16(attr.data[6:8]))
+		}
+	}
+	if innerName != "Inner" {
+		t.Errorf("InnerClasses inner_name = %q", innerName)
+	}
+}
+
+func TestRemapClassReportsFullConstantPool(t *testing.T) {
+	b := newTestClassBuilder()
+	b.class("a/b")
+	b.class("java/lang/Object")
+	for b.count < maxConstantPool {
+		b.utf8(fmt.Sprintf("s%d", b.count))
+	}
+	data := b.build("a/b", "java/lang/Object", nil, nil, nil, nil)
+	cf, err := parseClassFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cf.pool) != maxConstantPool {
+		t.Fatalf("pool size = %d", len(cf.pool))
+	}
+	renamer := &obfuscationRenamer{classes: map[string]string{"a/b": "com/acme/Outer"}}
+	err = remapClass(cf, renamer)
+	if err == nil || !strings.Contains(err.Error(), "常量池") {
+		t.Errorf("remapClass error = %v, want constant pool overflow", err)
+	}
+}
+
+func TestRemapClassRewritesSharedArrayDescriptorsButNotAnnotationStrings(t *testing.T) {
+	b := newTestClassBuilder()
+	array := b.class("[La/b;") // javac让数组类常量（checkcast）和同值的字段描述符共用一个Utf8
+	annotation := append(u2Bytes(1, b.utf8("Lcom/acme/Ann;"), 1, b.utf8("value")), 's')
+	annotation = append(annotation, u2Bytes(b.utf8("La/b;"))...)
+	fields := []testMember{
+		{access: 0x0002, name: "arr", desc: "[La/b;"},
+		{access: 0x0002, name: "one", desc: "La/b;"},
+	}
+	data := b.build("x/y", "java/lang/Object", nil, fields, nil,
+		[]testAttribute{{"RuntimeVisibleAnnotations", annotation}})
+	cf, err := parseClassFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamer := &obfuscationRenamer{classes: map[string]string{"a/b": "com/acme/Outer"}}
+	if err := remapClass(cf, renamer); err != nil {
+		t.Fatalf("remapClass: %v", err)
+	}
+	if cf, err = parseClassFile(cf.bytes()); err != nil {
+		t.Fatalf("reparse: %v", err)
+	}
+
+	if got := cf.utf8At(cf.fields[0].desc); got != "[Lcom/acme/Outer;" {
+		t.Errorf("array field descriptor = %q", got)
+	}
+	if got := cf.utf8At(cf.fields[1].desc); got != "Lcom/acme/Outer;" {
+		t.Errorf("field descriptor = %q", got)
+	}
+	if got := cf.utf8At(cf.pool[array].a); got != "[Lcom/acme/Outer;" {
+		t.Errorf("array class = %q", got)
+	}
+	value := binary.BigEndian.Uint16(cf.attrs[0].data[9:11])
+	if got := cf.utf8At(value); got != "La/b;" {
+		t.Errorf("annotation string = %q, want the literal unchanged", got)
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"archive/zip"
 	"bufio"
 	"codeql_n1ght/Common"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 
@@ -17,9 +19,10 @@ import (
 	"github.com/fatih/color"
 )
 
-// DecompileJava 反编译Java文件
-func DecompileJava(args ...string) error {
-	cmd := exec.Command("java", args...)
+// DecompileJava 反编译Java文件，长时间没有输出和CPU进展时终止进程
+func DecompileJava(ctx context.Context, args ...string) error {
+	// 反编译器JVM的堆大小由 -java-heap 决定，多个反编译器并发时不会超出内存限制
+	cmd := Common.WatchCommand(ctx, 0, "java", append([]string{Common.JavaHeapArg()}, args...)...)
 	// 获取标准输出管道
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -35,11 +38,17 @@ func DecompileJava(args ...string) error {
 		return fmt.Errorf("启动命令失败: %v", err)
 	}
 	// 创建协程并发读取标准输出
-	go streamOutput(stdout, "STDOUT")
+	go streamOutput(cmd.TrackOutput(stdout), "STDOUT")
 	// 创建协程并发读取标准错误
-	go streamOutput(stderr, "STDERR")
+	go streamOutput(cmd.TrackOutput(stderr), "STDERR")
 	// 等待命令结束
 	if err := cmd.Wait(); err != nil {
+		if Common.Interrupted(ctx) {
+			return context.Cause(ctx)
+		}
+		if Common.IsTimeout(err) {
+			return err
+		}
 		return fmt.Errorf("命令执行异常: %v", err)
 	}
 	return nil
@@ -99,10 +108,13 @@ func copyFile(src, dst string) error {
 
 
 // DecompileLibraries 反编译依赖库，允许用户选择
-func DecompileLibraries(location string) {
-	// 优先检查BOOT-INF/lib目录（Spring Boot结构）
+func DecompileLibraries(ctx context.Context, location string) {
+	// 优先检查BOOT-INF/lib目录（Spring Boot结构，MANIFEST.MF中声明了Spring-Boot-Lib时以其为准）
 	libDir := filepath.Join(location, "output", "BOOT-INF", "lib")
-	
+	if layout, ok := detectSpringBootLayout(filepath.Join(location, "output")); ok && Common.IsDirectory(layout.LibDir) {
+		libDir = layout.LibDir
+	}
+
 	// 如果BOOT-INF/lib不存在，检查传统的WEB-INF/lib目录
 	if _, err := os.Stat(libDir); os.IsNotExist(err) {
 		libDir = filepath.Join(location, "output", "WEB-INF", "lib")
@@ -110,31 +122,85 @@ func DecompileLibraries(location string) {
 			// 最后检查根目录下的lib目录
 			libDir = filepath.Join(location, "output", "lib")
 			if _, err := os.Stat(libDir); os.IsNotExist(err) {
-				fmt.Println("No lib directory found (checked BOOT-INF/lib, WEB-INF/lib, and lib), skipping jar decompilation.")
-				return
+				libDir = ""
 			}
 		}
 	}
-	
-	fmt.Printf("Using lib directory: %s\n", libDir)
 
-	// 查找所有.jar文件
-	jarFiles, err := filepath.Glob(filepath.Join(libDir, "*.jar"))
-	if err != nil {
-		fmt.Printf("Error searching for jar files: %v\n", err)
+	// EAR中Web模块自带的依赖目录等
+	var libDirs []string
+	if libDir != "" {
+		libDirs = append(libDirs, libDir)
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		libDirs = append(libDirs, meta.extraLibDirs...)
+	})
+	if len(libDirs) == 0 {
+		fmt.Println("No lib directory found (checked BOOT-INF/lib, WEB-INF/lib, and lib), skipping jar decompilation.")
 		return
 	}
 
+	// 查找所有.jar文件，同名jar只保留第一个
+	var jarFiles []string
+	seen := make(map[string]bool)
+	for _, dir := range libDirs {
+		fmt.Printf("Using lib directory: %s\n", dir)
+		found, err := filepath.Glob(filepath.Join(dir, "*.jar"))
+		if err != nil {
+			fmt.Printf("Error searching for jar files: %v\n", err)
+			return
+		}
+		for _, jarFile := range found {
+			if !seen[filepath.Base(jarFile)] {
+				seen[filepath.Base(jarFile)] = true
+				jarFiles = append(jarFiles, jarFile)
+			}
+		}
+	}
+
+	// 递归查找依赖jar和插件zip中的嵌套jar，与顶层依赖一起供选择
+	jarFiles = append(jarFiles, discoverNestedJars(location, libDirs, jarFiles)...)
+
 	if len(jarFiles) == 0 {
 		fmt.Println("No jar files found in lib directory.")
 		return
 	}
 
-	// 准备选项列表（只显示文件名）
+	var err error
+	// 按包名、groupId、发布方判断依赖归属
+	appPrefixes := appPackagePrefixes(filepath.Join(location, "createdabase", "src1"))
+	appGroups := appGroupIDs(filepath.Join(location, "output"))
+	classes := classifyDependencies(location, jarFiles, appPrefixes, appGroups)
+
+	// 匹配本地漏洞库，受影响的依赖排在最前面优先反编译
+	vulnerable := vulnerableJars(jarFiles)
+	if len(vulnerable) > 0 {
+		sort.SliceStable(jarFiles, func(i, j int) bool {
+			return len(vulnerable[dependencyName(jarFiles[i])]) > len(vulnerable[dependencyName(jarFiles[j])])
+		})
+		fmt.Printf("%d jar files have known vulnerabilities in the local feed.\n", len(vulnerable))
+	}
+
+	// 准备选项列表（文件名后附带归属分类），自有代码默认选中
 	options := make([]string, len(jarFiles))
+	optionFiles := make(map[string]string, len(jarFiles))
+	var defaults []string
+	categoryCount := make(map[string]int)
 	for i, jarFile := range jarFiles {
-		options[i] = filepath.Base(jarFile)
+		name := dependencyName(jarFile)
+		category := classes[name].Category
+		categoryCount[category]++
+		options[i] = fmt.Sprintf("%s [%s]", name, category)
+		if ids := vulnerable[name]; len(ids) > 0 {
+			options[i] += fmt.Sprintf(" [VULN: %s]", strings.Join(ids, ","))
+		}
+		optionFiles[options[i]] = name
+		if category == categoryFirstParty {
+			defaults = append(defaults, options[i])
+		}
 	}
+	fmt.Printf("Dependency classification: %d first-party, %d third-party, %d unknown\n",
+		categoryCount[categoryFirstParty], categoryCount[categoryThirdParty], categoryCount[categoryUnknown])
 
 	// 清空当前输出
 	// clearScreen()
@@ -146,20 +212,38 @@ func DecompileLibraries(location string) {
 	    fmt.Println("Dependency selection set to 'none'; skipping jar decompilation.")
 	    return
 	} else if mode == "all" {
-	    selectedFiles = options
+	    for _, jarFile := range jarFiles {
+	        selectedFiles = append(selectedFiles, dependencyName(jarFile))
+	    }
 	    fmt.Printf("Auto-selected all %d jar files for decompilation.\n", len(selectedFiles))
+	} else if mode == "rules" || mode == "first-party" {
+	    selectedFiles, _, err = selectDependenciesByRules(jarFiles, mode, Common.DepRules, classes)
+	    if err != nil {
+	        fmt.Printf("Error applying dependency rules: %v\n", err)
+	        return
+	    }
+	    if len(selectedFiles) == 0 {
+	        fmt.Println("No jar files matched the dependency rules, skipping jar decompilation.")
+	        return
+	    }
+	    fmt.Printf("Dependency rules selected %d of %d jar files.\n", len(selectedFiles), len(jarFiles))
 	} else {
 	    prompt := &survey.MultiSelect{
 	        Message:  "Select jar files to decompile (use arrow keys to navigate, space to select/deselect, enter to confirm):",
 	        Options:  options,
+	        Default:  defaults, // 默认选中自有代码
 	        PageSize: 40, // 每页显示40个选项
 	    }
 	
-	    err = survey.AskOne(prompt, &selectedFiles)
+	    var selectedOptions []string
+	    err = survey.AskOne(prompt, &selectedOptions)
 	    if err != nil {
 	        fmt.Printf("Error during selection: %v\n", err)
 	        return
 	    }
+	    for _, option := range selectedOptions {
+	        selectedFiles = append(selectedFiles, optionFiles[option])
+	    }
 	
 	    if len(selectedFiles) == 0 {
 	        fmt.Println("No files selected, skipping jar decompilation.")
@@ -167,31 +251,53 @@ func DecompileLibraries(location string) {
 	    }
 	}
 
+	// 找出主程序和选中的jar之间重复的类，避免多个jar写入同一个源码文件
+	var selectedPaths []string
+	for _, jarFile := range jarFiles {
+		for _, selectedFile := range selectedFiles {
+			if dependencyName(jarFile) == selectedFile {
+				selectedPaths = append(selectedPaths, jarFile)
+				break
+			}
+		}
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.SelectedDependencies = selectedFiles
+	})
+	separate := resolveDuplicateClasses(location, selectedPaths, classes)
+
 	// 反编译选中的文件
 	fmt.Printf("\nDecompiling %d selected jar files...\n", len(selectedFiles))
 
 	if Common.UseGoroutine {
 	    // 使用goroutine并发反编译
-	    decompileWithGoroutines(selectedFiles, jarFiles, location)
+	    decompileWithGoroutines(ctx, selectedFiles, jarFiles, location)
 	} else {
 	    // 串行反编译
 	    for _, selectedFile := range selectedFiles {
+	        if Common.Interrupted(ctx) {
+	            break
+	        }
 	        // 找到完整路径
 	        for _, jarFile := range jarFiles {
-	            if filepath.Base(jarFile) == selectedFile {
+	            if dependencyName(jarFile) == selectedFile {
 	                fmt.Printf("Decompiling %s...\n", selectedFile)
 	                outputDir := filepath.Join(location, "createdabase", "src1")
-	                decompileJarFile(jarFile, outputDir, selectedFile)
+	                decompileJarFile(ctx, location, jarFile, outputDir, selectedFile)
 	                break
 	            }
 	        }
 	    }
 	}
+	if Common.Interrupted(ctx) {
+		return
+	}
+	decompileSeparateRoots(ctx, location, separate)
 	fmt.Println("Jar decompilation completed.")
 }
 
 // 实时流式打印输出
-func streamOutput(reader io.ReadCloser, prefix string) {
+func streamOutput(reader io.Reader, prefix string) {
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
 		fmt.Printf("[%s] %s\n", prefix, scanner.Text())
@@ -214,37 +320,6 @@ func clearScreen() {
 	cmd.Run()
 }
 
-// decompileWithProcyon 使用Procyon反编译器
-func decompileWithProcyon(jarFile, outputDir string) error {
-	return DecompileJava("-jar", "tools/procyon-decompiler-0.6.0.jar", jarFile, "-o", outputDir)
-}
-
-// decompileWithFernflower 使用Fernflower反编译器
-func decompileWithFernflower(jarFile, outputDir string) error {
-	// 使用fernflower反编译jar文件
-	err := DecompileJava("-cp", "tools/java-decompiler.jar",
-		"org.jetbrains.java.decompiler.main.decompiler.ConsoleDecompiler",
-		"-dgs=true",
-		jarFile, outputDir)
-	if err != nil {
-		return err
-	}
-
-	// 反编译完成后，解压生成的jar文件
-	decompiledJar := filepath.Join(outputDir, filepath.Base(jarFile))
-	if _, err := os.Stat(decompiledJar); err == nil {
-		extractDir := filepath.Join(outputDir)
-		if err := extractJar(decompiledJar, extractDir); err != nil {
-			return fmt.Errorf("解压反编译后的jar文件失败: %v", err)
-		} else {
-			// 删除反编译生成的jar文件
-			os.Remove(decompiledJar)
-			fmt.Printf("反编译和解压完成，源码位于: %s\n", extractDir)
-		}
-	}
-	return nil
-}
-
 // extractJar 解压jar文件
 func extractJar(jarFile, destDir string) error {
 	// 创建目标目录
@@ -307,38 +382,72 @@ func extractJar(jarFile, destDir string) error {
 	return nil
 }
 
-// decompileJarFile 反编译单个jar文件
-func decompileJarFile(jarFile, outputDir, selectedFile string) {
-	var err error
-	// 根据反编译器类型选择不同的反编译方式
-	switch Common.DecompilerType {
-	case "fernflower":
-		err = decompileWithFernflower(jarFile, outputDir)
-		if err != nil {
-			color.Red("Fernflower反编译失败: %v，切换到Procyon反编译器\n", err)
-			err = decompileWithProcyon(jarFile, outputDir)
-			if err != nil {
-				color.Red("Procyon反编译也失败: %v\n", err)
-			} else {
-				fmt.Printf("使用Procyon反编译器成功完成 %s\n", selectedFile)
-			}
+// decompileJarFile 反编译单个jar文件，开启逐类回退时先反编译到暂存目录，修复失败的类后再合并
+func decompileJarFile(ctx context.Context, location, jarFile, outputDir, selectedFile string) {
+	ctx, cancel := Common.WithTimeout(ctx, "反编译 "+selectedFile, Common.DecompileTimeout)
+	defer cancel()
+	input := prepareDecompileInput(location, jarFile)
+	if !reuseUnchangedSources(location, input, outputDir, selectedFile) {
+		return
+	}
+	if !Common.ClassFallback {
+		used := decompileWithFallback(ctx, location, input.Path, outputDir, selectedFile)
+		if used != nil {
+			recordOrigins(location, input, outputDir, used)
 		}
-	default: // procyon
-		err = decompileWithProcyon(jarFile, outputDir)
-		if err != nil {
-			color.Red("Procyon反编译失败: %v，切换到Fernflower反编译器\n", err)
-			err = decompileWithFernflower(jarFile, outputDir)
-			if err != nil {
-				color.Red("Fernflower反编译也失败: %v\n", err)
-			} else {
-				fmt.Printf("使用Fernflower反编译器成功完成 %s\n", selectedFile)
-			}
+		return
+	}
+
+	stageDir := filepath.Join(filepath.Dir(outputDir), ".stage", selectedFile)
+	defer os.RemoveAll(stageDir)
+
+	used := decompileWithFallback(ctx, location, input.Path, stageDir, selectedFile)
+	if used == nil {
+		return
+	}
+	repairFailedClasses(ctx, input.Path, stageDir, used)
+
+	if err := copyDir(stageDir, outputDir); err != nil {
+		color.Red("合并 %s 的反编译结果失败: %v\n", selectedFile, err)
+		return
+	}
+	recordOrigins(location, input, outputDir, used)
+}
+
+// decompileWithFallback 使用-decompiler指定（auto模式下按样本得分选出）的反编译器整包反编译，进程失败或卡死时切换到另一个反编译器，返回实际使用的反编译器（全部失败或任务超时时为nil）
+func decompileWithFallback(ctx context.Context, location, input, outputDir, selectedFile string) Decompiler {
+	primary := selectedDecompiler()
+	if isAutoDecompiler() {
+		primary = chooseDecompiler(ctx, location, input, selectedFile)
+	}
+	err := primary.Decompile(ctx, input, outputDir)
+	if err == nil {
+		recordDecompiled(location, selectedFile, primary, false)
+		return primary
+	}
+	if Common.Interrupted(ctx) {
+		// 被中断或超过-decompile-timeout，而不是反编译器出错，不再切换反编译器
+		if cause := context.Cause(ctx); Common.IsTimeout(cause) {
+			color.Red("%v，已跳过\n", cause)
+			recordDecompileFailure(location, selectedFile, cause)
 		}
+		return nil
+	}
+
+	fallback := fallbackDecompiler(primary)
+	color.Red("%s反编译失败: %v，切换到%s反编译器\n", primary.Name(), err, fallback.Name())
+	if err := fallback.Decompile(ctx, input, outputDir); err != nil {
+		color.Red("%s反编译也失败: %v\n", fallback.Name(), err)
+		recordDecompileFailure(location, selectedFile, err)
+		return nil
 	}
+	fmt.Printf("使用%s反编译器成功完成 %s\n", fallback.Name(), selectedFile)
+	recordDecompiled(location, selectedFile, fallback, true)
+	return fallback
 }
 
 // decompileWithGoroutines 使用goroutine并发反编译
-func decompileWithGoroutines(selectedFiles, jarFiles []string, location string) {
+func decompileWithGoroutines(ctx context.Context, selectedFiles, jarFiles []string, location string) {
 	// 创建工作队列
 	type DecompileTask struct {
 		jarFile      string
@@ -363,8 +472,12 @@ func decompileWithGoroutines(selectedFiles, jarFiles []string, location string)
 		go func(workerID int) {
 			defer wg.Done()
 			for task := range tasks {
+				if Common.Interrupted(ctx) {
+					// 中断后丢弃队列中剩余的任务
+					continue
+				}
 				fmt.Printf("[Worker %d] Decompiling %s...\n", workerID, task.selectedFile)
-				decompileJarFile(task.jarFile, task.outputDir, task.selectedFile)
+				decompileJarFile(ctx, location, task.jarFile, task.outputDir, task.selectedFile)
 				fmt.Printf("[Worker %d] Completed %s\n", workerID, task.selectedFile)
 			}
 		}(i)
@@ -374,7 +487,7 @@ func decompileWithGoroutines(selectedFiles, jarFiles []string, location string)
 	for _, selectedFile := range selectedFiles {
 		// 找到完整路径
 		for _, jarFile := range jarFiles {
-			if filepath.Base(jarFile) == selectedFile {
+			if dependencyName(jarFile) == selectedFile {
 				outputDir := filepath.Join(location, "createdabase", "src1")
 				tasks <- DecompileTask{
 					jarFile:      jarFile,
@@ -0,0 +1,319 @@
+package Database
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// autoDecompilerName -decompiler auto：按样本得分为每个jar选择反编译器
+const autoDecompilerName = "auto"
+
+// autoPreference 得分相同时的优先顺序
+var autoPreference = []string{"procyon", "fernflower", "vineflower", "cfr"}
+
+// syntaxErrorPatterns javac解析错误的完整消息格式（英文和中文），用于区分解析错误和类型错误，
+// 如 ';' expected、<identifier> expected、illegal start of expression、需要';'
+var syntaxErrorPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?:'.+'|<identifier>|class, interface.*) expected$`),
+	regexp.MustCompile(`^illegal start of `),
+	regexp.MustCompile(`^not a statement$`),
+	regexp.MustCompile(`^unclosed `),
+	regexp.MustCompile(`^reached end of file while parsing$`),
+	regexp.MustCompile(`^'else' without 'if'$`),
+	regexp.MustCompile(`^orphaned `),
+	regexp.MustCompile(`^需要\s*(?:'.+'|<标识符>|class.*)$`),
+	regexp.MustCompile(`^非法的.+开始$`),
+	regexp.MustCompile(`^不是语句$`),
+	regexp.MustCompile(`^未结束的`),
+	regexp.MustCompile(`^(?:解析时)?已到达文件结尾$`),
+	regexp.MustCompile(`^有 'else', 但是没有 'if'$`),
+	regexp.MustCompile(`^孤立的`),
+}
+
+// syntheticNamePattern 反编译器生成的合成变量名，如var1、var10000、lvt_1_1、access$000
+var syntheticNamePattern = regexp.MustCompile(`\b(?:var\d+(?:_\d+)?|lvt_\d+_\d+|access\$\d+)\b`)
+
+// 各项指标在总分中的权重，总分越低越好
+const (
+	weightMissing       = 10.0
+	weightFailureMarker = 10.0
+	weightParseError    = 5.0
+	weightCompileError  = 2.0
+	weightNoisePerKLoc  = 0.05
+)
+
+// DecompilerScore 反编译器在样本类上的得分
+type DecompilerScore struct {
+	Decompiler       string  `json:"decompiler"`
+	Version          string  `json:"version"`
+	Classes          int     `json:"classes"`
+	Missing          int     `json:"missing"`
+	FailureMarkers   int     `json:"failureMarkers"`
+	ParseErrors      int     `json:"parseErrors"`
+	CompileErrors    int     `json:"compileErrors"`
+	JavacSuccessRate float64 `json:"javacSuccessRate"` // 不含缺少依赖的错误；javac不可用时为-1
+	SyntheticNoise   float64 `json:"syntheticNoise"`   // 每千行源码中的合成变量名数
+	Penalty          float64 `json:"penalty"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// DecompilerSelection auto模式下为某个输入选择反编译器的结果
+type DecompilerSelection struct {
+	Input    string            `json:"input"`
+	Vendor   string            `json:"vendor,omitempty"`
+	Selected string            `json:"selected"`
+	Scores   []DecompilerScore `json:"scores"`
+}
+
+// isAutoDecompiler 是否为-decompiler auto
+func isAutoDecompiler() bool {
+	return strings.EqualFold(Common.DecompilerType, autoDecompilerName)
+}
+
+// skippedDecompilers 已提示过无法运行的反编译器，每个只提示一次
+var skippedDecompilers sync.Map
+
+// installedDecompilers 按优先顺序返回已安装且能在当前java上运行的反编译器
+func installedDecompilers() []Decompiler {
+	var installed []Decompiler
+	for _, name := range autoPreference {
+		d := decompilers[name]
+		if !d.Installed() {
+			continue
+		}
+		if err := d.CheckRuntime(); err != nil {
+			if _, warned := skippedDecompilers.LoadOrStore(name, true); !warned {
+				color.Yellow("auto: 跳过%s: %v", name, err)
+			}
+			continue
+		}
+		installed = append(installed, d)
+	}
+	return installed
+}
+
+// chooseDecompiler 用每个已安装的反编译器反编译样本类并打分，返回得分最好的反编译器
+func chooseDecompiler(ctx context.Context, location, input, selectedFile string) Decompiler {
+	candidates := installedDecompilers()
+	if len(candidates) == 0 {
+		return decompilers["procyon"]
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	workDir, err := os.MkdirTemp(location, "auto-")
+	if err != nil {
+		color.Red("创建反编译器评估目录失败: %v", err)
+		return candidates[0]
+	}
+	defer os.RemoveAll(workDir)
+
+	// 目录输入先打包，后续按jar统一处理
+	jarFile := input
+	if Common.IsDirectory(input) {
+		jarFile = filepath.Join(workDir, "input.jar")
+		if err := packClassesDir(input, jarFile); err != nil {
+			color.Red("打包class目录失败: %v", err)
+			return candidates[0]
+		}
+	}
+
+	samples, err := sampleClassSources(jarFile, Common.AutoSampleSize)
+	if err != nil || len(samples) == 0 {
+		return candidates[0]
+	}
+	sampleJar := filepath.Join(workDir, "sample.jar")
+	if err := extractClassesToJar(jarFile, sampleJar, samples); err != nil {
+		color.Red("提取样本类失败: %v", err)
+		return candidates[0]
+	}
+
+	color.Green("auto: 使用 %d 个样本类评估 %s", len(samples), selectedFile)
+	scores := make([]DecompilerScore, 0, len(candidates))
+	best := 0
+	for i, d := range candidates {
+		score := scoreDecompiler(ctx, d, sampleJar, jarFile, samples, filepath.Join(workDir, d.Name()))
+		scores = append(scores, score)
+		if score.Error == "" && (scores[best].Error != "" || score.Penalty < scores[best].Penalty) {
+			best = i
+		}
+	}
+
+	selection := DecompilerSelection{
+		Input:    selectedFile,
+		Vendor:   inputVendor(input),
+		Selected: candidates[best].Name(),
+		Scores:   scores,
+	}
+	printDecompilerScores(selection)
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.DecompilerSelections = append(meta.DecompilerSelections, selection)
+	})
+	return candidates[best]
+}
+
+// scoreDecompiler 反编译样本jar并计算各项指标
+func scoreDecompiler(ctx context.Context, d Decompiler, sampleJar, classpath string, samples []string, outDir string) DecompilerScore {
+	score := DecompilerScore{
+		Decompiler:       d.Name(),
+		Version:          d.Version(),
+		Classes:          len(samples),
+		JavacSuccessRate: -1,
+	}
+	if err := d.Decompile(ctx, sampleJar, outDir); err != nil {
+		score.Error = err.Error()
+		return score
+	}
+
+	var present []string
+	lines := 0
+	noise := 0
+	for _, file := range samples {
+		data, err := os.ReadFile(filepath.Join(outDir, file))
+		if err != nil {
+			score.Missing++
+			continue
+		}
+		present = append(present, file)
+		lines += bytes.Count(data, []byte("\n")) + 1
+		noise += len(syntheticNamePattern.FindAll(data, -1))
+		score.FailureMarkers += countFailureMarkers(filepath.Join(outDir, file), d)
+	}
+	if lines > 0 {
+		score.SyntheticNoise = float64(noise) * 1000 / float64(lines)
+	}
+
+	if diagnostics, ok := javacDiagnostics(ctx, outDir, present, classpath); ok {
+		for _, messages := range diagnostics {
+			for _, message := range messages {
+				if isSyntaxError(message) {
+					score.ParseErrors++
+				} else {
+					score.CompileErrors++
+				}
+			}
+		}
+		if len(present) > 0 {
+			score.JavacSuccessRate = float64(len(present)-len(diagnostics)) / float64(len(present))
+		}
+	}
+
+	score.Penalty = weightMissing*float64(score.Missing) +
+		weightFailureMarker*float64(score.FailureMarkers) +
+		weightParseError*float64(score.ParseErrors) +
+		weightCompileError*float64(score.CompileErrors) +
+		weightNoisePerKLoc*score.SyntheticNoise
+	return score
+}
+
+// isSyntaxError 判断javac错误是否为语法解析错误
+func isSyntaxError(message string) bool {
+	message = strings.TrimSpace(message)
+	for _, pattern := range syntaxErrorPatterns {
+		if pattern.MatchString(message) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleClassSources 从jar中均匀抽取最多n个顶层类，返回对应的源码相对路径
+func sampleClassSources(jarFile string, n int) ([]string, error) {
+	r, err := zip.OpenReader(jarFile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var classes []string
+	for _, f := range r.File {
+		name := f.Name
+		base := path.Base(name)
+		if !strings.HasSuffix(name, ".class") || strings.Contains(base, "$") ||
+			base == "module-info.class" || base == "package-info.class" ||
+			strings.HasPrefix(name, "META-INF/") {
+			continue
+		}
+		classes = append(classes, strings.TrimSuffix(name, ".class")+".java")
+	}
+	sort.Strings(classes)
+
+	if n <= 0 || len(classes) <= n {
+		return toNativePaths(classes), nil
+	}
+	samples := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		samples = append(samples, classes[i*len(classes)/n])
+	}
+	return toNativePaths(samples), nil
+}
+
+// toNativePaths 将zip中的路径转换为本地路径
+func toNativePaths(paths []string) []string {
+	for i, p := range paths {
+		paths[i] = filepath.FromSlash(p)
+	}
+	return paths
+}
+
+// inputVendor 读取jar的发布方（MANIFEST或Maven groupId），用于统计各厂商字节码适合的反编译器
+func inputVendor(input string) string {
+	if Common.IsDirectory(input) {
+		return ""
+	}
+	info, err := inspectJar(input)
+	if err != nil {
+		return ""
+	}
+	if vendor := manifestVendor(info.Manifest); vendor != "" {
+		return vendor
+	}
+	if len(info.Coordinates) > 0 {
+		return info.Coordinates[0].GroupID
+	}
+	return ""
+}
+
+// printDecompilerScores 打印反编译器评估结果
+func printDecompilerScores(selection DecompilerSelection) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "反编译器评估: %s", selection.Input)
+	if selection.Vendor != "" {
+		fmt.Fprintf(&buf, " (%s)", selection.Vendor)
+	}
+	buf.WriteString("\n")
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  反编译器\t缺失\t失败标记\t语法错误\t编译错误\tjavac通过率\t合成变量/千行\t总分")
+	for _, score := range selection.Scores {
+		if score.Error != "" {
+			fmt.Fprintf(w, "  %s\t-\t-\t-\t-\t-\t-\t失败: %s\n", score.Decompiler, score.Error)
+			continue
+		}
+		rate := "-"
+		if score.JavacSuccessRate >= 0 {
+			rate = fmt.Sprintf("%.0f%%", score.JavacSuccessRate*100)
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%d\t%s\t%.1f\t%.1f\n", score.Decompiler,
+			score.Missing, score.FailureMarkers, score.ParseErrors, score.CompileErrors,
+			rate, score.SyntheticNoise, score.Penalty)
+	}
+	w.Flush()
+	fmt.Print(buf.String())
+	color.Green("auto: %s 选择 %s", selection.Input, selection.Selected)
+}
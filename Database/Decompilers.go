@@ -0,0 +1,333 @@
+package Database
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"codeql_n1ght/Common"
+)
+
+// Decompiler 反编译器后端
+type Decompiler interface {
+	// Name 反编译器名称，与-decompiler参数一致
+	Name() string
+	// Version 反编译器版本，优先读取工具jar的MANIFEST.MF
+	Version() string
+	// Decompile 反编译jar包或class目录，源码输出到outputDir
+	Decompile(ctx context.Context, input, outputDir string) error
+	// FailureMarkers 反编译失败时在源码中留下的标记
+	FailureMarkers() []string
+	// Installed 工具jar是否已安装
+	Installed() bool
+	// CheckRuntime 检查当前java能否运行该反编译器
+	CheckRuntime() error
+}
+
+// decompilerTool 以jar形式运行的反编译器的公共部分
+type decompilerTool struct {
+	name           string
+	jar            string
+	defaultVersion string
+	markers        []string
+	minJava        int // 运行所需的最低Java主版本，0表示不限制
+
+	versionOnce sync.Once
+	version     string
+}
+
+// Name 返回反编译器名称
+func (t *decompilerTool) Name() string {
+	return t.name
+}
+
+// Version 返回工具jar中Implementation-Version，读取失败时使用内置版本号
+func (t *decompilerTool) Version() string {
+	t.versionOnce.Do(func() {
+		t.version = t.defaultVersion
+		if manifest, err := readJarManifest(t.jar); err == nil && manifest["Implementation-Version"] != "" {
+			t.version = manifest["Implementation-Version"]
+		}
+	})
+	return t.version
+}
+
+// FailureMarkers 返回反编译失败标记
+func (t *decompilerTool) FailureMarkers() []string {
+	return t.markers
+}
+
+// Installed 检查工具jar是否存在
+func (t *decompilerTool) Installed() bool {
+	return Common.FileExists(t.jar)
+}
+
+// CheckRuntime 检查java主版本是否满足要求
+func (t *decompilerTool) CheckRuntime() error {
+	if t.minJava == 0 {
+		return nil
+	}
+	major, err := javaMajorVersion()
+	if err != nil {
+		return fmt.Errorf("%s需要Java %d及以上，无法确认当前Java版本: %v", t.name, t.minJava, err)
+	}
+	if major < t.minJava {
+		return fmt.Errorf("%s需要Java %d及以上，当前java为Java %d（-install只安装JDK 8），请将JAVA_HOME指向更高版本的JDK或选择其他反编译器", t.name, t.minJava, major)
+	}
+	return nil
+}
+
+// javaMajorVersion 缓存java主版本，环境变量在建库前设置好后不再变化
+var javaMajorVersion = sync.OnceValues(Common.JavaMajorVersion)
+
+// procyonDecompiler Procyon反编译器，只接受jar或class文件，目录输入先打包成jar
+type procyonDecompiler struct{ decompilerTool }
+
+// Decompile 使用Procyon反编译
+func (d *procyonDecompiler) Decompile(ctx context.Context, input, outputDir string) error {
+	return withJarInput(input, outputDir, func(jarFile string) error {
+		args := []string{"-jar", d.jar, jarFile, "-o", outputDir}
+		if Common.LineNumbers {
+			// 以 /*SL:行号*/ 注释输出原始行号
+			args = append(args, "-dl")
+		}
+		return DecompileJava(ctx, args...)
+	})
+}
+
+// lineMappingArgs Fernflower/Vineflower输出行号映射的参数：-bsm=1只在内存中记录字节码和源码的对应关系，
+// 还需要-__dump_original_lines__=1才会在文件末尾写出 // 原始行 反编译行 ... 注释
+var lineMappingArgs = []string{"-bsm=1", "-__dump_original_lines__=1"}
+
+// fernflowerDecompiler IntelliJ内置的Fernflower反编译器
+type fernflowerDecompiler struct{ decompilerTool }
+
+// Decompile 使用Fernflower反编译，jar输入会生成同名源码jar，需要再解压
+func (d *fernflowerDecompiler) Decompile(ctx context.Context, input, outputDir string) error {
+	args := []string{"-cp", d.jar, "org.jetbrains.java.decompiler.main.decompiler.ConsoleDecompiler"}
+	if Common.IsDirectory(input) {
+		args = append(args, "-dgs=true", "-hdc=0", "-dgs=1", "-rsy=1", "-rbr=1", "-lit=1", "-nls=1", "-mpm=60")
+	} else {
+		args = append(args, "-dgs=true")
+	}
+	if Common.LineNumbers {
+		args = append(args, lineMappingArgs...)
+	}
+	if Common.IsDirectory(input) {
+		return DecompileJava(ctx, append(args, input, outputDir)...)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	if err := DecompileJava(ctx, append(args, input, outputDir)...); err != nil {
+		return err
+	}
+	return extractDecompiledJar(input, outputDir)
+}
+
+// cfrDecompiler CFR反编译器，对新版本Java语法（lambda、switch表达式等）支持较好
+type cfrDecompiler struct{ decompilerTool }
+
+// Decompile 使用CFR反编译
+func (d *cfrDecompiler) Decompile(ctx context.Context, input, outputDir string) error {
+	return withJarInput(input, outputDir, func(jarFile string) error {
+		return DecompileJava(ctx, "-jar", d.jar, jarFile, "--outputdir", outputDir, "--silent", "true")
+	})
+}
+
+// vineflowerDecompiler Vineflower反编译器（Fernflower的社区分支，需要Java 11及以上）
+type vineflowerDecompiler struct{ decompilerTool }
+
+// Decompile 使用Vineflower反编译
+func (d *vineflowerDecompiler) Decompile(ctx context.Context, input, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	args := []string{"-jar", d.jar, "-dgs=1", "-hdc=0", "-rsy=1", "-rbr=1", "-lit=1", "-nls=1", "-mpm=60"}
+	if Common.LineNumbers {
+		args = append(args, lineMappingArgs...)
+	}
+	if err := DecompileJava(ctx, append(args, "--folder", input, outputDir)...); err != nil {
+		return err
+	}
+	if Common.IsDirectory(input) {
+		return nil
+	}
+	return extractDecompiledJar(input, outputDir)
+}
+
+// decompilers 已注册的反编译器
+var decompilers = map[string]Decompiler{
+	"procyon": &procyonDecompiler{decompilerTool{
+		name:           "procyon",
+		jar:            "tools/procyon-decompiler-0.6.0.jar",
+		defaultVersion: "0.6.0",
+		markers:        []string{"could not be decompiled"},
+	}},
+	"fernflower": &fernflowerDecompiler{decompilerTool{
+		name:           "fernflower",
+		jar:            "tools/java-decompiler.jar",
+		defaultVersion: "unknown",
+		markers:        []string{"$FF: Couldn't be decompiled", "<undefinedtype>"},
+	}},
+	"cfr": &cfrDecompiler{decompilerTool{
+		name:           "cfr",
+		jar:            "tools/cfr-0.152.jar",
+		defaultVersion: "0.152",
+		markers:        []string{"This method has failed to decompile", "Exception decompiling"},
+	}},
+	"vineflower": &vineflowerDecompiler{decompilerTool{
+		name:           "vineflower",
+		jar:            "tools/vineflower-1.10.1.jar",
+		defaultVersion: "1.10.1",
+		markers:        []string{"$VF: Couldn't be decompiled"},
+		minJava:        11,
+	}},
+}
+
+// DecompilerNames 返回所有可用的反编译器名称
+func DecompilerNames() []string {
+	names := make([]string, 0, len(decompilers))
+	for name := range decompilers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetDecompiler 按名称获取反编译器
+func GetDecompiler(name string) (Decompiler, error) {
+	if d, ok := decompilers[strings.ToLower(name)]; ok {
+		return d, nil
+	}
+	return nil, fmt.Errorf("不支持的反编译器: %s（可选 %s）", name, strings.Join(DecompilerNames(), "|"))
+}
+
+// ValidateDecompilerName 检查-decompiler参数，除具体的反编译器外还接受auto
+func ValidateDecompilerName(name string) error {
+	if strings.EqualFold(name, autoDecompilerName) {
+		return nil
+	}
+	if _, err := GetDecompiler(name); err != nil {
+		return fmt.Errorf("不支持的反编译器: %s（可选 %s|%s）", name, strings.Join(DecompilerNames(), "|"), autoDecompilerName)
+	}
+	return nil
+}
+
+// selectedDecompiler 返回-decompiler指定的反编译器，未知名称时使用Procyon
+func selectedDecompiler() Decompiler {
+	if d, err := GetDecompiler(Common.DecompilerType); err == nil {
+		return d
+	}
+	return decompilers["procyon"]
+}
+
+// checkDecompilerRuntime 检查-decompiler指定的反编译器能否在当前java上运行，auto模式在评估时跳过无法运行的反编译器，需在设置环境变量后调用
+func checkDecompilerRuntime() error {
+	if isAutoDecompiler() {
+		return nil
+	}
+	return selectedDecompiler().CheckRuntime()
+}
+
+// fallbackDecompiler 返回反编译失败时换用的反编译器
+func fallbackDecompiler(d Decompiler) Decompiler {
+	if d.Name() == "procyon" {
+		return decompilers["fernflower"]
+	}
+	return decompilers["procyon"]
+}
+
+// readJarManifest 只读取jar中的META-INF/MANIFEST.MF
+func readJarManifest(jarPath string) (map[string]string, error) {
+	r, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name == "META-INF/MANIFEST.MF" {
+			data, err := readZipEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			return parseManifest(data), nil
+		}
+	}
+	return nil, fmt.Errorf("jar中没有MANIFEST.MF: %s", jarPath)
+}
+
+// extractDecompiledJar 解压Fernflower系反编译器在输出目录生成的同名源码jar
+func extractDecompiledJar(input, outputDir string) error {
+	decompiledJar := filepath.Join(outputDir, filepath.Base(input))
+	if _, err := os.Stat(decompiledJar); err != nil {
+		return nil
+	}
+	if err := extractJar(decompiledJar, outputDir); err != nil {
+		return fmt.Errorf("解压反编译后的jar文件失败: %v", err)
+	}
+	// 删除反编译生成的jar文件
+	os.Remove(decompiledJar)
+	fmt.Printf("反编译和解压完成，源码位于: %s\n", outputDir)
+	return nil
+}
+
+// withJarInput 对只接受jar输入的反编译器，把class目录临时打包成jar后再调用
+func withJarInput(input, outputDir string, fn func(jarFile string) error) error {
+	if !Common.IsDirectory(input) {
+		return fn(input)
+	}
+	tmpDir, err := os.MkdirTemp("", "n1ght-classes-")
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	jarFile := filepath.Join(tmpDir, filepath.Base(input)+".jar")
+	if err := packClassesDir(input, jarFile); err != nil {
+		return fmt.Errorf("打包class目录失败: %v", err)
+	}
+	return fn(jarFile)
+}
+
+// packClassesDir 将目录中的class文件按相对路径打包成jar
+func packClassesDir(dir, jarFile string) error {
+	out, err := os.Create(jarFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := zip.NewWriter(out)
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".class") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		dst, err := w.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(dst, src)
+		return err
+	})
+	if err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
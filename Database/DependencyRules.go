@@ -0,0 +1,258 @@
+package Database
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// dependencyDecision 单个依赖jar的选择结果及原因
+type dependencyDecision struct {
+	Name     string
+	Selected bool
+	Reason   string
+}
+
+// dependencyRuleSet 编译后的依赖选择规则
+type dependencyRuleSet struct {
+	includeGlobs  []string
+	excludeGlobs  []string
+	includeCoords []*regexp.Regexp
+	excludeCoords []*regexp.Regexp
+}
+
+// compileDependencyRules 校验glob并编译坐标正则
+func compileDependencyRules(rules Common.DependencyRules) (*dependencyRuleSet, error) {
+	set := &dependencyRuleSet{
+		includeGlobs: rules.Include,
+		excludeGlobs: rules.Exclude,
+	}
+	for _, glob := range append(append([]string{}, rules.Include...), rules.Exclude...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("无效的glob规则 %q: %v", glob, err)
+		}
+	}
+	for _, expr := range rules.IncludeCoordinates {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的坐标正则 %q: %v", expr, err)
+		}
+		set.includeCoords = append(set.includeCoords, re)
+	}
+	for _, expr := range rules.ExcludeCoordinates {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的坐标正则 %q: %v", expr, err)
+		}
+		set.excludeCoords = append(set.excludeCoords, re)
+	}
+	return set, nil
+}
+
+// needsCoordinates 是否需要读取jar中的Maven坐标
+func (s *dependencyRuleSet) needsCoordinates() bool {
+	return len(s.includeCoords) > 0 || len(s.excludeCoords) > 0
+}
+
+// matchGlob 返回命中的第一个glob
+func matchGlob(globs []string, name string) (string, bool) {
+	for _, glob := range globs {
+		// 嵌套jar的名称带有外层路径，同时按文件名匹配
+		if ok, _ := path.Match(glob, name); ok {
+			return glob, true
+		}
+		if ok, _ := path.Match(glob, path.Base(name)); ok {
+			return glob, true
+		}
+	}
+	return "", false
+}
+
+// matchCoordinate 返回命中的第一个坐标及正则
+func matchCoordinate(exprs []*regexp.Regexp, info *jarInfo) (string, bool) {
+	if info == nil {
+		return "", false
+	}
+	for _, re := range exprs {
+		for _, coord := range info.Coordinates {
+			if re.MatchString(coord.String()) {
+				return fmt.Sprintf("%s ~ %s", coord, re), true
+			}
+		}
+	}
+	return "", false
+}
+
+// selectDependenciesByRules 按规则（rules）或依赖归属分类（first-party）选择依赖
+func selectDependenciesByRules(jarFiles []string, mode string, rules Common.DependencyRules, classes map[string]DependencyClassification) ([]string, []dependencyDecision, error) {
+	set, err := compileDependencyRules(rules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var selected []string
+	decisions := make([]dependencyDecision, 0, len(jarFiles))
+	for _, jarFile := range jarFiles {
+		name := dependencyName(jarFile)
+
+		var info *jarInfo
+		if set.needsCoordinates() {
+			if info, err = inspectJar(jarFile); err != nil {
+				color.Yellow("读取 %s 失败: %v", name, err)
+			}
+		}
+
+		decision := decideDependency(name, info, set, mode, classes[name])
+		decisions = append(decisions, decision)
+		if decision.Selected {
+			selected = append(selected, name)
+		}
+	}
+
+	logDependencyDecisions(decisions)
+	return selected, decisions, nil
+}
+
+// decideDependency 判断单个依赖是否选中，排除规则优先
+func decideDependency(name string, info *jarInfo, set *dependencyRuleSet, mode string, class DependencyClassification) dependencyDecision {
+	decision := dependencyDecision{Name: name}
+
+	if glob, ok := matchGlob(set.excludeGlobs, name); ok {
+		decision.Reason = "命中排除规则 " + glob
+		return decision
+	}
+	if coord, ok := matchCoordinate(set.excludeCoords, info); ok {
+		decision.Reason = "命中排除坐标 " + coord
+		return decision
+	}
+
+	if mode == "first-party" && class.Category == categoryFirstParty {
+		decision.Selected = true
+		decision.Reason = class.Reason
+		return decision
+	}
+
+	if glob, ok := matchGlob(set.includeGlobs, name); ok {
+		decision.Selected = true
+		decision.Reason = "命中选中规则 " + glob
+		return decision
+	}
+	if coord, ok := matchCoordinate(set.includeCoords, info); ok {
+		decision.Selected = true
+		decision.Reason = "命中选中坐标 " + coord
+		return decision
+	}
+
+	// rules模式下没有任何选中规则时，视为除排除项外全部选中
+	if mode == "rules" && len(set.includeGlobs) == 0 && len(set.includeCoords) == 0 {
+		decision.Selected = true
+		decision.Reason = "未配置选中规则，默认选中"
+		return decision
+	}
+
+	decision.Reason = "未命中任何规则"
+	return decision
+}
+
+// logDependencyDecisions 输出每个依赖的选择结果
+func logDependencyDecisions(decisions []dependencyDecision) {
+	fmt.Println("依赖选择结果:")
+	for _, decision := range decisions {
+		if decision.Selected {
+			color.Green("  [+] %s: %s", decision.Name, decision.Reason)
+		} else {
+			color.White("  [-] %s: %s", decision.Name, decision.Reason)
+		}
+	}
+}
+
+// jasperPackage Jasper编译JSP生成的Servlet所在的包，不属于主程序自己的包名
+const jasperPackage = "org.apache.jsp"
+
+// appPackagePrefixes 从主程序源码目录推断包名前缀（取前三段，如com.acme.shop），跳过JSP生成的Servlet
+func appPackagePrefixes(src1Dir string) []string {
+	prefixes := make(map[string]bool)
+	filepath.Walk(src1Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(src1Dir, p)
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if strings.ReplaceAll(filepath.ToSlash(rel), "/", ".") == jasperPackage {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(info.Name(), ".java") || filepath.Dir(rel) == "." {
+			return nil
+		}
+		pkg := strings.ReplaceAll(filepath.ToSlash(filepath.Dir(rel)), "/", ".")
+		if prefix := packagePrefix(pkg); prefix != "" {
+			prefixes[prefix] = true
+		}
+		return nil
+	})
+
+	var result []string
+	for prefix := range prefixes {
+		result = append(result, prefix)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// appGroupIDs 读取主程序解压目录中的pom.properties（如META-INF/maven/com.acme/shop/pom.properties）得到主程序的groupId，
+// 依赖jar仍是压缩包，不会被读到
+func appGroupIDs(outputDir string) []string {
+	groups := make(map[string]bool)
+	filepath.Walk(outputDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() != "pom.properties" || !strings.Contains(filepath.ToSlash(p), "/META-INF/maven/") {
+			return nil
+		}
+		if data, err := os.ReadFile(p); err == nil {
+			if coord, ok := parsePomProperties(data); ok && coord.GroupID != "" {
+				groups[coord.GroupID] = true
+			}
+		}
+		return nil
+	})
+
+	var result []string
+	for group := range groups {
+		result = append(result, group)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// packagePrefix 取包名的前三段，只取两段会把org.springframework.samples这类示例应用的前缀扩大到整个框架
+func packagePrefix(pkg string) string {
+	parts := strings.Split(pkg, ".")
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, ".")
+}
+
+// sharesPackagePrefix 判断jar中的包是否与主程序包名前缀相同
+func sharesPackagePrefix(packages, prefixes []string) (string, bool) {
+	for _, pkg := range packages {
+		for _, prefix := range prefixes {
+			if pkg == prefix || strings.HasPrefix(pkg, prefix+".") {
+				return prefix, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,417 @@
+package Database
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// diffArchiveDepth 对比时展开的嵌套jar层数，war中的WEB-INF/lib/*.jar为第1层
+const diffArchiveDepth = 3
+
+// maxPrintedChanges 终端中最多列出的变化类，完整结果见对比报告
+const maxPrintedChanges = 200
+
+// classRootPrefixes 不属于类名的目录前缀
+var classRootPrefixes = []string{"WEB-INF/classes/", "BOOT-INF/classes/"}
+
+// artifactSnapshot 制品中所有类的结构和方法摘要，以及制品根目录下其他文件的摘要
+type artifactSnapshot struct {
+	classes map[string]*classSnapshot // 所在jar或目录和class条目，如 WEB-INF/lib/foo.jar!/com/foo/Bar.class
+	files   map[string]string         // 文件路径 -> SHA-256
+}
+
+// classSnapshot 一个类的摘要
+type classSnapshot struct {
+	name      string // class条目，如 com/foo/Bar.class
+	container string
+	structure string
+	methods   map[string]methodSnapshot // 方法名和描述符 -> 摘要
+}
+
+// methodSnapshot 一个方法的摘要和原始行号范围
+type methodSnapshot struct {
+	fingerprint string
+	first, last int
+}
+
+// DiffArtifacts 对比两个版本的jar、war、ear或解压目录，列出新增、删除和变化的类和方法，以及根目录下变化的JSP和配置文件
+func DiffArtifacts(oldPath, newPath string) (*Common.ArtifactDiff, error) {
+	oldSnap, err := snapshotArtifact(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取旧版本失败: %v", err)
+	}
+	newSnap, err := snapshotArtifact(newPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取新版本失败: %v", err)
+	}
+
+	diff := &Common.ArtifactDiff{Old: oldPath, New: newPath, CreatedAt: time.Now()}
+	diff.OldSHA256, _, _ = artifactHash(oldPath)
+	diff.NewSHA256, _, _ = artifactHash(newPath)
+
+	compare := func(before, after *classSnapshot) {
+		change := compareClasses(before, after)
+		if change == nil {
+			diff.Summary.UnchangedClasses++
+			return
+		}
+		for _, m := range change.Methods {
+			switch m.Status {
+			case Common.ChangeAdded:
+				diff.Summary.AddedMethods++
+			case Common.ChangeRemoved:
+				diff.Summary.RemovedMethods++
+			default:
+				diff.Summary.ChangedMethods++
+			}
+		}
+		diff.Classes = append(diff.Classes, *change)
+		diff.Summary.ChangedClasses++
+	}
+
+	var added, removed []*classSnapshot
+	for _, key := range unionKeys(oldSnap.classes, newSnap.classes) {
+		before, after := oldSnap.classes[key], newSnap.classes[key]
+		switch {
+		case before == nil:
+			added = append(added, after)
+		case after == nil:
+			removed = append(removed, before)
+		default:
+			compare(before, after)
+		}
+	}
+	moved, added, removed := pairMovedClasses(added, removed)
+	for _, pair := range moved {
+		compare(pair[0], pair[1])
+	}
+	for _, after := range added {
+		diff.Classes = append(diff.Classes, Common.ClassChange{Class: after.name, Container: after.container, Status: Common.ChangeAdded})
+		diff.Summary.AddedClasses++
+	}
+	for _, before := range removed {
+		diff.Classes = append(diff.Classes, Common.ClassChange{Class: before.name, Container: before.container, Status: Common.ChangeRemoved})
+		diff.Summary.RemovedClasses++
+	}
+	sort.SliceStable(diff.Classes, func(i, j int) bool {
+		a, b := diff.Classes[i], diff.Classes[j]
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		return a.Container < b.Container
+	})
+
+	for _, name := range unionKeys(oldSnap.files, newSnap.files) {
+		before, inOld := oldSnap.files[name]
+		after, inNew := newSnap.files[name]
+		status := Common.ChangeChanged
+		switch {
+		case !inOld:
+			status = Common.ChangeAdded
+		case !inNew:
+			status = Common.ChangeRemoved
+		case before == after:
+			continue
+		}
+		diff.Files = append(diff.Files, Common.FileChange{Path: name, Status: status})
+		diff.Summary.ChangedFiles++
+	}
+	return diff, nil
+}
+
+// pairMovedClasses 所在的jar改名（如依赖升级后文件名中的版本号变化）时，新旧版本中都只有一处的同名类仍视为同一个类，
+// 返回配对的 [旧, 新] 和剩下的新增、删除的类
+func pairMovedClasses(added, removed []*classSnapshot) ([][2]*classSnapshot, []*classSnapshot, []*classSnapshot) {
+	count := func(classes []*classSnapshot) map[string]int {
+		counts := make(map[string]int)
+		for _, c := range classes {
+			counts[c.name]++
+		}
+		return counts
+	}
+	addedCount, removedCount := count(added), count(removed)
+	single := func(name string) bool {
+		return addedCount[name] == 1 && removedCount[name] == 1
+	}
+
+	movedTo := make(map[string]*classSnapshot)
+	var restAdded []*classSnapshot
+	for _, c := range added {
+		if single(c.name) {
+			movedTo[c.name] = c
+		} else {
+			restAdded = append(restAdded, c)
+		}
+	}
+	var pairs [][2]*classSnapshot
+	var restRemoved []*classSnapshot
+	for _, c := range removed {
+		if single(c.name) {
+			pairs = append(pairs, [2]*classSnapshot{c, movedTo[c.name]})
+		} else {
+			restRemoved = append(restRemoved, c)
+		}
+	}
+	return pairs, restAdded, restRemoved
+}
+
+// compareClasses 对比同一个类的新旧版本的结构和每个方法，没有变化时返回nil
+func compareClasses(before, after *classSnapshot) *Common.ClassChange {
+	change := &Common.ClassChange{
+		Class:     after.name,
+		Container: after.container,
+		Status:    Common.ChangeChanged,
+		Structure: before.structure != after.structure,
+	}
+	for _, method := range unionKeys(before.methods, after.methods) {
+		old, inOld := before.methods[method]
+		cur, inNew := after.methods[method]
+		m := Common.MethodChange{Method: method, Status: Common.ChangeChanged, FirstLine: cur.first, LastLine: cur.last}
+		switch {
+		case !inOld:
+			m.Status = Common.ChangeAdded
+		case !inNew:
+			m = Common.MethodChange{Method: method, Status: Common.ChangeRemoved}
+		case old.fingerprint == cur.fingerprint:
+			continue
+		}
+		change.Methods = append(change.Methods, m)
+	}
+	if !change.Structure && len(change.Methods) == 0 {
+		return nil
+	}
+	return change
+}
+
+// snapshotArtifact 读取制品（或解压目录）及其中嵌套的jar
+func snapshotArtifact(artifact string) (*artifactSnapshot, error) {
+	snap := &artifactSnapshot{
+		classes: make(map[string]*classSnapshot),
+		files:   make(map[string]string),
+	}
+	if !Common.IsDirectory(artifact) {
+		r, err := zip.OpenReader(artifact)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return snap, snap.addArchive(&r.Reader, "", 0)
+	}
+
+	err := filepath.Walk(artifact, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(artifact, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return snap.addEntry(filepath.ToSlash(rel), "", data, 0)
+	})
+	return snap, err
+}
+
+// addArchive 读取zip中的所有条目，container为该zip在制品中的路径（制品本身为空）
+func (s *artifactSnapshot) addArchive(r *zip.Reader, container string, depth int) error {
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %v", f.Name, err)
+		}
+		if err := s.addEntry(f.Name, container, data, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEntry 记录一个条目：class文件计算结构和方法摘要，嵌套的jar和war继续展开，制品根目录下的其他文件记录摘要
+func (s *artifactSnapshot) addEntry(name, container string, data []byte, depth int) error {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".class"):
+		s.addClass(name, container, data)
+	case (strings.HasSuffix(lower, ".jar") || strings.HasSuffix(lower, ".war")) && depth < diffArchiveDepth:
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			color.Yellow("跳过无法解析的嵌套包 %s: %v", name, err)
+			return nil
+		}
+		label := name
+		if container != "" {
+			label = container + "!/" + name
+		}
+		return s.addArchive(r, label, depth+1)
+	case container == "":
+		sum := sha256.Sum256(data)
+		s.files[name] = hex.EncodeToString(sum[:])
+	}
+	return nil
+}
+
+// addClass 解析class文件，按所在jar或目录和类名记录，同一位置的同名类（如解压目录中重复的条目）保留第一个
+func (s *artifactSnapshot) addClass(name, container string, data []byte) {
+	if strings.HasPrefix(name, "META-INF/") {
+		return
+	}
+	for _, prefix := range classRootPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			if container == "" {
+				container = strings.TrimSuffix(prefix, "/")
+			} else {
+				container += "!/" + strings.TrimSuffix(prefix, "/")
+			}
+			name = strings.TrimPrefix(name, prefix)
+			break
+		}
+	}
+	if base := path.Base(name); base == "module-info.class" || base == "package-info.class" {
+		return
+	}
+	key := name
+	if container != "" {
+		key = container + nestedSeparator + name
+	}
+	if _, ok := s.classes[key]; ok {
+		return
+	}
+	cf, err := parseClassFile(data)
+	if err != nil {
+		return
+	}
+	snap := &classSnapshot{
+		name:      name,
+		container: container,
+		structure: cf.structureFingerprint(),
+		methods:   make(map[string]methodSnapshot, len(cf.methods)),
+	}
+	for _, m := range cf.methods {
+		first, last := cf.methodLines(m)
+		snap.methods[cf.utf8At(m.name)+cf.utf8At(m.desc)] = methodSnapshot{
+			fingerprint: cf.methodFingerprint(m),
+			first:       first,
+			last:        last,
+		}
+	}
+	s.classes[key] = snap
+}
+
+// artifactHash 计算制品文件或目录的SHA-256
+func artifactHash(artifact string) (string, int64, error) {
+	if Common.IsDirectory(artifact) {
+		return directoryHash(artifact)
+	}
+	return fileHash(artifact)
+}
+
+// unionKeys 返回两个map中所有键，按字典序排列
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PrintArtifactDiff 打印对比结果
+func PrintArtifactDiff(diff *Common.ArtifactDiff) {
+	printed := 0
+	for _, c := range diff.Classes {
+		if printed == maxPrintedChanges {
+			color.Yellow("  ……还有 %d 个变化的类，见对比报告", len(diff.Classes)-printed)
+			break
+		}
+		printed++
+		switch c.Status {
+		case Common.ChangeAdded:
+			color.Green("+ %s（%s）", c.Class, c.Container)
+		case Common.ChangeRemoved:
+			color.Red("- %s（%s）", c.Class, c.Container)
+		default:
+			structure := ""
+			if c.Structure {
+				structure = "，类结构有变化"
+			}
+			color.Yellow("~ %s（%s%s）", c.Class, c.Container, structure)
+			for _, m := range c.Methods {
+				if m.Status == Common.ChangeChanged && m.FirstLine > 0 {
+					fmt.Printf("    ~ %s（第 %d-%d 行）\n", m.Method, m.FirstLine, m.LastLine)
+				} else {
+					fmt.Printf("    %s %s\n", changeMark(m.Status), m.Method)
+				}
+			}
+		}
+	}
+	for _, f := range diff.Files {
+		fmt.Printf("%s %s\n", changeMark(f.Status), f.Path)
+	}
+
+	s := diff.Summary
+	color.Green("类: 新增 %d、删除 %d、变化 %d、未变化 %d", s.AddedClasses, s.RemovedClasses, s.ChangedClasses, s.UnchangedClasses)
+	color.Green("变化的类中的方法: 新增 %d、删除 %d、变化 %d", s.AddedMethods, s.RemovedMethods, s.ChangedMethods)
+	if s.ChangedFiles > 0 {
+		color.Green("JSP和配置等文件: %d 个变化", s.ChangedFiles)
+	}
+}
+
+// changeMark 返回变化类型的标记：+ 新增、- 删除、~ 变化
+func changeMark(status string) string {
+	switch status {
+	case Common.ChangeAdded:
+		return "+"
+	case Common.ChangeRemoved:
+		return "-"
+	}
+	return "~"
+}
+
+// RunDiff 对比两个版本的制品，打印结果并写入对比报告
+func RunDiff(oldPath, newPath, report string) error {
+	color.Green("对比 %s -> %s", oldPath, newPath)
+	diff, err := DiffArtifacts(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	PrintArtifactDiff(diff)
+
+	if report == "" {
+		report = Common.DefaultDiffReport
+	}
+	if err := Common.WriteArtifactDiff(report, diff); err != nil {
+		return fmt.Errorf("写入对比报告失败: %v", err)
+	}
+	color.Green("对比报告: %s（扫描时用 -changed-only 只报告涉及变化代码的结果）", report)
+	return nil
+}
@@ -0,0 +1,183 @@
+package Database
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codeql_n1ght/Common"
+)
+
+// codeAttribute 拼出Code属性，lines为LineNumberTable中的行号（从pc 0开始依次对应）
+func codeAttribute(b *testClassBuilder, code []byte, lines ...uint16) []byte {
+	var lineTable []byte
+	if len(lines) > 0 {
+		data := u2Bytes(uint16(len(lines)))
+		for i, line := range lines {
+			data = append(data, u2Bytes(uint16(i), line)...)
+		}
+		lineTable = append(u2Bytes(b.utf8("LineNumberTable"), 0, uint16(len(data))), data...)
+	}
+	attr := u2Bytes(2, 1, 0, uint16(len(code)))
+	attr = append(attr, code...)
+	attr = append(attr, u2Bytes(0)...)
+	if lineTable != nil {
+		return append(append(attr, u2Bytes(1)...), lineTable...)
+	}
+	return append(attr, u2Bytes(0)...)
+}
+
+// greetingClass 生成 com/acme/Greeter，run() 加载字符串常量后调用静态方法；
+// stringFirst控制两个常量在常量池中的先后，使同样的代码对应不同的索引
+func greetingClass(greeting string, stringFirst bool, lines ...uint16) []byte {
+	b := newTestClassBuilder()
+	var str, method uint16
+	if stringFirst {
+		str = b.str(greeting)
+		method = b.ref(cpMethodref, "com/acme/Log", "info", "(Ljava/lang/String;)V")
+	} else {
+		method = b.ref(cpMethodref, "com/acme/Log", "info", "(Ljava/lang/String;)V")
+		str = b.str(greeting)
+	}
+	code := []byte{0x12, byte(str), 0xb8, byte(method >> 8), byte(method), 0xb1} // ldc、invokestatic、return
+	methods := []testMember{{access: 0x0001, name: "run", desc: "()V", attrs: []testAttribute{{"Code", codeAttribute(b, code, lines...)}}}}
+	return b.build("com/acme/Greeter", "java/lang/Object", nil, nil, methods, nil)
+}
+
+func runFingerprint(t *testing.T, data []byte) (string, int, int) {
+	t.Helper()
+	cf, err := parseClassFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, last := cf.methodLines(cf.methods[0])
+	return cf.methodFingerprint(cf.methods[0]), first, last
+}
+
+func TestMethodFingerprintIgnoresConstantPoolLayoutAndLines(t *testing.T) {
+	base, first, last := runFingerprint(t, greetingClass("hello", true, 10, 11))
+	if first != 10 || last != 11 {
+		t.Errorf("lines = %d-%d, want 10-11", first, last)
+	}
+
+	// 常量池顺序不同、只是重新编译导致行号移动时，方法摘要不变
+	if reordered, _, _ := runFingerprint(t, greetingClass("hello", false, 10, 11)); reordered != base {
+		t.Error("constant pool order changed the fingerprint")
+	}
+	moved, first, last := runFingerprint(t, greetingClass("hello", true, 20, 22))
+	if moved != base {
+		t.Error("line numbers changed the fingerprint")
+	}
+	if first != 20 || last != 22 {
+		t.Errorf("lines = %d-%d, want 20-22", first, last)
+	}
+
+	// 引用的常量内容不同时摘要不同
+	if changed, _, _ := runFingerprint(t, greetingClass("bye", true, 10, 11)); changed == base {
+		t.Error("a different string constant kept the fingerprint")
+	}
+}
+
+// lambdaClass 生成 com/acme/Greeter，run() 通过invokedynamic创建lambda，target为lambda实现方法名；
+// swapped把两个引导方法在BootstrapMethods中的顺序对调，使同样的代码对应不同的引导方法序号
+func lambdaClass(target string, swapped bool) []byte {
+	b := newTestClassBuilder()
+	handle := func(owner, name, desc string) uint16 {
+		ref := b.ref(cpMethodref, owner, name, desc)
+		return b.entry(fmt.Sprintf("handle:%d", ref), 1, func() {
+			b.pool.WriteByte(cpMethodHandle)
+			b.pool.WriteByte(6) // REF_invokeStatic
+			b.u2(ref)
+		})
+	}
+	factory := handle("java/lang/invoke/LambdaMetafactory", "metafactory", "(Ljava/lang/invoke/MethodHandles$Lookup;)Ljava/lang/invoke/CallSite;")
+	impls := []uint16{handle("com/acme/Greeter", "lambda$run$0", "()V"), handle("com/acme/Greeter", "lambda$other$1", "()V")}
+	if swapped {
+		impls[0], impls[1] = impls[1], impls[0]
+	}
+	bsm := uint16(0)
+	if impls[1] == handle("com/acme/Greeter", target, "()V") {
+		bsm = 1
+	}
+	nat := b.nameAndType("run", "()Ljava/lang/Runnable;")
+	indy := b.entry(fmt.Sprintf("indy:%d", bsm), 1, func() {
+		b.pool.WriteByte(cpInvokeDynamic)
+		b.u2(bsm)
+		b.u2(nat)
+	})
+	bootstrap := u2Bytes(uint16(len(impls)))
+	for _, impl := range impls {
+		bootstrap = append(bootstrap, u2Bytes(factory, 1, impl)...)
+	}
+	code := []byte{0xba, byte(indy >> 8), byte(indy), 0, 0, 0x57, 0xb1} // invokedynamic、pop、return
+	methods := []testMember{{access: 0x0001, name: "run", desc: "()V", attrs: []testAttribute{{"Code", codeAttribute(b, code, 10)}}}}
+	return b.build("com/acme/Greeter", "java/lang/Object", nil, nil, methods, []testAttribute{{"BootstrapMethods", bootstrap}})
+}
+
+func TestMethodFingerprintResolvesInvokeDynamic(t *testing.T) {
+	base, _, _ := runFingerprint(t, lambdaClass("lambda$run$0", false))
+	// 其他lambda增删使引导方法序号变化时摘要不变
+	if reordered, _, _ := runFingerprint(t, lambdaClass("lambda$run$0", true)); reordered != base {
+		t.Error("bootstrap method order changed the fingerprint")
+	}
+	// 同一个序号指向不同的lambda实现时摘要不同
+	if retargeted, _, _ := runFingerprint(t, lambdaClass("lambda$other$1", true)); retargeted == base {
+		t.Error("a different lambda implementation kept the fingerprint")
+	}
+}
+
+func zipBytes(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiffArtifactsKeysClassesByContainer(t *testing.T) {
+	dir := t.TempDir()
+	unchanged := greetingClass("hello", true, 10)
+	war := func(name string, shaded []byte, toolsJar string) string {
+		p := filepath.Join(dir, name)
+		data := zipBytes(t, map[string][]byte{
+			"WEB-INF/lib/app-core.jar":  zipBytes(t, map[string][]byte{"com/acme/Greeter.class": unchanged}),
+			"WEB-INF/lib/app-shade.jar": zipBytes(t, map[string][]byte{"com/acme/Greeter.class": shaded}),
+			"WEB-INF/lib/" + toolsJar:   zipBytes(t, map[string][]byte{"com/tools/Greeter.class": unchanged}),
+		})
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	oldWar := war("old.war", unchanged, "tools-1.0.jar")
+	newWar := war("new.war", greetingClass("bye", true, 10), "tools-1.1.jar")
+
+	diff, err := DiffArtifacts(oldWar, newWar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只有app-shade.jar中的同名类变化；tools的jar改名后其中的类仍按同一个类对比
+	if len(diff.Classes) != 1 {
+		t.Fatalf("classes = %+v", diff.Classes)
+	}
+	change := diff.Classes[0]
+	if change.Class != "com/acme/Greeter.class" || change.Container != "WEB-INF/lib/app-shade.jar" || change.Status != Common.ChangeChanged {
+		t.Errorf("change = %+v", change)
+	}
+	want := Common.DiffSummary{ChangedClasses: 1, UnchangedClasses: 2, ChangedMethods: 1}
+	if diff.Summary != want {
+		t.Errorf("summary = %+v, want %+v", diff.Summary, want)
+	}
+}
@@ -0,0 +1,198 @@
+package Database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// 重复类处理策略
+const (
+	duplicatePolicyApp      = "app"      // 主程序的类优先，其次自有依赖，再按版本取最新
+	duplicatePolicyNewest   = "newest"   // 主程序的类优先，依赖之间按版本取最新
+	duplicatePolicySeparate = "separate" // 按app策略选出写入src1的副本，其余副本放到各自独立的源码根目录
+)
+
+// duplicateRootsDir 重复类独立源码根目录的父目录（位于createdabase下）
+const duplicateRootsDir = "src-dup"
+
+// maxDuplicateLines 控制台最多列出的重复类数量
+const maxDuplicateLines = 20
+
+// appSourceLabel 主程序（已反编译到src1的代码）在重复类记录中的名称
+const appSourceLabel = "."
+
+// DuplicateClass 多个来源中都存在的同名类及其处理结果
+type DuplicateClass struct {
+	Class    string   `json:"class"`              // 源码文件，如 com/foo/Bar.java
+	Sources  []string `json:"sources"`            // 包含该类的来源，主程序为 "."
+	Kept     string   `json:"kept"`               // 写入src1的来源
+	Separate []string `json:"separate,omitempty"` // separate策略下放入独立源码根目录的来源
+}
+
+// duplicateCandidate 重复类的一个来源
+type duplicateCandidate struct {
+	label      string
+	jarFile    string
+	firstParty bool
+	version    string
+}
+
+// ValidateDuplicatePolicy 校验-duplicates参数
+func ValidateDuplicatePolicy(policy string) error {
+	switch strings.ToLower(policy) {
+	case duplicatePolicyApp, duplicatePolicyNewest, duplicatePolicySeparate:
+		return nil
+	}
+	return fmt.Errorf("不支持的重复类处理策略: %s（可选 %s|%s|%s）", policy,
+		duplicatePolicyApp, duplicatePolicyNewest, duplicatePolicySeparate)
+}
+
+// resolveDuplicateClasses 在反编译依赖前找出主程序和选中的jar之间重复的类，按-duplicates策略决定每个类保留哪个副本，
+// 返回每个jar需要放到独立源码根目录的类（仅separate策略）
+func resolveDuplicateClasses(location string, jarFiles []string, classes map[string]DependencyClassification) map[string][]string {
+	policy := strings.ToLower(Common.DuplicatePolicy)
+	src1Dir := filepath.Join(location, "createdabase", "src1")
+
+	owners := make(map[string][]*duplicateCandidate)
+	app := &duplicateCandidate{label: appSourceLabel}
+	for _, source := range listJavaFiles(src1Dir) {
+		source = filepath.ToSlash(source)
+		owners[source] = append(owners[source], app)
+	}
+	for _, jarFile := range jarFiles {
+		entries, err := listTopLevelClasses(jarFile)
+		if err != nil {
+			continue
+		}
+		candidate := &duplicateCandidate{
+			label:      originLabel(location, jarFile),
+			jarFile:    jarFile,
+			firstParty: classes[dependencyName(jarFile)].Category == categoryFirstParty,
+		}
+		if component, err := identifyJar(jarFile); err == nil {
+			candidate.version = component.Version
+		}
+		for _, entry := range entries {
+			source := strings.TrimSuffix(entry, ".class") + ".java"
+			owners[source] = append(owners[source], candidate)
+		}
+	}
+
+	var duplicates []DuplicateClass
+	excluded := make(map[string]map[string]bool)
+	separate := make(map[string][]string)
+	for source, candidates := range owners {
+		if len(candidates) < 2 {
+			continue
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return preferDuplicate(candidates[i], candidates[j], policy)
+		})
+
+		dup := DuplicateClass{Class: source, Kept: candidates[0].label}
+		for _, c := range candidates {
+			dup.Sources = append(dup.Sources, c.label)
+		}
+		for _, c := range candidates[1:] {
+			if c.jarFile == "" {
+				continue
+			}
+			if excluded[c.jarFile] == nil {
+				excluded[c.jarFile] = make(map[string]bool)
+			}
+			excluded[c.jarFile][source] = true
+			if policy == duplicatePolicySeparate {
+				separate[c.jarFile] = append(separate[c.jarFile], source)
+				dup.Separate = append(dup.Separate, c.label)
+			}
+		}
+		duplicates = append(duplicates, dup)
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].Class < duplicates[j].Class })
+	color.Yellow("发现 %d 个在多个来源中重复的类，按 %s 策略处理", len(duplicates), policy)
+	for i, dup := range duplicates {
+		if i == maxDuplicateLines {
+			fmt.Printf("  ... 其余 %d 个见 n1ght-db.json\n", len(duplicates)-maxDuplicateLines)
+			break
+		}
+		fmt.Printf("  %s: %s -> 保留 %s\n", dup.Class, strings.Join(dup.Sources, ", "), dup.Kept)
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.DuplicatePolicy = policy
+		meta.DuplicateClasses = duplicates
+		meta.duplicateExclusions = excluded
+	})
+	return separate
+}
+
+// preferDuplicate 判断重复类的来源a是否优先于b：主程序的类始终优先
+func preferDuplicate(a, b *duplicateCandidate, policy string) bool {
+	if (a.jarFile == "") != (b.jarFile == "") {
+		return a.jarFile == ""
+	}
+	if policy != duplicatePolicyNewest && a.firstParty != b.firstParty {
+		return a.firstParty
+	}
+	if cmp := compareMavenVersions(a.version, b.version); cmp != 0 {
+		return cmp > 0
+	}
+	if a.firstParty != b.firstParty {
+		return a.firstParty
+	}
+	return a.label < b.label
+}
+
+// duplicateExclusions 返回jar中因重复而不写入src1的源码文件
+func duplicateExclusions(location, jarFile string) map[string]bool {
+	var excluded map[string]bool
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		excluded = meta.duplicateExclusions[jarFile]
+	})
+	return excluded
+}
+
+// decompileSeparateRoots separate策略：把落选的重复类从各自的jar中提取出来，反编译到 createdabase/src-dup/<jar名> 并加入构建文件
+func decompileSeparateRoots(ctx context.Context, location string, separate map[string][]string) {
+	if len(separate) == 0 {
+		return
+	}
+	createDir := filepath.Join(location, "createdabase")
+	for jarFile, sources := range separate {
+		name := strings.TrimSuffix(filepath.Base(jarFile), filepath.Ext(jarFile))
+		root := filepath.Join(createDir, duplicateRootsDir, name)
+		subset := filepath.Join(location, "dup-"+name+".jar")
+		if err := extractClassesToJar(jarFile, subset, sources); err != nil {
+			color.Red("提取 %s 中的重复类失败: %v", filepath.Base(jarFile), err)
+			continue
+		}
+		input := &decompileInput{Original: jarFile, Path: subset}
+		jobCtx, cancel := Common.WithTimeout(ctx, "反编译 "+filepath.Base(subset), Common.DecompileTimeout)
+		used := decompileWithFallback(jobCtx, location, subset, root, filepath.Base(jarFile))
+		cancel()
+		if used != nil {
+			recordOrigins(location, input, root, used)
+		}
+		os.Remove(subset)
+		if used == nil {
+			continue
+		}
+		color.Green("已将 %s 中的 %d 个重复类反编译到 %s/%s", filepath.Base(jarFile), len(sources), duplicateRootsDir, name)
+	}
+
+	// 独立源码根目录需要单独的javac任务，重新生成构建文件
+	if err := GenerateBuildXML(createDir); err != nil {
+		color.Red("更新build.xml失败: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package Database
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// earApplication 对应EAR包中的META-INF/application.xml
+type earApplication struct {
+	XMLName    xml.Name    `xml:"application"`
+	LibraryDir *string     `xml:"library-directory"`
+	Modules    []earModule `xml:"module"`
+}
+
+// earModule application.xml中的单个模块声明
+type earModule struct {
+	Web *struct {
+		WebURI      string `xml:"web-uri"`
+		ContextRoot string `xml:"context-root"`
+	} `xml:"web"`
+	Ejb       string `xml:"ejb"`
+	Java      string `xml:"java"`
+	Connector string `xml:"connector"`
+}
+
+// earLayout 解析后的EAR结构
+type earLayout struct {
+	LibraryDir string   // 共享依赖目录（相对EAR根目录）
+	WebModules []string // Web模块（WAR）路径，相对EAR根目录
+	JarModules []string // EJB/应用客户端/普通jar模块路径，相对EAR根目录
+}
+
+// parseEarLayout 读取application.xml，缺失时按Java EE 5的默认规则推断模块
+func parseEarLayout(earDir string) (*earLayout, error) {
+	layout := &earLayout{LibraryDir: "lib"}
+
+	descriptor := filepath.Join(earDir, "META-INF", "application.xml")
+	data, err := os.ReadFile(descriptor)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("读取application.xml失败: %v", err)
+		}
+		color.Yellow("未找到META-INF/application.xml，按文件扩展名推断模块")
+		return guessEarLayout(earDir, layout)
+	}
+
+	var app earApplication
+	if err := xml.Unmarshal(data, &app); err != nil {
+		return nil, fmt.Errorf("解析application.xml失败: %v", err)
+	}
+
+	// <library-directory/> 为空表示不使用共享依赖目录
+	if app.LibraryDir != nil {
+		layout.LibraryDir = strings.Trim(strings.TrimSpace(*app.LibraryDir), "/")
+		if layout.LibraryDir != "" && !safeEarURI(layout.LibraryDir) {
+			color.Red("忽略不安全的library-directory: %s", layout.LibraryDir)
+			layout.LibraryDir = ""
+		}
+	}
+
+	for _, module := range app.Modules {
+		var uri string
+		web := false
+		switch {
+		case module.Web != nil && module.Web.WebURI != "":
+			uri, web = strings.TrimSpace(module.Web.WebURI), true
+		case module.Ejb != "":
+			uri = strings.TrimSpace(module.Ejb)
+		case module.Java != "":
+			uri = strings.TrimSpace(module.Java)
+		case module.Connector != "":
+			color.Yellow("跳过资源适配器模块: %s", module.Connector)
+			continue
+		default:
+			continue
+		}
+		if !safeEarURI(uri) {
+			color.Red("忽略不安全的模块路径: %s", uri)
+			continue
+		}
+		if web {
+			layout.WebModules = append(layout.WebModules, uri)
+		} else {
+			layout.JarModules = append(layout.JarModules, uri)
+		}
+	}
+
+	return layout, nil
+}
+
+// safeEarURI 模块路径必须是EAR内的相对路径，拒绝绝对路径和包含..的路径
+func safeEarURI(uri string) bool {
+	if uri == "" || strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "\\") || filepath.IsAbs(uri) || filepath.VolumeName(uri) != "" {
+		return false
+	}
+	for _, segment := range strings.FieldsFunc(uri, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// guessEarLayout 没有部署描述符时，根目录下的.war为Web模块，.jar为EJB模块
+func guessEarLayout(earDir string, layout *earLayout) (*earLayout, error) {
+	entries, err := os.ReadDir(earDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取EAR目录失败: %v", err)
+	}
+
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".war":
+			layout.WebModules = append(layout.WebModules, entry.Name())
+		case ".jar":
+			if !entry.IsDir() {
+				layout.JarModules = append(layout.JarModules, entry.Name())
+			}
+		}
+	}
+	return layout, nil
+}
+
+// decompileEar 解压EAR中的每个模块并反编译到同一个src1目录
+func decompileEar(ctx context.Context, location string) error {
+	earDir := filepath.Join(location, "output")
+	src1Dir := filepath.Join(location, "createdabase", "src1")
+
+	layout, err := parseEarLayout(earDir)
+	if err != nil {
+		return err
+	}
+	color.Green("EAR包包含 %d 个Web模块，%d 个EJB/jar模块", len(layout.WebModules), len(layout.JarModules))
+
+	// 先解压所有Web模块，去混淆的改名规则需要覆盖EAR中的全部类
+	moduleDirs := make(map[string]string)
+	for _, uri := range layout.WebModules {
+		modulePath := filepath.Join(earDir, filepath.FromSlash(uri))
+		moduleDir := modulePath
+		if !Common.IsDirectory(modulePath) {
+			// 打包形式的WAR，解压到output/.modules下
+			moduleDir = filepath.Join(earDir, ".modules", filepath.Base(uri))
+			if err := Common.ExtractZip(modulePath, moduleDir); err != nil {
+				color.Red("解压Web模块 %s 失败: %v", uri, err)
+				continue
+			}
+		}
+		moduleDirs[uri] = moduleDir
+	}
+	prepareDeobfuscation(location)
+
+	// 处理Web模块：复用WAR的反编译逻辑
+	for _, uri := range layout.WebModules {
+		moduleDir, ok := moduleDirs[uri]
+		if !ok {
+			continue
+		}
+
+		color.Green("开始处理Web模块: %s", uri)
+		if err := decompileWebModule(ctx, location, moduleDir, src1Dir); err != nil {
+			color.Red("Web模块 %s 反编译失败: %v", uri, err)
+		}
+
+		// 模块自带的依赖加入编译classpath，并交给依赖阶段选择反编译
+		if moduleLib := filepath.Join(moduleDir, "WEB-INF", "lib"); Common.IsDirectory(moduleLib) {
+			if err := addClasspathJars(location, moduleLib); err != nil {
+				color.Red("复制Web模块 %s 的依赖失败: %v", uri, err)
+			}
+			addExtraLibDir(location, moduleLib)
+		}
+	}
+
+	// 处理EJB模块和普通jar模块
+	for _, uri := range layout.JarModules {
+		modulePath := filepath.Join(earDir, filepath.FromSlash(uri))
+		if !Common.FileExists(modulePath) {
+			color.Red("模块不存在: %s", uri)
+			continue
+		}
+		fmt.Printf("Decompiling EAR module %s...\n", uri)
+		decompileJarFile(ctx, location, modulePath, src1Dir, filepath.Base(uri))
+	}
+
+	// 共享依赖加入编译classpath
+	if layout.LibraryDir != "" {
+		libDir := filepath.Join(earDir, filepath.FromSlash(layout.LibraryDir))
+		if err := addClasspathJars(location, libDir); err != nil {
+			color.Red("复制EAR共享依赖失败: %v", err)
+		}
+		// 默认的lib目录由依赖阶段直接扫描，自定义目录需要单独登记
+		if layout.LibraryDir != "lib" && Common.IsDirectory(libDir) {
+			addExtraLibDir(location, libDir)
+		}
+	}
+
+	return nil
+}
+
+// addExtraLibDir 登记主依赖目录之外的依赖目录
+func addExtraLibDir(location, libDir string) {
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.extraLibDirs = append(meta.extraLibDirs, libDir)
+	})
+}
+
+// addClasspathJars 将目录下的jar复制到createdabase/lib，供build.xml加入编译classpath
+func addClasspathJars(location, libDir string) error {
+	jarFiles, err := filepath.Glob(filepath.Join(libDir, "*.jar"))
+	if err != nil {
+		return err
+	}
+	if len(jarFiles) == 0 {
+		return nil
+	}
+
+	classpathDir := filepath.Join(location, "createdabase", "lib")
+	for _, jarFile := range jarFiles {
+		if err := Common.CopyFile(jarFile, filepath.Join(classpathDir, filepath.Base(jarFile))); err != nil {
+			return err
+		}
+	}
+	color.Green("已将 %d 个依赖加入编译classpath: %s", len(jarFiles), libDir)
+	return nil
+}
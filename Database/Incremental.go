@@ -0,0 +1,414 @@
+package Database
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// incrementalSettings 影响反编译结果的参数，与上一个数据库不同时不能复用其源码
+var incrementalSettings = []string{"decompiler", "line-numbers", "class-fallback", "deobfuscate", "mapping"}
+
+// previousBuild 增量重建参照的上一个数据库
+type previousBuild struct {
+	path       string
+	inputs     map[string]Common.InputDigest     // 输入在制品中的路径 -> 类摘要
+	decompiled map[string]Common.DecompiledInput // 反编译记录，按输入名称索引
+	origins    map[string]*Common.SourceOrigin   // 源码路径（如 src1/com/foo/Bar.java）-> 来源
+	sources    map[string]*zip.File              // 源码路径 -> src.zip中的条目
+	archive    *zip.ReadCloser
+}
+
+// versionedArchive 路径中带版本号的jar、war、ear文件名，如 commons-lang3-3.12.0.jar、app-web-1.2-SNAPSHOT.war
+var versionedArchive = regexp.MustCompile(`-\d[^/!]*(\.(?i:jar|war|ear)!?)$`)
+
+// previous 上一个数据库只在第一次用到时打开，并发反编译的jar共用
+var previous = struct {
+	once  sync.Once
+	build *previousBuild
+}{}
+
+// loadPreviousBuild 返回 -incremental 指定的上一个数据库，未指定或无法复用时返回nil
+func loadPreviousBuild() *previousBuild {
+	previous.once.Do(func() {
+		if Common.IncrementalBase == "" {
+			return
+		}
+		build, err := openPreviousBuild(Common.IncrementalBase)
+		if err != nil {
+			color.Yellow("无法增量重建，将完整反编译: %v", err)
+			return
+		}
+		color.Green("增量重建：参照上一个数据库 %s（%d 个输入，%d 个源码文件）", build.path, len(build.inputs), len(build.sources))
+		previous.build = build
+	})
+	return previous.build
+}
+
+// openPreviousBuild 读取上一个数据库的类摘要、源码来源和src.zip，检查反编译参数是否一致
+func openPreviousBuild(dbPath string) (*previousBuild, error) {
+	info, err := Common.LoadBuildInfo(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil || len(info.Inputs) == 0 || info.Config == nil {
+		return nil, fmt.Errorf("上一个数据库没有记录类摘要和建库参数: %s", dbPath)
+	}
+	current := Common.CurrentConfig()
+	for _, name := range incrementalSettings {
+		if before, now := info.Config.Flags[name], current.Flags[name]; before != now {
+			return nil, fmt.Errorf("参数 -%s 与上一个数据库不同（%s -> %s）", name, before, now)
+		}
+	}
+
+	origins, err := Common.LoadOrigins(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("上一个数据库没有%s", Common.OriginsFileName)
+	}
+	archive, err := zip.OpenReader(filepath.Join(dbPath, "src.zip"))
+	if err != nil {
+		return nil, fmt.Errorf("打开上一个数据库的src.zip失败: %v", err)
+	}
+
+	build := &previousBuild{
+		path:       dbPath,
+		inputs:     make(map[string]Common.InputDigest),
+		decompiled: make(map[string]Common.DecompiledInput),
+		origins:    origins,
+		sources:    make(map[string]*zip.File),
+		archive:    archive,
+	}
+	for _, input := range info.Inputs {
+		build.inputs[input.Input] = input
+	}
+	for _, d := range info.Decompiled {
+		build.decompiled[d.Input] = d
+	}
+	// src.zip中保存的是建库时的绝对路径，取工作目录createdabase之后的部分
+	for _, f := range archive.File {
+		name := "/" + f.Name
+		if i := strings.LastIndex(name, "/createdabase/"); i >= 0 {
+			build.sources[name[i+len("/createdabase/"):]] = f
+		}
+	}
+	return build, nil
+}
+
+// releasePreviousBuild 反编译结束后关闭上一个数据库的src.zip
+func releasePreviousBuild() {
+	if previous.build != nil {
+		previous.build.archive.Close()
+		previous.build.sources = nil
+	}
+}
+
+// counterpart 返回输入在上一个数据库中对应的记录：先找路径相同的输入；制品本身对应上一个制品本身；
+// 否则按去掉版本号的路径配对（如 WEB-INF/lib/foo-1.2.jar 与 foo-1.3.jar），上一个数据库中只有一个时才视为同一个输入。
+// 配对只决定与哪个输入比较，类的源码仍要摘要相同才复用
+func (p *previousBuild) counterpart(digest Common.InputDigest) (Common.InputDigest, bool) {
+	if before, ok := p.inputs[digest.Input]; ok {
+		return before, true
+	}
+	family := inputFamily(digest.Input)
+	var roots, renamed []Common.InputDigest
+	for _, before := range p.inputs {
+		if digest.Root && before.Root {
+			roots = append(roots, before)
+		}
+		if inputFamily(before.Input) == family {
+			renamed = append(renamed, before)
+		}
+	}
+	switch {
+	case len(roots) == 1:
+		return roots[0], true
+	case len(renamed) == 1:
+		return renamed[0], true
+	}
+	return Common.InputDigest{}, false
+}
+
+// inputFamily 去掉输入路径中每一级jar、war、ear文件名的版本号
+func inputFamily(label string) string {
+	parts := strings.Split(label, "/")
+	for i, part := range parts {
+		parts[i] = versionedArchive.ReplaceAllString(part, "$1")
+	}
+	return strings.Join(parts, "/")
+}
+
+// reuseSource 上一个数据库中同一输入的同一个类生成了sourcePath时，把源码写回sourcePath并返回其来源
+func (p *previousBuild) reuseSource(createDir, sourcePath, label, class string) (Common.SourceOrigin, bool) {
+	rel, err := filepath.Rel(createDir, sourcePath)
+	if err != nil {
+		return Common.SourceOrigin{}, false
+	}
+	rel = filepath.ToSlash(rel)
+	origin, ok := p.origins[rel]
+	if !ok || origin.Artifact != label || origin.Class != class {
+		return Common.SourceOrigin{}, false
+	}
+	f, ok := p.sources[rel]
+	if !ok || extractZipEntry(f, sourcePath) != nil {
+		return Common.SourceOrigin{}, false
+	}
+	return *origin, true
+}
+
+// reuseUnchangedSources 记录输入中每个类的摘要；增量重建时把摘要未变化的类的源码从上一个数据库复制到outputDir，
+// input.Path改为只包含变化的类的jar。全部类都已复用、无需反编译时返回false
+func reuseUnchangedSources(location string, input *decompileInput, outputDir, name string) bool {
+	label := originLabel(location, input.Original)
+	digest, err := digestInput(input.Original, label)
+	if err != nil {
+		color.Red("计算 %s 的类摘要失败: %v", label, err)
+		return true
+	}
+	digest.Renamed = input.Remapped
+	digest.Root = isArtifactRoot(location, input.Original)
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.Inputs = append(meta.Inputs, digest)
+	})
+
+	prev := loadPreviousBuild()
+	if prev == nil {
+		return true
+	}
+	classes, err := listTopLevelClasses(input.Path)
+	if err != nil || len(classes) == 0 {
+		return true
+	}
+
+	before, known := prev.counterpart(digest)
+	if known && before.Input != label {
+		fmt.Printf("%s 对应上一个数据库中的 %s\n", label, before.Input)
+	}
+	// 改名按输入中的全部类统一计算，类有增删时未变化的类也可能得到不同的名称，只复用两次都未改名的输入
+	reusable := known && !before.Renamed && !digest.Renamed
+	if known && !reusable {
+		color.Yellow("%s 被去混淆改名，整包重新反编译", label)
+	}
+	createDir := filepath.Join(location, "createdabase")
+	var reused []Common.SourceOrigin
+	changed := make(map[string]bool)
+	for _, class := range classes {
+		original := input.originalClass(class)
+		if reusable && digest.Classes[original] != "" && before.Classes[original] == digest.Classes[original] {
+			sourcePath := filepath.Join(outputDir, filepath.FromSlash(strings.TrimSuffix(class, ".class")+".java"))
+			if origin, ok := prev.reuseSource(createDir, sourcePath, before.Input, original); ok {
+				reused = append(reused, origin)
+				continue
+			}
+		}
+		changed[class] = true
+	}
+
+	if len(changed) > 0 && len(reused) > 0 {
+		dir, err := os.MkdirTemp(location, "incremental-")
+		if err == nil {
+			subset := filepath.Join(dir, strings.TrimSuffix(filepath.Base(input.Path), ".jar")+".jar")
+			err = writeClassSubset(input.Path, subset, func(className string) bool {
+				return changed[className+".class"]
+			})
+			if err == nil {
+				input.Path = subset
+			}
+		}
+		if err != nil {
+			// 整包重新反编译，已写回的源码会被覆盖
+			color.Red("提取 %s 中变化的类失败，将整包反编译: %v", label, err)
+			reused = nil
+		}
+	}
+
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		if meta.Incremental == nil {
+			meta.Incremental = &Common.IncrementalStats{Previous: prev.path}
+		}
+		stats := meta.Incremental
+		stats.ReusedClasses += len(reused)
+		stats.ChangedClasses += len(classes) - len(reused)
+		switch {
+		case !known:
+			stats.NewInputs++
+		case len(changed) == 0:
+			stats.ReusedInputs++
+		default:
+			stats.ChangedInputs++
+		}
+		for i := range reused {
+			reused[i].Artifact = label
+		}
+		meta.Origins = append(meta.Origins, reused...)
+		if d, ok := prev.decompiled[previousName(name, label, before.Input)]; ok && len(changed) == 0 {
+			d.Input = name
+			d.Reused = true
+			meta.Decompiled = append(meta.Decompiled, d)
+		}
+	})
+
+	switch {
+	case len(changed) == 0:
+		fmt.Printf("%s 未变化，复用上一个数据库中的 %d 个源码文件\n", label, len(reused))
+		return false
+	case len(reused) > 0:
+		fmt.Printf("%s: 复用 %d 个未变化的类，重新反编译 %d 个变化的类\n", label, len(reused), len(changed))
+	}
+	return true
+}
+
+// previousName 返回反编译记录在上一个数据库中的名称：记录按输入路径或jar文件名索引，输入改名时换成上一个数据库中的路径或文件名
+func previousName(name, label, previousLabel string) string {
+	switch {
+	case label == previousLabel:
+		return name
+	case name == label:
+		return previousLabel
+	case name == path.Base(label):
+		return path.Base(previousLabel)
+	}
+	return name
+}
+
+// digestInput 计算jar或class目录中每个顶层类（包含其内部类）的SHA-256
+func digestInput(input, label string) (Common.InputDigest, error) {
+	digest := Common.InputDigest{Input: label, Classes: make(map[string]string)}
+	groups := make(map[string][]string) // 顶层类 -> 各class条目的 "摘要  条目名"
+	add := func(name string, r io.Reader) error {
+		if !strings.HasSuffix(name, ".class") || strings.HasPrefix(name, "META-INF/") {
+			return nil
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		top := strings.TrimSuffix(topLevelSource(strings.TrimSuffix(name, ".class")), ".java") + ".class"
+		groups[top] = append(groups[top], hex.EncodeToString(h.Sum(nil))+"  "+name)
+		return nil
+	}
+
+	isDir := Common.IsDirectory(input)
+	if isDir {
+		err := filepath.Walk(input, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(input, p)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return add(filepath.ToSlash(rel), f)
+		})
+		if err != nil {
+			return digest, err
+		}
+	} else {
+		r, err := zip.OpenReader(input)
+		if err != nil {
+			return digest, err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return digest, err
+			}
+			err = add(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return digest, err
+			}
+		}
+	}
+
+	var tops []string
+	for top, entries := range groups {
+		sort.Strings(entries)
+		h := sha256.New()
+		for _, entry := range entries {
+			fmt.Fprintln(h, entry)
+		}
+		digest.Classes[top] = hex.EncodeToString(h.Sum(nil))
+		tops = append(tops, top)
+	}
+
+	if !isDir {
+		sum, _, err := fileHash(input)
+		digest.SHA256 = sum
+		return digest, err
+	}
+	sort.Strings(tops)
+	h := sha256.New()
+	for _, top := range tops {
+		fmt.Fprintf(h, "%s  %s\n", digest.Classes[top], top)
+	}
+	digest.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return digest, nil
+}
+
+// writeClassSubset 将jar或class目录中keep返回true的顶层类（含内部类）写入新的jar
+func writeClassSubset(input, destJar string, keep func(className string) bool) error {
+	if !Common.IsDirectory(input) {
+		return filterJarClasses(input, destJar, keep)
+	}
+
+	out, err := os.Create(destJar)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := zip.NewWriter(out)
+
+	err = filepath.Walk(input, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".class") {
+			return err
+		}
+		rel, err := filepath.Rel(input, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !keep(strings.TrimSuffix(topLevelSource(strings.TrimSuffix(name, ".class")), ".java")) {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		dst, err := w.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = dst.Write(data)
+		return err
+	})
+	if err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,152 @@
+package Database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"codeql_n1ght/Common"
+)
+
+func writeTestJar(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, zipBytes(t, entries), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInputFamily(t *testing.T) {
+	tests := map[string]string{
+		"app-1.2.0.jar":                              "app.jar",
+		"WEB-INF/lib/commons-lang3-3.12.0.jar":       "WEB-INF/lib/commons-lang3.jar",
+		"app-web-1.2-SNAPSHOT.war/WEB-INF/classes":   "app-web.war/WEB-INF/classes",
+		"WEB-INF/lib/outer-2.0.jar!/lib/inner-1.jar": "WEB-INF/lib/outer.jar!/lib/inner.jar",
+		"WEB-INF/classes":                            "WEB-INF/classes",
+		"WEB-INF/lib/log4j-api.jar":                  "WEB-INF/lib/log4j-api.jar",
+	}
+	for label, want := range tests {
+		if got := inputFamily(label); got != want {
+			t.Errorf("inputFamily(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestPreviousBuildCounterpart(t *testing.T) {
+	p := &previousBuild{inputs: map[string]Common.InputDigest{
+		"app-1.2.0.jar":             {Input: "app-1.2.0.jar", Root: true},
+		"WEB-INF/lib/foo-1.0.jar":   {Input: "WEB-INF/lib/foo-1.0.jar"},
+		"WEB-INF/lib/bar-1.0.jar":   {Input: "WEB-INF/lib/bar-1.0.jar"},
+		"WEB-INF/lib/bar-1.0.1.jar": {Input: "WEB-INF/lib/bar-1.0.1.jar"},
+	}}
+	tests := []struct {
+		digest Common.InputDigest
+		want   string
+	}{
+		{Common.InputDigest{Input: "application.jar", Root: true}, "app-1.2.0.jar"},
+		{Common.InputDigest{Input: "WEB-INF/lib/foo-1.1.jar"}, "WEB-INF/lib/foo-1.0.jar"},
+		{Common.InputDigest{Input: "WEB-INF/lib/bar-1.0.jar"}, "WEB-INF/lib/bar-1.0.jar"},
+		// 上一个数据库中有两个同名的jar，无法确定对应哪一个
+		{Common.InputDigest{Input: "WEB-INF/lib/bar-2.0.jar"}, ""},
+		{Common.InputDigest{Input: "WEB-INF/lib/baz-1.0.jar"}, ""},
+	}
+	for _, tt := range tests {
+		got, ok := p.counterpart(tt.digest)
+		if got.Input != tt.want || ok != (tt.want != "") {
+			t.Errorf("counterpart(%s) = %q, %v, want %q", tt.digest.Input, got.Input, ok, tt.want)
+		}
+	}
+}
+
+// TestReuseUnchangedSourcesAcrossVersions 普通jar升级版本（文件名变化）后，未变化的类复用上一个数据库的源码，只反编译变化的类
+func TestReuseUnchangedSourcesAcrossVersions(t *testing.T) {
+	dir := t.TempDir()
+	app := greetingClass("hello", true, 10)
+	oldUtil, newUtil := greetingClass("old", true, 10), greetingClass("new", true, 10)
+	oldJar := filepath.Join(dir, "artifacts", "app-1.2.0.jar")
+	newJar := filepath.Join(dir, "artifacts", "app-1.2.1.jar")
+	writeTestJar(t, oldJar, map[string][]byte{"com/acme/App.class": app, "com/acme/Util.class": oldUtil})
+	writeTestJar(t, newJar, map[string][]byte{"com/acme/App.class": app, "com/acme/Util.class": newUtil})
+
+	// 上一个数据库：类摘要、源码来源和src.zip（保存建库时的绝对路径）
+	dbPath := filepath.Join(dir, "databases", "app-1.2.0.jar")
+	digest, err := digestInput(oldJar, "app-1.2.0.jar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest.Root = true
+	info := Common.BuildInfo{
+		Config:     &Common.EffectiveConfig{Flags: map[string]string{}},
+		Inputs:     []Common.InputDigest{digest},
+		Decompiled: []Common.DecompiledInput{{Input: "app-1.2.0.jar", Decompiler: "cfr"}},
+	}
+	data, _ := json.Marshal(info)
+	if err := os.MkdirAll(dbPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dbPath, Common.MetadataFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	origins := []Common.SourceOrigin{
+		{Source: "src1/com/acme/App.java", Artifact: "app-1.2.0.jar", Class: "com/acme/App.class", Decompiler: "cfr"},
+		{Source: "src1/com/acme/Util.java", Artifact: "app-1.2.0.jar", Class: "com/acme/Util.class", Decompiler: "cfr"},
+	}
+	if err := Common.WriteOrigins(dbPath, origins); err != nil {
+		t.Fatal(err)
+	}
+	writeTestJar(t, filepath.Join(dbPath, "src.zip"), map[string][]byte{
+		"tmp/build-1/createdabase/src1/com/acme/App.java":  []byte("class App {}\n"),
+		"tmp/build-1/createdabase/src1/com/acme/Util.java": []byte("class Util { old }\n"),
+	})
+
+	prev, err := openPreviousBuild(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.once.Do(func() {})
+	previous.build = prev
+	defer func() {
+		releasePreviousBuild()
+		previous.build = nil
+		previous.once = sync.Once{}
+	}()
+
+	location := filepath.Join(dir, "work")
+	if err := os.MkdirAll(location, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer discardMetadata(location)
+	src1 := filepath.Join(location, "createdabase", "src1")
+	input := &decompileInput{Original: newJar, Path: newJar}
+	if !reuseUnchangedSources(location, input, src1, "app-1.2.1.jar") {
+		t.Fatal("changed class was not left for decompilation")
+	}
+
+	if data, err := os.ReadFile(filepath.Join(src1, "com", "acme", "App.java")); err != nil || string(data) != "class App {}\n" {
+		t.Errorf("App.java = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(src1, "com", "acme", "Util.java")); !os.IsNotExist(err) {
+		t.Errorf("changed Util.java was reused: %v", err)
+	}
+	classes, err := listTopLevelClasses(input.Path)
+	if err != nil || len(classes) != 1 || classes[0] != "com/acme/Util.class" {
+		t.Errorf("decompile input %s = %v, %v", input.Path, classes, err)
+	}
+
+	var meta DatabaseMetadata
+	updateMetadata(location, func(m *DatabaseMetadata) { meta = *m })
+	if len(meta.Inputs) != 1 || !meta.Inputs[0].Root || meta.Inputs[0].Input != "app-1.2.1.jar" {
+		t.Errorf("inputs = %+v", meta.Inputs)
+	}
+	if len(meta.Origins) != 1 || meta.Origins[0].Artifact != "app-1.2.1.jar" || meta.Origins[0].Class != "com/acme/App.class" {
+		t.Errorf("origins = %+v", meta.Origins)
+	}
+	want := Common.IncrementalStats{Previous: dbPath, ChangedInputs: 1, ReusedClasses: 1, ChangedClasses: 1}
+	if meta.Incremental == nil || *meta.Incremental != want {
+		t.Errorf("incremental = %+v, want %+v", meta.Incremental, want)
+	}
+}
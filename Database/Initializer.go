@@ -2,127 +2,281 @@ package Database
 
 import (
 	"codeql_n1ght/Common"
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/fatih/color"
 )
 
-// Init 初始化数据库创建流程
-func Init(jar string) {
-	pwd, _ := os.Getwd()
-	jar = filepath.Join(pwd, jar)
+// Init 初始化数据库创建流程，在独立的工作目录中建库后输出到-out指定的路径
+func Init(ctx context.Context, jar string) error {
+	jar, _ = filepath.Abs(jar)
 	if !Common.FileExists(jar) {
 		color.Red("Jar file not found")
-		return
+		return fmt.Errorf("文件不存在: %s", jar)
 	}
-	location := filepath.Dir(jar)
-	color.Green("Jar file found")
 
-	// 清理旧文件
-	cleanupOldFiles(location)
+	dbPath := Common.DatabaseOutPath
+	if dbPath == "" {
+		dbPath = Common.DefaultDatabasePath(jar)
+	}
+	dbPath, _ = filepath.Abs(dbPath)
+
+	location, err := Common.NewRunWorkspace(filepath.Base(jar))
+	if err != nil {
+		return err
+	}
+	color.Green("工作目录: %s", location)
 
-	// 解压jar包
-	Common.ExtractZip(jar, filepath.Join(location, "output"))
 	Common.SetupEnvironment()
+	if err := checkDecompilerRuntime(); err != nil {
+		Common.RemoveFile(location)
+		return err
+	}
+	return Build(ctx, jar, location, dbPath)
+}
+
+// Build 在工作目录location下完成解压、反编译和建库，最终数据库移动到dbPath，调用前需先执行Common.SetupEnvironment
+func Build(ctx context.Context, jar, location, dbPath string) (err error) {
+	jar, _ = filepath.Abs(jar)
+	defer discardMetadata(location)
+	defer forgetNestedJars(location)
+	started := time.Now()
+	defer func() {
+		if err != nil {
+			handleFailedBuild(ctx, jar, location, dbPath, started)
+		}
+	}()
+	if !Common.FileExists(jar) {
+		color.Red("Jar file not found")
+		return fmt.Errorf("文件不存在: %s", jar)
+	}
+	if err := checkDatabaseOutput(dbPath); err != nil {
+		return err
+	}
+	recordArtifact(location, jar)
+	enterStage(location, stageExtract)
+	isDir := Common.IsDirectory(jar)
+	color.Green("Jar file found")
+
+	// 解压jar包；目录输入（解压后的webapp或class目录）直接复制
+	if isDir {
+		if err := Common.CopyDirectory(jar, filepath.Join(location, "output")); err != nil {
+			return fmt.Errorf("复制输入目录失败: %v", err)
+		}
+	} else {
+		Common.ExtractZip(jar, filepath.Join(location, "output"))
+	}
 	color.Green("解压完成")
 
 	// 设置创建数据库目录
 	setupDatabaseDirectory(location)
 
 	// 生成构建文件
-	err := GenerateBuildXML(filepath.Join(location, "createdabase"))
+	err = GenerateBuildXML(filepath.Join(location, "createdabase"))
 	if err != nil {
-		color.Red("Generate build.xml failed: %v", err)
-		return
+		return fmt.Errorf("Generate build.xml failed: %v", err)
 	}
 
 	// 创建必要的目录
 	createDirectories(location)
 
-	// 检查是否为war包，如果是则特殊处理
-	if filepath.Ext(jar) == ".war" {
-		// 对于war包，直接反编译classes目录和JSP文件
-		outputDir := filepath.Join(location, "output")
-		src1Dir := filepath.Join(location, "createdabase", "src1")
-
-		// 反编译BOOT-INF/classes目录
-		classesDir := filepath.Join(outputDir, "BOOT-INF", "classes")
-		if _, err := os.Stat(classesDir); err == nil {
-			color.Green("开始反编译BOOT-INF/classes目录")
-			err := DecompileJava("-cp", "tools/java-decompiler.jar",
-				"org.jetbrains.java.decompiler.main.decompiler.ConsoleDecompiler",
-				"-dgs=true", "-hdc=0", "-dgs=1", "-rsy=1", "-rbr=1", "-lit=1", "-nls=1", "-mpm=60",
-				classesDir, src1Dir)
-			if err != nil {
-				color.Red("BOOT-INF/classes目录反编译失败: %v", err)
-				return
-			}
-			color.Green("BOOT-INF/classes目录反编译完成")
-		}
+	src1Dir := filepath.Join(location, "createdabase", "src1")
 
-		// 检查传统WAR包的WEB-INF/classes目录
-		webInfClassesDir := filepath.Join(outputDir, "WEB-INF", "classes")
-		if _, err := os.Stat(webInfClassesDir); err == nil {
-			color.Green("开始反编译WEB-INF/classes目录")
-			err := DecompileJava("-cp", "tools/java-decompiler.jar",
-				"org.jetbrains.java.decompiler.main.decompiler.ConsoleDecompiler",
-				"-dgs=true", "-hdc=0", "-dgs=1", "-rsy=1", "-rbr=1", "-lit=1", "-nls=1", "-mpm=60",
-				webInfClassesDir, src1Dir)
-			if err != nil {
-				color.Red("WEB-INF/classes目录反编译失败: %v", err)
-				return
-			}
-			color.Green("WEB-INF/classes目录反编译完成")
-		}
+	outputDir := filepath.Join(location, "output")
+	layout := detectInputLayout(outputDir, jar, isDir)
 
-		// 反编译JSP文件
-		color.Green("反编译JSP文件: ")
-		err := DecompileJava("-jar", "tools/jsp2class.jar", outputDir, src1Dir)
-		if err != nil {
-			color.Red("JSP文件反编译失败 %s: %v", err)
-			// JSP反编译失败不影响整体流程，继续执行
+	enterStage(location, stageDecompile)
+	if layout != layoutEar {
+		// EAR在解压各Web模块后再生成
+		prepareDeobfuscation(location)
+	}
+	// 根据包类型选择反编译方式
+	switch layout {
+	case layoutEar:
+		// 对于ear包，按application.xml逐个处理其中的模块
+		if err := decompileEar(ctx, location); err != nil {
+			return fmt.Errorf("EAR包处理失败: %v", err)
 		}
-		color.Green("反编译JSP文件: 完成")
-	} else {
+	case layoutWeb:
+		// 对于war包和Spring Boot可执行包，直接反编译classes目录和JSP文件
+		if _, ok := detectSpringBootLayout(outputDir); ok {
+			color.Green("检测到Spring Boot包结构（MANIFEST.MF）")
+		}
+		if err := decompileWebModule(ctx, location, outputDir, src1Dir); err != nil {
+			return err
+		}
+	case layoutClasses:
+		// 对于裸class目录，只反编译其中的class文件
+		if err := decompileClassTree(ctx, location, outputDir, src1Dir); err != nil {
+			return fmt.Errorf("class目录反编译失败: %v", err)
+		}
+	default:
 		// 对于普通jar包，使用原有逻辑
-		DecompileJava("-jar", "tools/procyon-decompiler-0.6.0.jar", jar, "-o", filepath.Join(location, "createdabase", "src1"))
+		decompileJarFile(ctx, location, jar, src1Dir, filepath.Base(jar))
+	}
+
+	if Common.Interrupted(ctx) {
+		return context.Cause(ctx)
 	}
 
 	// 反编译依赖到src1
-	DecompileLibraries(location)
+	enterStage(location, stageDependencies)
+	DecompileLibraries(ctx, location)
+	releasePreviousBuild()
+	if Common.Interrupted(ctx) {
+		return context.Cause(ctx)
+	}
 
 	// 复制额外源码目录到src1（如果指定了的话）
-	src1Dir := filepath.Join(location, "createdabase", "src1")
+	enterStage(location, stageResources)
 	if err := Common.CopyExtraSourceToSrc1(Common.ExtraSourceDir, src1Dir); err != nil {
-		color.Red("复制额外源码失败: %v", err)
-		return
+		return fmt.Errorf("复制额外源码失败: %v", err)
+	}
+
+	// 复制XML、properties和JSP等资源文件到源码根目录
+	if Common.IncludeResources {
+		collectResources(location)
 	}
 
 	// 清理可能导致编译失败的文件
 	cleanupProblematicFiles(location)
 
 	// 创建数据库
-	Createdatabase(filepath.Join(location, "createdabase"))
+	enterStage(location, stageCreate)
+	if err := Createdatabase(ctx, filepath.Join(location, "createdabase")); err != nil {
+		return err
+	}
+	recordBuildInfo(location, started)
+	enterStage(location, stageFinalize)
+
+	// 根据解压出的依赖生成SBOM并匹配本地漏洞库
+	if Common.GenerateSbom || Common.VulnFeedPath != "" {
+		components := collectSbomComponents(outputDir)
+		if Common.GenerateSbom {
+			if err := generateSbom(jar, dbPath, components); err != nil {
+				color.Red("生成SBOM失败: %v", err)
+			}
+		}
+		if err := writeVulnerabilityReport(location, dbPath, components); err != nil {
+			color.Red("依赖漏洞匹配失败: %v", err)
+		}
+	}
 
 	// 移动和清理文件
-	finalizeDatabaseCreation(location)
+	return finalizeDatabaseCreation(location, dbPath)
 }
 
-// cleanupOldFiles 清理旧文件
-func cleanupOldFiles(location string) {
-	color.Red("删除output")
-	color.Red("删除src")
-	Common.RemoveFile(filepath.Join(location, "output"))
-	Common.RemoveFile(filepath.Join(location, "src"))
+// decompileClassesDir 使用-decompiler指定的反编译器反编译class目录
+func decompileClassesDir(ctx context.Context, location, classesDir, src1Dir string) error {
+	parent := ctx
+	ctx, cancel := Common.WithTimeout(ctx, "反编译 "+originLabel(location, classesDir), Common.DecompileTimeout)
+	defer cancel()
+	label := originLabel(location, classesDir)
+	input := prepareDecompileInput(location, classesDir)
+	if !reuseUnchangedSources(location, input, src1Dir, label) {
+		return nil
+	}
+	if !Common.ClassFallback {
+		used := decompileWithFallback(ctx, location, input.Path, src1Dir, label)
+		if used == nil {
+			return classesDirFailure(parent, ctx, classesDir)
+		}
+		recordOrigins(location, input, src1Dir, used)
+		return nil
+	}
+
+	// 开启逐类回退时与jar相同，先反编译到暂存目录，修复失败的类后再合并
+	stageDir := filepath.Join(filepath.Dir(src1Dir), ".stage", filepath.FromSlash(label))
+	defer os.RemoveAll(stageDir)
+
+	used := decompileWithFallback(ctx, location, input.Path, stageDir, label)
+	if used == nil {
+		return classesDirFailure(parent, ctx, classesDir)
+	}
+	repairFailedClasses(ctx, input.Path, stageDir, used)
+
+	if err := copyDir(stageDir, src1Dir); err != nil {
+		return fmt.Errorf("合并 %s 的反编译结果失败: %v", label, err)
+	}
+	recordOrigins(location, input, src1Dir, used)
+	return nil
+}
+
+// classesDirFailure class目录反编译失败时的返回值：超过-decompile-timeout已记入decompileFailures，跳过该目录继续建库；
+// 反编译器出错或建库被中断时返回错误
+func classesDirFailure(parent, ctx context.Context, classesDir string) error {
+	if !Common.Interrupted(parent) && Common.IsTimeout(context.Cause(ctx)) {
+		return nil
+	}
+	return fmt.Errorf("所有反编译器均无法反编译: %s", classesDir)
+}
+
+// decompileWebModule 反编译Web模块（WAR结构）的classes目录并编译JSP文件
+func decompileWebModule(ctx context.Context, location, outputDir, src1Dir string) error {
+	// 反编译Spring Boot的classes目录（优先使用MANIFEST.MF中声明的路径）
+	classesDir := filepath.Join(outputDir, "BOOT-INF", "classes")
+	if layout, ok := detectSpringBootLayout(outputDir); ok {
+		classesDir = layout.ClassesDir
+	}
+	if _, err := os.Stat(classesDir); err == nil {
+		name := classesDirLabel(outputDir, classesDir)
+		color.Green("开始反编译%s目录", name)
+		err := decompileClassesDir(ctx, location, classesDir, src1Dir)
+		if err != nil {
+			color.Red("%s目录反编译失败: %v", name, err)
+			return err
+		}
+		color.Green("%s目录反编译完成", name)
+	}
+
+	// 检查传统WAR包的WEB-INF/classes目录，Spring Boot WAR声明的classes目录就是它时不再重复反编译
+	webInfClassesDir := filepath.Join(outputDir, "WEB-INF", "classes")
+	if _, err := os.Stat(webInfClassesDir); err == nil && filepath.Clean(webInfClassesDir) != filepath.Clean(classesDir) {
+		color.Green("开始反编译WEB-INF/classes目录")
+		err := decompileClassesDir(ctx, location, webInfClassesDir, src1Dir)
+		if err != nil {
+			color.Red("WEB-INF/classes目录反编译失败: %v", err)
+			return err
+		}
+		color.Green("WEB-INF/classes目录反编译完成")
+	}
+
+	// 编译JSP文件
+	color.Green("编译JSP文件: ")
+	if err := compileJsps(ctx, location, outputDir, src1Dir); err != nil {
+		color.Red("JSP文件编译失败 %s: %v", outputDir, err)
+		// JSP编译失败不影响整体流程，继续执行
+	}
+	color.Green("编译JSP文件: 完成")
+	return nil
+}
+
+// classesDirLabel 返回classes目录相对解压目录的路径，用于日志
+func classesDirLabel(outputDir, classesDir string) string {
+	if rel, err := filepath.Rel(outputDir, classesDir); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return classesDir
+}
+
+// checkDatabaseOutput 检查输出路径，只允许覆盖已有的CodeQL数据库
+func checkDatabaseOutput(dbPath string) error {
+	if !Common.FileExists(dbPath) {
+		return nil
+	}
+	if !Common.IsCodeQLDatabase(dbPath) {
+		return fmt.Errorf("输出路径已存在且不是CodeQL数据库，拒绝覆盖: %s", dbPath)
+	}
+	color.Yellow("将覆盖已有数据库: %s", dbPath)
+	return nil
 }
 
 // setupDatabaseDirectory 设置数据库目录
 func setupDatabaseDirectory(location string) {
-	if Common.FileExists(filepath.Join(location, "createdabase")) {
-		color.Red("已删除存在的createdatabase")
-		os.Remove(filepath.Join(location, "createdabase"))
-	}
 	os.Mkdir(filepath.Join(location, "createdabase"), 0755)
 }
 
@@ -131,6 +285,7 @@ func createDirectories(location string) {
 	os.Mkdir(filepath.Join(location, "createdabase", "src1"), 0755)
 	os.Mkdir(filepath.Join(location, "createdabase", "src2"), 0755)
 	os.Mkdir(filepath.Join(location, "createdabase", "build_classes"), 0755)
+	os.Mkdir(filepath.Join(location, "createdabase", "lib"), 0755)
 }
 
 // cleanupProblematicFiles 清理可能导致编译失败的文件
@@ -179,23 +334,38 @@ func cleanupProblematicFiles(location string) {
 }
 
 // finalizeDatabaseCreation 完成数据库创建的最后步骤
-func finalizeDatabaseCreation(location string) {
-	Common.RemoveFile(filepath.Join(location, "temp"))
+func finalizeDatabaseCreation(location, dbPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	if Common.IsCodeQLDatabase(dbPath) {
+		Common.RemoveFile(dbPath)
+	}
 
-	err := os.Rename(filepath.Join(location, "createdabase", "temp"), filepath.Join(location, "temp"))
+	err := os.Rename(filepath.Join(location, "createdabase", "temp"), dbPath)
 	if err != nil {
-		color.Red("移动失败:", err)
-	} else {
-		color.Green("数据库移动成功")
+		// 工作目录与输出路径不在同一文件系统时改为复制
+		err = Common.CopyDirectory(filepath.Join(location, "createdabase", "temp"), dbPath)
+	}
+	if err != nil {
+		color.Red("移动失败: %v", err)
+		return fmt.Errorf("移动数据库失败: %v", err)
+	}
+	color.Green("数据库移动成功: %s", dbPath)
+	// 之前中断留下的快照已经没有意义
+	os.Remove(Common.InterruptedStatePath(dbPath))
+
+	if err := writeMetadata(location, dbPath); err != nil {
+		color.Red("写入数据库元数据失败: %v", err)
 	}
 
 	if Common.KeepTempFiles {
-		color.Yellow("保留临时文件模式：跳过清理output和createdatabase目录")
+		color.Yellow("保留临时文件模式：工作目录保留在 %s", location)
 		color.Green("数据库生成完成")
 	} else {
-		color.Green("数据库生成完成，删除output和createdatabase")
-		Common.RemoveFile(filepath.Join(location, "createdabase"))
-		Common.RemoveFile(filepath.Join(location, "output"))
+		color.Green("数据库生成完成，删除工作目录")
+		Common.RemoveFile(location)
 		color.Green("删除成功")
 	}
+	return nil
 }
@@ -0,0 +1,76 @@
+package Database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// 建库的阶段，中断时写入快照
+const (
+	stageExtract      = "extract"
+	stageDecompile    = "decompile"
+	stageDependencies = "dependencies"
+	stageResources    = "resources"
+	stageCreate       = "create-database"
+	stageFinalize     = "finalize"
+)
+
+// enterStage 记录建库进入的阶段，上一个阶段记为已完成
+func enterStage(location, stage string) {
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		if meta.stage != "" {
+			meta.completedStages = append(meta.completedStages, meta.stage)
+		}
+		meta.stage = stage
+	})
+}
+
+// handleFailedBuild 建库失败时清理工作目录（指定-keep-temp时保留），因中断而失败时先写入 <数据库>.interrupted.json，需在discardMetadata之前执行
+func handleFailedBuild(ctx context.Context, jar, location, dbPath string, started time.Time) {
+	if Common.Interrupted(ctx) {
+		writeInterruptedSnapshot(ctx, jar, location, dbPath, started)
+	}
+
+	if Common.KeepTempFiles {
+		color.Yellow("保留临时文件模式：工作目录保留在 %s", location)
+		return
+	}
+	Common.RemoveFile(location)
+	color.Yellow("已删除工作目录: %s", location)
+}
+
+// writeInterruptedSnapshot 记录中断时的阶段、已完成的阶段和已反编译的输入
+func writeInterruptedSnapshot(ctx context.Context, jar, location, dbPath string, started time.Time) {
+	snapshot := &Common.StageSnapshot{
+		Operation:     "database",
+		Target:        jar,
+		Output:        dbPath,
+		Workspace:     location,
+		WorkspaceKept: Common.KeepTempFiles,
+		Reason:        Common.InterruptReason(ctx),
+		StartedAt:     started,
+		InterruptedAt: time.Now(),
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		snapshot.Stage = meta.stage
+		snapshot.Completed = append([]string(nil), meta.completedStages...)
+		for _, d := range meta.Decompiled {
+			snapshot.Finished = append(snapshot.Finished, d.Input)
+		}
+	})
+
+	statePath := Common.InterruptedStatePath(dbPath)
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err == nil {
+		if err := Common.WriteStageSnapshot(statePath, snapshot); err != nil {
+			color.Red("写入中断快照失败: %v", err)
+		} else {
+			color.Yellow("建库在 %s 阶段被中断，快照: %s", snapshot.Stage, statePath)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package Database
+
+import (
+	"strings"
+)
+
+// 依赖jar的归属分类
+const (
+	categoryFirstParty = "first-party"
+	categoryThirdParty = "third-party"
+	categoryUnknown    = "unknown"
+)
+
+// knownThirdPartyGroups 常见公开构件的groupId/包名前缀
+var knownThirdPartyGroups = []string{
+	"org.springframework", "org.apache", "com.fasterxml", "com.google", "io.netty",
+	"org.hibernate", "ch.qos.logback", "org.slf4j", "commons-", "junit", "org.junit",
+	"org.mybatis", "com.alibaba", "org.yaml", "org.jboss", "javax.", "jakarta.",
+	"org.eclipse", "org.glassfish", "io.micrometer", "org.aspectj", "net.bytebuddy",
+	"org.bouncycastle", "mysql", "com.mysql", "org.postgresql", "com.h2database",
+	"redis.clients", "io.lettuce", "org.projectlombok", "com.zaxxer", "org.thymeleaf",
+	"io.swagger", "org.freemarker", "com.squareup", "org.json", "dom4j", "org.dom4j",
+	"cn.hutool", "com.baomidou", "org.quartz-scheduler", "log4j", "io.projectreactor",
+	"org.reactivestreams", "com.github.pagehelper", "org.javassist", "cglib",
+	"org.ow2.asm", "com.sun.", "org.codehaus", "net.sf", "xml-apis", "xerces",
+	"org.mockito", "org.hamcrest", "org.jetbrains", "org.checkerframework",
+	"com.thoughtworks.xstream", "org.attoparser", "org.unbescape", "antlr",
+	"com.oracle", "org.webjars", "io.jsonwebtoken", "com.auth0", "org.apache.shiro",
+}
+
+// knownThirdPartyJarPrefixes 没有pom.properties时按jar文件名识别的常见构件
+var knownThirdPartyJarPrefixes = []string{
+	"spring-", "commons-", "jackson-", "log4j", "slf4j-", "logback-", "guava-",
+	"netty-", "tomcat-", "hibernate-", "mybatis", "fastjson", "fastjson2", "druid", "poi-",
+	"aspectjweaver", "aspectjrt", "aspectjtools", "javax.", "jakarta.", "jboss-", "snakeyaml", "gson-", "httpclient",
+	"httpcore", "mysql-connector", "postgresql-", "h2-", "jedis-", "lettuce-",
+	"httpclient5", "httpcore5", "lombok", "HikariCP", "thymeleaf", "swagger-", "freemarker", "okhttp", "okio",
+	"dom4j", "hutool-", "quartz", "reactor-", "byte-buddy", "bcprov", "bcpkix",
+	"asm-", "cglib", "javassist", "xstream", "shiro-", "jjwt", "antlr", "antlr4", "junit",
+	"mockito", "hamcrest", "xercesImpl", "xml-apis", "el-api", "jsp-api", "servlet-api",
+	"validation-api", "micrometer-", "classmate", "jboss-logging", "ojdbc6", "ojdbc8", "ojdbc10", "ojdbc11",
+}
+
+// knownThirdPartyVendors MANIFEST.MF中常见的公开构件发布方
+var knownThirdPartyVendors = []string{
+	"apache software foundation", "pivotal", "vmware", "spring", "oracle",
+	"eclipse", "fasterxml", "qos.ch", "google", "red hat", "jetbrains", "alibaba",
+	"sun microsystems", "glassfish", "jboss", "hibernate", "mybatis", "netty",
+	"bouncy castle", "legion of the bouncy castle", "the guava authors",
+}
+
+// classifyJar 根据包名、groupId、发布方和已知构件列表判断依赖归属，appGroups为主程序自身pom.properties中的groupId
+func classifyJar(info *jarInfo, appPrefixes, appGroups []string) (string, string) {
+	if info == nil {
+		return categoryUnknown, "无法读取jar"
+	}
+
+	// 已知公开构件的groupId优先，避免主程序前缀与框架包名重叠时误判为自有代码
+	for _, coord := range info.Coordinates {
+		if group, ok := matchKnownPrefix(coord.GroupID, knownThirdPartyGroups); ok {
+			return categoryThirdParty, "已知groupId " + group
+		}
+	}
+
+	// 包名与主程序相同的视为自有代码
+	if prefix, ok := sharesPackagePrefix(info.Packages, appPrefixes); ok {
+		return categoryFirstParty, "包名与主程序前缀相同 " + prefix
+	}
+	for _, coord := range info.Coordinates {
+		for _, prefix := range appPrefixes {
+			if coord.GroupID == prefix || strings.HasPrefix(coord.GroupID, prefix+".") {
+				return categoryFirstParty, "groupId与主程序前缀相同 " + coord.GroupID
+			}
+		}
+		// 包名前缀取三段，com.acme.common与com.acme.shop不同，但都在主程序的groupId com.acme之下
+		for _, group := range appGroups {
+			if coord.GroupID == group || strings.HasPrefix(coord.GroupID, group+".") {
+				return categoryFirstParty, "groupId与主程序groupId相同 " + coord.GroupID
+			}
+		}
+	}
+
+	// 其他已知的公开构件
+	if prefix, ok := matchKnownPrefix(info.Name, knownThirdPartyJarPrefixes); ok {
+		return categoryThirdParty, "已知构件 " + prefix
+	}
+	if vendor := manifestVendor(info.Manifest); vendor != "" {
+		lower := strings.ToLower(vendor)
+		for _, known := range knownThirdPartyVendors {
+			if strings.Contains(lower, known) {
+				return categoryThirdParty, "已知发布方 " + vendor
+			}
+		}
+	}
+	for _, pkg := range info.Packages {
+		if group, ok := matchKnownPrefix(pkg, knownThirdPartyGroups); ok {
+			return categoryThirdParty, "已知包名 " + group
+		}
+	}
+
+	return categoryUnknown, "未命中已知构件"
+}
+
+// matchKnownPrefix 判断值是否以列表中的某个前缀开头，前缀之后须为 . 或 - 分隔（或前缀本身以分隔符结尾），
+// 避免com.google匹配com.googlex、mysql匹配mysqlfoo
+func matchKnownPrefix(value string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(value, prefix) {
+			continue
+		}
+		if len(value) == len(prefix) || strings.HasSuffix(prefix, ".") || strings.HasSuffix(prefix, "-") {
+			return prefix, true
+		}
+		if next := value[len(prefix)]; next == '.' || next == '-' {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// manifestVendor 读取MANIFEST.MF中的发布方信息
+func manifestVendor(manifest map[string]string) string {
+	for _, key := range []string{"Implementation-Vendor", "Bundle-Vendor", "Specification-Vendor", "Implementation-Vendor-Id"} {
+		if vendor := manifest[key]; vendor != "" {
+			return vendor
+		}
+	}
+	return ""
+}
+
+// classifyDependencies 对依赖jar逐个分类并记录到数据库元数据
+func classifyDependencies(location string, jarFiles []string, appPrefixes, appGroups []string) map[string]DependencyClassification {
+	result := make(map[string]DependencyClassification, len(jarFiles))
+	classifications := make([]DependencyClassification, 0, len(jarFiles))
+
+	for _, jarFile := range jarFiles {
+		info, err := inspectJar(jarFile)
+		if err != nil {
+			info = nil
+		}
+		category, reason := classifyJar(info, appPrefixes, appGroups)
+
+		item := DependencyClassification{
+			Name:     dependencyName(jarFile),
+			Category: category,
+			Reason:   reason,
+		}
+		if info != nil {
+			for _, coord := range info.Coordinates {
+				item.Coordinates = append(item.Coordinates, coord.String())
+			}
+		}
+		result[item.Name] = item
+		classifications = append(classifications, item)
+	}
+
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.Dependencies = classifications
+	})
+	return result
+}
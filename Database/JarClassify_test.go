@@ -0,0 +1,27 @@
+package Database
+
+import "testing"
+
+func TestClassifyJar(t *testing.T) {
+	appPrefixes := []string{"com.acme.shop"}
+	appGroups := []string{"com.acme"}
+	tests := []struct {
+		name string
+		info *jarInfo
+		want string
+	}{
+		{"internal jar under the app groupId", &jarInfo{Name: "common-1.0.jar", Coordinates: []mavenCoordinate{{GroupID: "com.acme.common", ArtifactID: "common"}}, Packages: []string{"com.acme.common.util"}}, categoryFirstParty},
+		{"same groupId as the app", &jarInfo{Name: "core-1.0.jar", Coordinates: []mavenCoordinate{{GroupID: "com.acme", ArtifactID: "core"}}}, categoryFirstParty},
+		{"similar groupId outside the app", &jarInfo{Name: "x-1.0.jar", Coordinates: []mavenCoordinate{{GroupID: "com.acmesoft", ArtifactID: "x"}}}, categoryUnknown},
+		{"known groupId", &jarInfo{Name: "guava-31.0.jar", Coordinates: []mavenCoordinate{{GroupID: "com.google.guava", ArtifactID: "guava"}}}, categoryThirdParty},
+		{"known prefix without boundary", &jarInfo{Name: "x-1.0.jar", Coordinates: []mavenCoordinate{{GroupID: "com.googlex", ArtifactID: "x"}}}, categoryUnknown},
+		{"known jar name", &jarInfo{Name: "mysql-connector-java-8.0.jar"}, categoryThirdParty},
+		{"jar name sharing a known prefix", &jarInfo{Name: "mysqlfoo-1.0.jar"}, categoryUnknown},
+		{"jar name with version suffix", &jarInfo{Name: "ojdbc8.jar"}, categoryThirdParty},
+	}
+	for _, tt := range tests {
+		if got, reason := classifyJar(tt.info, appPrefixes, appGroups); got != tt.want {
+			t.Errorf("%s: classifyJar = %s (%s), want %s", tt.name, got, reason, tt.want)
+		}
+	}
+}
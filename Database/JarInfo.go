@@ -0,0 +1,109 @@
+package Database
+
+import (
+	"archive/zip"
+	"bufio"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// mavenCoordinate jar包中pom.properties记录的Maven坐标
+type mavenCoordinate struct {
+	GroupID    string
+	ArtifactID string
+	Version    string
+}
+
+// String 返回groupId:artifactId:version形式的坐标
+func (c mavenCoordinate) String() string {
+	return c.GroupID + ":" + c.ArtifactID + ":" + c.Version
+}
+
+// jarInfo 依赖jar包的元数据
+type jarInfo struct {
+	Path        string
+	Name        string
+	Coordinates []mavenCoordinate
+	Manifest    map[string]string
+	Packages    []string // 包含class文件的包名，已排序
+}
+
+// inspectJar 读取jar包中的pom.properties、MANIFEST.MF和包名列表
+func inspectJar(jarPath string) (*jarInfo, error) {
+	r, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	info := &jarInfo{
+		Path: jarPath,
+		Name: path.Base(strings.ReplaceAll(jarPath, "\\", "/")),
+	}
+	packages := make(map[string]bool)
+
+	for _, f := range r.File {
+		name := f.Name
+		switch {
+		case name == "META-INF/MANIFEST.MF":
+			if data, err := readZipEntry(f); err == nil {
+				info.Manifest = parseManifest(data)
+			}
+		case strings.HasPrefix(name, "META-INF/maven/") && strings.HasSuffix(name, "/pom.properties"):
+			if data, err := readZipEntry(f); err == nil {
+				if coord, ok := parsePomProperties(data); ok {
+					info.Coordinates = append(info.Coordinates, coord)
+				}
+			}
+		case strings.HasSuffix(name, ".class") && !strings.HasPrefix(name, "META-INF/"):
+			if dir := path.Dir(name); dir != "." {
+				packages[strings.ReplaceAll(dir, "/", ".")] = true
+			}
+		}
+	}
+
+	for pkg := range packages {
+		info.Packages = append(info.Packages, pkg)
+	}
+	sort.Strings(info.Packages)
+	return info, nil
+}
+
+// readZipEntry 读取zip条目内容
+func readZipEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// parsePomProperties 解析pom.properties中的groupId、artifactId和version
+func parsePomProperties(data []byte) (mavenCoordinate, bool) {
+	var coord mavenCoordinate
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.IndexAny(line, "=:")
+		if idx <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+		switch key {
+		case "groupId":
+			coord.GroupID = value
+		case "artifactId":
+			coord.ArtifactID = value
+		case "version":
+			coord.Version = value
+		}
+	}
+	return coord, coord.GroupID != "" && coord.ArtifactID != ""
+}
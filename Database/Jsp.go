@@ -0,0 +1,286 @@
+package Database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"codeql_n1ght/Common"
+	"codeql_n1ght/Install"
+
+	"github.com/fatih/color"
+)
+
+// jspExts 需要编译的JSP文件类型
+var jspExts = map[string]bool{
+	".jsp":  true,
+	".jspx": true,
+}
+
+// errJspFound 找到JSP文件后停止遍历
+var errJspFound = errors.New("jsp found")
+
+// hasJspFiles 检查Web模块中是否有JSP文件（忽略依赖目录和内部暂存目录）
+func hasJspFiles(webDir string) bool {
+	skipDirs := append([]string{".modules"}, resourceSkipDirs...)
+	err := filepath.Walk(webDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(webDir, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			relSlash := filepath.ToSlash(rel)
+			for _, skip := range skipDirs {
+				if relSlash == skip {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if jspExts[strings.ToLower(filepath.Ext(path))] {
+			return errJspFound
+		}
+		return nil
+	})
+	return err == errJspFound
+}
+
+// compileJsps 使用Tomcat的Jasper把JSP转换为Servlet源码并生成SMAP行号映射，未安装Tomcat时回退到jsp2class.jar
+func compileJsps(ctx context.Context, location, webDir, src1Dir string) error {
+	if !hasJspFiles(webDir) {
+		return nil
+	}
+
+	tomcatDir := Install.GetTomcatPath()
+	if tomcatDir == "" {
+		color.Yellow("未安装Tomcat，使用jsp2class.jar反编译JSP（没有行号映射）")
+		return decompileJspsLegacy(ctx, webDir, src1Dir)
+	}
+
+	ctx, cancel := Common.WithTimeout(ctx, "JSP编译", Common.DecompileTimeout)
+	defer cancel()
+
+	outDir, err := os.MkdirTemp(location, "jsp-")
+	if err != nil {
+		return fmt.Errorf("创建JSP输出目录失败: %v", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	classpath := strings.Join([]string{
+		filepath.Join(tomcatDir, "lib", "*"),
+		filepath.Join(tomcatDir, "bin", "tomcat-juli.jar"),
+	}, string(os.PathListSeparator))
+
+	// -compile 才能保证生成.smap文件；单个JSP编译失败不影响其他JSP
+	jspcErr := DecompileJava(ctx, "-cp", classpath, "org.apache.jasper.JspC",
+		"-uriroot", webDir,
+		"-d", outDir,
+		"-javaEncoding", "UTF-8",
+		"-smap", "-dumpsmap", "-compile")
+	if jspcErr != nil {
+		color.Yellow("Jasper编译部分JSP失败: %v", jspcErr)
+	}
+
+	count, err := collectJspServlets(location, webDir, outDir, src1Dir)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		if jspcErr != nil {
+			color.Yellow("Jasper没有生成任何Servlet源码，回退到jsp2class.jar")
+			return decompileJspsLegacy(ctx, webDir, src1Dir)
+		}
+		return nil
+	}
+
+	// 生成的Servlet继承HttpJspBase，需要Jasper和Servlet API参与编译
+	if err := addClasspathJars(location, filepath.Join(tomcatDir, "lib")); err != nil {
+		color.Red("复制Tomcat依赖失败: %v", err)
+	}
+	color.Green("Jasper已生成 %d 个JSP Servlet源码", count)
+	return nil
+}
+
+// decompileJspsLegacy 使用jsp2class.jar处理JSP
+func decompileJspsLegacy(ctx context.Context, webDir, src1Dir string) error {
+	if err := DecompileJava(ctx, "-jar", "tools/jsp2class.jar", webDir, src1Dir); err != nil {
+		return fmt.Errorf("jsp2class处理失败: %v", err)
+	}
+	return nil
+}
+
+// collectJspServlets 将Jasper生成的Servlet源码复制到src1，并根据SMAP记录回到JSP文件和行号的映射
+func collectJspServlets(location, webDir, outDir, src1Dir string) (int, error) {
+	createDir := filepath.Join(location, "createdabase")
+	artifact := originLabel(location, webDir)
+
+	var origins []Common.SourceOrigin
+	count := 0
+	err := filepath.Walk(outDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".java") {
+			return err
+		}
+		rel, err := filepath.Rel(outDir, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(src1Dir, rel)
+		if err := Common.CopyFile(path, dest); err != nil {
+			return err
+		}
+		count++
+
+		smapPath := strings.TrimSuffix(path, ".java") + ".class.smap"
+		data, err := os.ReadFile(smapPath)
+		if err != nil {
+			return nil
+		}
+		smap, ok := parseSmap(data)
+		if !ok {
+			return nil
+		}
+		source, err := filepath.Rel(createDir, dest)
+		if err != nil {
+			return nil
+		}
+		origins = append(origins, Common.SourceOrigin{
+			Source:     filepath.ToSlash(source),
+			Artifact:   artifact,
+			Class:      smap.MainFile,
+			Decompiler: "jasper",
+			Lines:      smap.Lines,
+			Includes:   smap.Includes,
+		})
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("复制JSP Servlet源码失败: %v", err)
+	}
+
+	if len(origins) > 0 {
+		updateMetadata(location, func(meta *DatabaseMetadata) {
+			meta.Origins = append(meta.Origins, origins...)
+		})
+	}
+	return count, nil
+}
+
+// jspSmap 解析后的JSR-45 SMAP，行号均为Servlet源码行 -> JSP行
+type jspSmap struct {
+	MainFile string
+	Lines    map[int]int
+	Includes map[int]string
+}
+
+// parseSmap 解析Jasper生成的SMAP中JSP层的文件表和行表
+func parseSmap(data []byte) (*jspSmap, bool) {
+	smap := &jspSmap{Lines: make(map[int]int), Includes: make(map[int]string)}
+	files := make(map[int]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	section := ""
+	stratum := ""
+	pendingID := -1
+	fileID := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "*") {
+			section = line
+			if strings.HasPrefix(line, "*S ") {
+				stratum = strings.TrimSpace(strings.TrimPrefix(line, "*S "))
+			}
+			continue
+		}
+		if stratum != "JSP" || line == "" {
+			continue
+		}
+
+		switch section {
+		case "*F":
+			// "+ id name" 后一行是路径，"id name" 没有路径
+			if pendingID >= 0 {
+				files[pendingID] = strings.TrimPrefix(line, "/")
+				pendingID = -1
+				continue
+			}
+			withPath := strings.HasPrefix(line, "+ ")
+			fields := strings.Fields(strings.TrimPrefix(line, "+ "))
+			if len(fields) < 2 {
+				continue
+			}
+			id, err := strconv.Atoi(fields[0])
+			if err != nil {
+				continue
+			}
+			files[id] = fields[1]
+			if withPath {
+				pendingID = id
+			}
+		case "*L":
+			fileID = applySmapLine(smap, line, fileID, files)
+		}
+	}
+
+	if len(files) == 0 || len(smap.Lines) == 0 {
+		return nil, false
+	}
+	smap.MainFile = files[0]
+	for javaLine, file := range smap.Includes {
+		if file == smap.MainFile {
+			delete(smap.Includes, javaLine)
+		}
+	}
+	return smap, true
+}
+
+// applySmapLine 解析一条行信息 InputStartLine[#LineFileID][,RepeatCount]:OutputStartLine[,OutputLineIncrement]，返回当前文件ID
+func applySmapLine(smap *jspSmap, line string, fileID int, files map[int]string) int {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return fileID
+	}
+
+	input, output := parts[0], parts[1]
+	repeat := 1
+	if idx := strings.Index(input, ","); idx >= 0 {
+		repeat, _ = strconv.Atoi(input[idx+1:])
+		input = input[:idx]
+	}
+	if idx := strings.Index(input, "#"); idx >= 0 {
+		if id, err := strconv.Atoi(input[idx+1:]); err == nil {
+			fileID = id
+		}
+		input = input[:idx]
+	}
+	increment := 1
+	if idx := strings.Index(output, ","); idx >= 0 {
+		increment, _ = strconv.Atoi(output[idx+1:])
+		output = output[:idx]
+	}
+
+	inStart, err1 := strconv.Atoi(input)
+	outStart, err2 := strconv.Atoi(output)
+	if err1 != nil || err2 != nil || repeat <= 0 {
+		return fileID
+	}
+	for i := 0; i < repeat; i++ {
+		for j := 0; j < increment || (increment == 0 && j == 0); j++ {
+			javaLine := outStart + i*increment + j
+			if _, exists := smap.Lines[javaLine]; exists {
+				continue
+			}
+			smap.Lines[javaLine] = inStart + i
+			smap.Includes[javaLine] = files[fileID]
+		}
+	}
+	return fileID
+}
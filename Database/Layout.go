@@ -0,0 +1,89 @@
+package Database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// inputLayout 输入制品的结构类型
+type inputLayout int
+
+const (
+	layoutJar     inputLayout = iota // 普通jar包
+	layoutWeb                        // WAR结构（WEB-INF）或Spring Boot结构（BOOT-INF）
+	layoutEar                        // EAR结构
+	layoutClasses                    // 裸class目录
+)
+
+// detectInputLayout 根据扩展名和解压后的目录结构判断输入类型
+func detectInputLayout(outputDir, input string, isDir bool) inputLayout {
+	ext := strings.ToLower(filepath.Ext(input))
+
+	if ext == ".ear" || (isDir && Common.FileExists(filepath.Join(outputDir, "META-INF", "application.xml"))) {
+		return layoutEar
+	}
+	if _, ok := detectSpringBootLayout(outputDir); ok || ext == ".war" {
+		return layoutWeb
+	}
+	if !isDir {
+		return layoutJar
+	}
+
+	// 目录输入：识别解压后的webapp
+	if Common.IsDirectory(filepath.Join(outputDir, "WEB-INF")) || Common.IsDirectory(filepath.Join(outputDir, "BOOT-INF")) {
+		color.Green("检测到解压后的Web应用目录")
+		return layoutWeb
+	}
+	color.Green("按class目录处理输入")
+	return layoutClasses
+}
+
+// decompileClassTree 反编译裸class目录，目录中的jar交给依赖选择流程处理
+func decompileClassTree(ctx context.Context, location, outputDir, src1Dir string) error {
+	stagingDir := filepath.Join(outputDir, ".classes")
+	count := 0
+
+	// 只挑出class文件，避免反编译器把目录中的jar一并处理
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path == stagingDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(info.Name(), ".class") {
+			return nil
+		}
+		relPath, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		count++
+		return Common.CopyFile(path, filepath.Join(stagingDir, relPath))
+	})
+	if err != nil {
+		return fmt.Errorf("收集class文件失败: %v", err)
+	}
+
+	if count == 0 {
+		color.Yellow("输入目录中没有class文件，跳过主程序反编译")
+		return nil
+	}
+
+	color.Green("开始反编译 %d 个class文件", count)
+	if err := decompileClassesDir(ctx, location, stagingDir, src1Dir); err != nil {
+		return err
+	}
+	color.Green("class目录反编译完成")
+	return nil
+}
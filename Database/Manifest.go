@@ -0,0 +1,216 @@
+package Database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// 构建文件中javac任务的设置
+const (
+	javacSource   = "8"
+	javacTarget   = "8"
+	javacDebug    = true
+	javacOptimize = false
+)
+
+// toolVersions 工具版本只在第一次建库时检测，批量模式下各制品共用
+var toolVersions = struct {
+	once     sync.Once
+	versions map[string]string
+}{}
+
+// javacTaskOptions 返回build.xml中javac任务的公共属性
+func javacTaskOptions() string {
+	return fmt.Sprintf(`source="%s" target="%s" fork="true" optimize="%s" debug="%s" failonerror="false"`,
+		javacSource, javacTarget, onOff(javacOptimize), onOff(javacDebug))
+}
+
+// onOff 将布尔值转换为Ant的on/off
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
+
+// recordArtifact 计算输入制品的摘要，记入建库清单
+func recordArtifact(location, jar string) {
+	info := &Common.ArtifactInfo{
+		Name:      filepath.Base(jar),
+		Path:      jar,
+		Directory: Common.IsDirectory(jar),
+	}
+	var err error
+	if info.Directory {
+		info.SHA256, info.Size, err = directoryHash(jar)
+	} else {
+		info.SHA256, info.Size, err = fileHash(jar)
+	}
+	if err != nil {
+		color.Red("计算制品摘要失败: %v", err)
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.Artifact = info
+	})
+}
+
+// recordDecompiled 记录一个jar或class目录实际使用的反编译器和版本
+func recordDecompiled(location, input string, used Decompiler, fallback bool) {
+	entry := Common.DecompiledInput{
+		Input:      input,
+		Decompiler: used.Name(),
+		Version:    used.Version(),
+		Fallback:   fallback,
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.Decompiled = append(meta.Decompiled, entry)
+	})
+}
+
+// recordDecompileFailure 记录所有反编译器都失败或超时的jar、class目录
+func recordDecompileFailure(location, input string, err error) {
+	failure := Common.DecompileFailure{
+		Input:    input,
+		Reason:   err.Error(),
+		TimedOut: Common.IsTimeout(err),
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.DecompileFailures = append(meta.DecompileFailures, failure)
+	})
+}
+
+// recordBuildInfo 数据库创建完成后记录工具版本、javac设置、编译覆盖率、创建时间和生效的配置
+func recordBuildInfo(location string, started time.Time) {
+	createDir := filepath.Join(location, "createdabase")
+	coverage := compileCoverage(createDir)
+	color.Green("编译覆盖率: %d/%d 个源码文件编译成功（%.1f%%）", coverage.CompiledFiles, coverage.SourceFiles, coverage.Percent)
+
+	toolVersions.once.Do(func() {
+		toolVersions.versions = Common.GetToolVersions()
+	})
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		if stats := meta.Incremental; stats != nil {
+			color.Green("增量重建: 复用 %d 个类的源码，重新反编译 %d 个类（未变化输入 %d、变化 %d、新增 %d）",
+				stats.ReusedClasses, stats.ChangedClasses, stats.ReusedInputs, stats.ChangedInputs, stats.NewInputs)
+		}
+		if len(meta.DecompileFailures) > 0 {
+			color.Yellow("%d 个jar或class目录反编译失败或超时，已跳过（见 %s 的 decompileFailures）", len(meta.DecompileFailures), Common.MetadataFileName)
+		}
+		meta.CreatedAt = time.Now()
+		meta.DurationSeconds = time.Since(started).Round(time.Second).Seconds()
+		meta.ToolVersions = toolVersions.versions
+		meta.Javac = javacSettings()
+		meta.Coverage = coverage
+		meta.Config = Common.CurrentConfig()
+	})
+}
+
+// javacSettings 返回build.xml中javac任务的设置和classpath
+func javacSettings() *Common.JavacSettings {
+	tomcat := os.Getenv("CATALINA_HOME")
+	return &Common.JavacSettings{
+		Source:   javacSource,
+		Target:   javacTarget,
+		Debug:    javacDebug,
+		Optimize: javacOptimize,
+		Classpath: []string{
+			filepath.Join(tomcat, "lib"),
+			filepath.Join(tomcat, "lib", "*.jar"),
+			filepath.Join(tomcat, "bin", "*.jar"),
+			"lib/**/*.jar",
+		},
+	}
+}
+
+// compileCoverage 统计src1和src-dup下的源码文件中有多少编译出了对应的class文件
+func compileCoverage(createDir string) *Common.CompileCoverage {
+	coverage := &Common.CompileCoverage{}
+	count := func(srcDir, buildDir string) {
+		for _, file := range listJavaFiles(srcDir) {
+			coverage.SourceFiles++
+			class := strings.TrimSuffix(file, ".java") + ".class"
+			if Common.FileExists(filepath.Join(buildDir, class)) {
+				coverage.CompiledFiles++
+			}
+		}
+	}
+	count(filepath.Join(createDir, "src1"), filepath.Join(createDir, "build_classes"))
+	roots, _ := filepath.Glob(filepath.Join(createDir, duplicateRootsDir, "*"))
+	for _, root := range roots {
+		count(root, filepath.Join(createDir, "build_classes_dup", filepath.Base(root)))
+	}
+	if coverage.SourceFiles > 0 {
+		percent := float64(coverage.CompiledFiles) * 100 / float64(coverage.SourceFiles)
+		coverage.Percent = float64(int(percent*10+0.5)) / 10
+	}
+	return coverage
+}
+
+// fileHash 计算文件的SHA-256和大小
+func fileHash(path string) (string, int64, error) {
+	h := sha256.New()
+	size, err := hashFile(path, h)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// hashFile 只读取一遍文件，同时写入所有摘要，返回文件大小
+func hashFile(path string, hashes ...hash.Hash) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		writers[i] = h
+	}
+	return io.Copy(io.MultiWriter(writers...), f)
+}
+
+// directoryHash 按相对路径排序后对每个文件的路径和SHA-256再做摘要，结果与目录所在位置和遍历顺序无关
+func directoryHash(dir string) (string, int64, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	var total int64
+	for _, file := range files {
+		sum, size, err := fileHash(file)
+		if err != nil {
+			return "", 0, err
+		}
+		rel, _ := filepath.Rel(dir, file)
+		fmt.Fprintf(h, "%s  %s\n", sum, filepath.ToSlash(rel))
+		total += size
+	}
+	return hex.EncodeToString(h.Sum(nil)), total, nil
+}
@@ -0,0 +1,95 @@
+package Database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"codeql_n1ght/Common"
+)
+
+// DatabaseMetadata 随数据库一起保存的建库信息
+type DatabaseMetadata struct {
+	// 建库清单：输入制品摘要、反编译器、选中的依赖、工具版本、javac设置、编译覆盖率和生效的配置
+	Common.BuildInfo
+	Dependencies    []DependencyClassification `json:"dependencies,omitempty"`
+	Vulnerabilities []VulnerabilityFinding     `json:"vulnerabilities,omitempty"`
+	// auto模式下每个jar的反编译器评估结果
+	DecompilerSelections []DecompilerSelection `json:"decompilerSelections,omitempty"`
+	// 混淆检测和去混淆改名结果
+	Obfuscation []ObfuscationReport `json:"obfuscation,omitempty"`
+	// 依赖中发现的嵌套jar
+	NestedJars []NestedJar `json:"nestedJars,omitempty"`
+	// 多个来源中重复的类及处理策略
+	DuplicatePolicy  string           `json:"duplicatePolicy,omitempty"`
+	DuplicateClasses []DuplicateClass `json:"duplicateClasses,omitempty"`
+	// 每个jar因重复而不写入src1的源码文件
+	duplicateExclusions map[string]map[string]bool
+	// EAR中各Web模块自带的WEB-INF/lib等额外依赖目录，与主依赖目录一起供选择反编译
+	extraLibDirs []string
+	// 反编译前按制品中所有输入统一生成的改名规则（-deobfuscate、-mapping）
+	renamer classRenamer
+	// 当前所处和已完成的建库阶段，中断时写入快照
+	stage           string
+	completedStages []string
+	// 反编译源码的来源，单独写入n1ght-origins.json
+	Origins []Common.SourceOrigin `json:"-"`
+}
+
+// DependencyClassification 依赖jar的归属分类
+type DependencyClassification struct {
+	Name        string   `json:"name"`
+	Category    string   `json:"category"`
+	Reason      string   `json:"reason"`
+	Coordinates []string `json:"coordinates,omitempty"`
+}
+
+// metadataRegistry 按工作目录保存每次建库的元数据，批量模式下多个建库互不干扰
+var metadataRegistry = struct {
+	sync.Mutex
+	items map[string]*DatabaseMetadata
+}{items: make(map[string]*DatabaseMetadata)}
+
+// updateMetadata 在锁内修改指定工作目录对应的元数据
+func updateMetadata(location string, fn func(meta *DatabaseMetadata)) {
+	metadataRegistry.Lock()
+	defer metadataRegistry.Unlock()
+
+	meta, ok := metadataRegistry.items[location]
+	if !ok {
+		meta = &DatabaseMetadata{}
+		metadataRegistry.items[location] = meta
+	}
+	fn(meta)
+}
+
+// writeMetadata 将元数据写入数据库目录，并释放该工作目录的记录
+func writeMetadata(location, dbPath string) error {
+	metadataRegistry.Lock()
+	meta, ok := metadataRegistry.items[location]
+	delete(metadataRegistry.items, location)
+	metadataRegistry.Unlock()
+
+	if !ok {
+		meta = &DatabaseMetadata{}
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dbPath, Common.MetadataFileName), data, 0644); err != nil {
+		return err
+	}
+	if len(meta.Origins) > 0 {
+		return Common.WriteOrigins(dbPath, meta.Origins)
+	}
+	return nil
+}
+
+// discardMetadata 丢弃工作目录对应的元数据（建库失败时）
+func discardMetadata(location string) {
+	metadataRegistry.Lock()
+	defer metadataRegistry.Unlock()
+	delete(metadataRegistry.items, location)
+}
@@ -0,0 +1,155 @@
+package Database
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// nestedSeparator 嵌套路径中外层归档与内部条目的分隔符，如 fat.jar!/BOOT-INF/lib/inner.jar
+const nestedSeparator = "!/"
+
+// nestedArchiveExts 会继续查找内部jar的归档类型（zip只用于查找，不作为依赖反编译）
+var nestedArchiveExts = map[string]bool{
+	".jar": true,
+	".zip": true,
+}
+
+// NestedJar 从依赖jar或插件zip内部发现的嵌套jar
+type NestedJar struct {
+	Name  string `json:"name"`  // 以顶层依赖文件名开头的嵌套路径，如 fat.jar!/lib/inner.jar
+	Depth int    `json:"depth"` // 嵌套层级，顶层依赖内部的jar为1
+}
+
+// nestedJarInfo 已解压的嵌套jar
+type nestedJarInfo struct {
+	name  string // 依赖名称（嵌套路径）
+	label string // 在制品中的完整路径，如 WEB-INF/lib/fat.jar!/lib/inner.jar
+}
+
+// nestedJars 按解压后的路径记录嵌套jar，供依赖选择、分类和来源记录使用；路径都在各自工作目录的nested下，建库结束后由forgetNestedJars清除
+var nestedJars sync.Map
+
+// forgetNestedJars 清除工作目录location中解压的嵌套jar记录，批量建库时不随制品数量累积
+func forgetNestedJars(location string) {
+	prefix := filepath.Join(location, "nested") + string(filepath.Separator)
+	nestedJars.Range(func(key, _ interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			nestedJars.Delete(key)
+		}
+		return true
+	})
+}
+
+// dependencyName 返回依赖在选择列表、规则匹配和元数据中的名称：顶层依赖为文件名，嵌套jar为完整嵌套路径
+func dependencyName(jarFile string) string {
+	if info, ok := nestedJars.Load(jarFile); ok {
+		return info.(nestedJarInfo).name
+	}
+	return filepath.Base(jarFile)
+}
+
+// nestedJarLabel 返回嵌套jar在制品中的完整路径，不是嵌套jar时返回空
+func nestedJarLabel(jarFile string) string {
+	if info, ok := nestedJars.Load(jarFile); ok {
+		return info.(nestedJarInfo).label
+	}
+	return ""
+}
+
+// discoverNestedJars 在依赖目录的jar和插件zip中递归查找嵌套jar（最多-nested-depth层），解压到工作目录并加入编译classpath
+func discoverNestedJars(location string, libDirs, jarFiles []string) []string {
+	if Common.NestedDepth <= 0 {
+		return nil
+	}
+	roots := append([]string{}, jarFiles...)
+	for _, libDir := range libDirs {
+		if zips, err := filepath.Glob(filepath.Join(libDir, "*.zip")); err == nil {
+			roots = append(roots, zips...)
+		}
+	}
+
+	extractDir := filepath.Join(location, "nested")
+	var found []string
+	var records []NestedJar
+	counter := 0
+
+	var walk func(archive, name, label string, depth int)
+	walk = func(archive, name, label string, depth int) {
+		if depth > Common.NestedDepth {
+			return
+		}
+		r, err := zip.OpenReader(archive)
+		if err != nil {
+			return
+		}
+		defer r.Close()
+
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() || !nestedArchiveExts[strings.ToLower(path.Ext(f.Name))] {
+				continue
+			}
+			counter++
+			dest := filepath.Join(extractDir, fmt.Sprint(counter), path.Base(f.Name))
+			if err := extractZipEntry(f, dest); err != nil {
+				color.Red("解压嵌套归档 %s%s%s 失败: %v", name, nestedSeparator, f.Name, err)
+				continue
+			}
+			childName := name + nestedSeparator + f.Name
+			childLabel := label + nestedSeparator + f.Name
+			if strings.EqualFold(path.Ext(f.Name), ".jar") {
+				nestedJars.Store(dest, nestedJarInfo{name: childName, label: childLabel})
+				found = append(found, dest)
+				records = append(records, NestedJar{Name: childName, Depth: depth})
+			}
+			walk(dest, childName, childLabel, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, filepath.Base(root), originLabel(location, root), 1)
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	color.Green("在依赖中发现 %d 个嵌套jar（最大深度 %d）", len(found), Common.NestedDepth)
+	classpathDir := filepath.Join(location, "createdabase", "lib", "nested")
+	for i, jarFile := range found {
+		dest := filepath.Join(classpathDir, fmt.Sprintf("%d-%s", i+1, filepath.Base(jarFile)))
+		if err := Common.CopyFile(jarFile, dest); err != nil {
+			color.Red("复制嵌套jar到classpath失败: %v", err)
+		}
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.NestedJars = records
+	})
+	return found
+}
+
+// extractZipEntry 将zip条目解压到指定文件
+func extractZipEntry(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, rc)
+	return err
+}
@@ -0,0 +1,41 @@
+package Database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"codeql_n1ght/Common"
+)
+
+func TestForgetNestedJarsAfterBuild(t *testing.T) {
+	defer func(old int) { Common.NestedDepth = old }(Common.NestedDepth)
+	Common.NestedDepth = 2
+
+	discover := func(location string) string {
+		t.Helper()
+		fat := filepath.Join(location, "output", "WEB-INF", "lib", "fat.jar")
+		writeTestJar(t, fat, map[string][]byte{"lib/inner.jar": zipBytes(t, map[string][]byte{"a/A.class": []byte("class")})})
+		found := discoverNestedJars(location, nil, []string{fat})
+		if len(found) != 1 {
+			t.Fatalf("nested jars = %v", found)
+		}
+		return found[0]
+	}
+	first, second := t.TempDir(), t.TempDir()
+	defer discardMetadata(first)
+	defer discardMetadata(second)
+	inner, other := discover(first), discover(second)
+	if got := dependencyName(inner); got != "fat.jar!/lib/inner.jar" {
+		t.Fatalf("dependencyName = %q", got)
+	}
+
+	// 只清除结束建库的工作目录中的记录
+	forgetNestedJars(first)
+	if got := dependencyName(inner); got != "inner.jar" {
+		t.Errorf("after cleanup dependencyName = %q, want the file name", got)
+	}
+	if nestedJarLabel(other) == "" {
+		t.Error("nested jar of another build was forgotten")
+	}
+	forgetNestedJars(second)
+}
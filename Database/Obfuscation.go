@@ -0,0 +1,946 @@
+package Database
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// javaKeywords 不能作为Java标识符的关键字和字面量
+var javaKeywords = map[string]bool{
+	"abstract": true, "assert": true, "boolean": true, "break": true, "byte": true, "case": true,
+	"catch": true, "char": true, "class": true, "const": true, "continue": true, "default": true,
+	"do": true, "double": true, "else": true, "enum": true, "extends": true, "final": true,
+	"finally": true, "float": true, "for": true, "goto": true, "if": true, "implements": true,
+	"import": true, "instanceof": true, "int": true, "interface": true, "long": true, "native": true,
+	"new": true, "package": true, "private": true, "protected": true, "public": true, "return": true,
+	"short": true, "static": true, "strictfp": true, "super": true, "switch": true, "synchronized": true,
+	"this": true, "throw": true, "throws": true, "transient": true, "try": true, "void": true,
+	"volatile": true, "while": true, "true": true, "false": true, "null": true, "_": true,
+}
+
+// stringDecryptorDescriptors 字符串解密方法常见的签名
+var stringDecryptorDescriptors = map[string]bool{
+	"(Ljava/lang/String;)Ljava/lang/String;":  true,
+	"(Ljava/lang/Object;)Ljava/lang/String;":  true,
+	"(Ljava/lang/String;I)Ljava/lang/String;": true,
+	"(I)Ljava/lang/String;":                   true,
+	"(II)Ljava/lang/String;":                  true,
+	"([B)Ljava/lang/String;":                  true,
+	"([C)Ljava/lang/String;":                  true,
+}
+
+// 混淆判定阈值
+const (
+	obfuscationMinClasses     = 5 // 短类名比例判定需要的最少类数
+	shortNameMaxLength        = 2 // 不超过该长度的类名视为混淆短名称
+	decryptorMinCallerClasses = 3 // 被至少这么多个类调用的解密签名静态方法才视为字符串解密桩
+)
+
+// allatoriMarker Allatori（尤其是演示版）在类、方法名和字符串中留下的标记
+const allatoriMarker = "ALLATORI"
+
+// ObfuscationReport 单个jar或class目录的混淆检测和处理结果
+type ObfuscationReport struct {
+	Artifact         string   `json:"artifact"`
+	Obfuscated       bool     `json:"obfuscated"`
+	Tool             string   `json:"tool,omitempty"` // proguard、allatori或unknown
+	Classes          int      `json:"classes"`
+	ShortNames       int      `json:"shortNames"`
+	InvalidNames     int      `json:"invalidNames"`
+	CaseCollisions   int      `json:"caseCollisions"`
+	StringDecryptors []string `json:"stringDecryptors,omitempty"`
+	Mapping          string   `json:"mapping,omitempty"` // 使用的mapping.txt
+	RenamedClasses   int      `json:"renamedClasses,omitempty"`
+	RenamedMembers   int      `json:"renamedMembers,omitempty"`
+	UpdatedClasses   int      `json:"updatedClasses,omitempty"` // 被改写的类，包括只引用了其他输入中改名的类或成员的类
+}
+
+// decompileInput 反编译输入：Original为制品中的jar或class目录，Path为实际反编译的路径（去混淆改名后为新jar）
+type decompileInput struct {
+	Original string
+	Path     string
+	Renamed  map[string]string // 新类名 -> 原类名（内部名称）
+	Remapped bool              // 类或成员被改名
+}
+
+// originalClass 返回反编译输入中的class条目在原制品中的名称
+func (in *decompileInput) originalClass(entry string) string {
+	name := strings.TrimSuffix(entry, ".class")
+	if original, ok := in.Renamed[name]; ok {
+		return original + ".class"
+	}
+	return entry
+}
+
+// loadedClass 从输入中读取的class文件
+type loadedClass struct {
+	entry string // jar条目或目录中的相对路径
+	class *classFile
+}
+
+// prepareDecompileInput 反编译前分析输入中的类：排除因重复不写入src1的类，检测混淆，按mapping.txt还原名称，或对冲突和非法的类名、成员名统一改名
+func prepareDecompileInput(location, input string) *decompileInput {
+	prepared := &decompileInput{Original: input, Path: input}
+	excluded := duplicateExclusions(location, input)
+	if !Common.Deobfuscate && Common.MappingFile == "" {
+		return excludeDuplicateClasses(location, prepared, excluded)
+	}
+
+	loaded, err := loadClassFiles(input)
+	if err != nil || len(loaded) == 0 {
+		return excludeDuplicateClasses(location, prepared, excluded)
+	}
+	// 因重复而不写入src1的类不参与分析和改名
+	if len(excluded) > 0 {
+		kept := loaded[:0]
+		for _, lc := range loaded {
+			if !excluded[topLevelSource(lc.class.name())] {
+				kept = append(kept, lc)
+			}
+		}
+		loaded = kept
+	}
+	classes := make([]*classFile, len(loaded))
+	for i, lc := range loaded {
+		classes[i] = lc.class
+	}
+
+	report := analyzeObfuscation(obfuscationCandidates(loaded))
+	report.Artifact = originLabel(location, input)
+
+	if mapping := customerMapping(); mapping != nil && mapping.covers(classes) {
+		report.Mapping = Common.MappingFile
+	}
+
+	// 改名规则在反编译前按制品中的所有输入统一生成，没有改名的输入中引用了改名的类或成员时也一并改写
+	if renamer := artifactRenamer(location); renamer != nil {
+		path, renamed, err := writeRemappedJar(location, input, loaded, excluded, renamer, &report)
+		if err != nil {
+			color.Red("去混淆改名失败 %s: %v", report.Artifact, err)
+		} else if path != "" {
+			prepared.Path = path
+			prepared.Renamed = renamed
+			prepared.Remapped = true
+		}
+	}
+
+	if report.Obfuscated || report.Mapping != "" || report.RenamedClasses > 0 || report.RenamedMembers > 0 || report.UpdatedClasses > 0 {
+		printObfuscationReport(&report)
+		updateMetadata(location, func(meta *DatabaseMetadata) {
+			meta.Obfuscation = append(meta.Obfuscation, report)
+		})
+	}
+	if prepared.Path != input {
+		// 改名后的jar只包含未被排除的类
+		return prepared
+	}
+	return excludeDuplicateClasses(location, prepared, excluded)
+}
+
+// prepareDeobfuscation 反编译前读取制品中的所有jar和class目录：能按mapping.txt还原的输入按映射改名，
+// 被混淆或有冲突、非法名称的输入一起生成改名规则。规则对所有输入统一生效，跨jar的引用与改名后的类保持一致
+func prepareDeobfuscation(location string) {
+	if !Common.Deobfuscate && Common.MappingFile == "" {
+		return
+	}
+	mapping := customerMapping()
+	var mapped, obfuscated []*classFile
+	for _, input := range deobfuscationInputs(filepath.Join(location, "output")) {
+		loaded, err := loadClassFiles(input)
+		if err != nil || len(loaded) == 0 {
+			continue
+		}
+		classes := make([]*classFile, len(loaded))
+		for i, lc := range loaded {
+			classes[i] = lc.class
+		}
+		if mapping != nil && mapping.covers(classes) {
+			mapped = append(mapped, classes...)
+			continue
+		}
+		report := analyzeObfuscation(obfuscationCandidates(loaded))
+		if Common.Deobfuscate && (report.InvalidNames > 0 || report.CaseCollisions > 0 || report.Obfuscated) {
+			obfuscated = append(obfuscated, classes...)
+		}
+	}
+
+	var renamer chainedRenamer
+	if len(mapped) > 0 {
+		renamer = append(renamer, mapping.renamerFor(mapped))
+	}
+	if len(obfuscated) > 0 {
+		renamer = append(renamer, newObfuscationRenamer(obfuscated))
+	}
+	if len(renamer) > 0 {
+		updateMetadata(location, func(meta *DatabaseMetadata) {
+			meta.renamer = renamer
+		})
+	}
+}
+
+// deobfuscationInputs 返回解压目录中的jar和class目录：WEB-INF/classes、BOOT-INF/classes（包括EAR中解压的Web模块），
+// 没有这些目录时解压目录本身（普通jar或裸class目录）
+func deobfuscationInputs(outputDir string) []string {
+	var jars, classDirs []string
+	looseClasses := false
+	filepath.Walk(outputDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		switch {
+		case info.IsDir() && name == "classes" && strings.HasSuffix(filepath.Base(filepath.Dir(p)), "-INF"):
+			classDirs = append(classDirs, p)
+			return filepath.SkipDir
+		case !info.IsDir() && strings.EqualFold(filepath.Ext(name), ".jar"):
+			jars = append(jars, p)
+		case !info.IsDir() && strings.HasSuffix(name, ".class"):
+			looseClasses = true
+		}
+		return nil
+	})
+	if len(classDirs) == 0 && looseClasses {
+		classDirs = append(classDirs, outputDir)
+	}
+	return append(classDirs, jars...)
+}
+
+// artifactRenamer 返回prepareDeobfuscation为制品生成的改名规则，不需要改名时返回nil
+func artifactRenamer(location string) classRenamer {
+	var renamer classRenamer
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		renamer = meta.renamer
+	})
+	return renamer
+}
+
+// chainedRenamer 依次尝试多个改名规则，使用第一个需要改名的结果
+type chainedRenamer []classRenamer
+
+func (c chainedRenamer) renameClass(internal string) (string, bool) {
+	for _, r := range c {
+		if name, ok := r.renameClass(internal); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (c chainedRenamer) renameMember(owner, name, desc string, method bool) (string, bool) {
+	for _, r := range c {
+		if newName, ok := r.renameMember(owner, name, desc, method); ok {
+			return newName, true
+		}
+	}
+	return "", false
+}
+
+// excludeDuplicateClasses 去掉因重复而不写入src1的类，写入工作目录中的新jar
+func excludeDuplicateClasses(location string, prepared *decompileInput, excluded map[string]bool) *decompileInput {
+	if len(excluded) == 0 {
+		return prepared
+	}
+	dir, err := os.MkdirTemp(location, "dedup-")
+	if err != nil {
+		color.Red("创建去重目录失败: %v", err)
+		return prepared
+	}
+	jarPath := filepath.Join(dir, filepath.Base(prepared.Path))
+	err = filterJarClasses(prepared.Path, jarPath, func(className string) bool {
+		return !excluded[className+".java"]
+	})
+	if err != nil {
+		color.Red("排除 %s 中的重复类失败: %v", filepath.Base(prepared.Path), err)
+		return prepared
+	}
+	prepared.Path = jarPath
+	return prepared
+}
+
+// topLevelSource 返回类所在的源码文件，如 com/foo/Bar$Inner -> com/foo/Bar.java
+func topLevelSource(internal string) string {
+	slash := strings.LastIndex(internal, "/")
+	if i := strings.Index(internal[slash+1:], "$"); i > 0 {
+		internal = internal[:slash+1+i]
+	}
+	return internal + ".java"
+}
+
+// loadClassFiles 读取jar或目录中的所有class文件，无法解析的类跳过
+func loadClassFiles(input string) ([]loadedClass, error) {
+	var loaded []loadedClass
+	add := func(entry string, data []byte) {
+		if cf, err := parseClassFile(data); err == nil && cf.name() != "" {
+			loaded = append(loaded, loadedClass{entry: entry, class: cf})
+		}
+	}
+
+	if Common.IsDirectory(input) {
+		err := filepath.Walk(input, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".class") {
+				return err
+			}
+			rel, err := filepath.Rel(input, p)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			add(filepath.ToSlash(rel), data)
+			return nil
+		})
+		return loaded, err
+	}
+
+	r, err := zip.OpenReader(input)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(f.Name, ".class") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err == nil {
+			add(f.Name, data)
+		}
+	}
+	return loaded, nil
+}
+
+// obfuscationCandidates 返回参与混淆分析的类：package-info、module-info不是普通类，META-INF/versions下是同一批类的其他版本，都不参与统计
+func obfuscationCandidates(loaded []loadedClass) []*classFile {
+	var classes []*classFile
+	for _, lc := range loaded {
+		if strings.HasPrefix(lc.entry, "META-INF/versions/") || isPackageOrModuleInfo(simpleClassName(lc.class.name())) {
+			continue
+		}
+		classes = append(classes, lc.class)
+	}
+	return classes
+}
+
+// analyzeObfuscation 统计短类名、混淆器生成的名称、大小写冲突和字符串解密桩，判断是否被混淆
+func analyzeObfuscation(classes []*classFile) ObfuscationReport {
+	report := ObfuscationReport{Classes: len(classes)}
+	allatori := false
+	lowerNames := make(map[string]int)
+	declared := make(map[string]bool)
+	callers := make(map[string]map[string]bool)
+
+	for _, cf := range classes {
+		name := cf.name()
+		lowerNames[strings.ToLower(name)]++
+
+		simple := simpleClassName(name)
+		if len(simple) <= shortNameMaxLength && !isAllDigits(simple) {
+			report.ShortNames++
+		}
+		invalid := false
+		for _, segment := range strings.Split(name, "/") {
+			if isObfuscatedClassSegment(segment) {
+				invalid = true
+			}
+		}
+		for _, members := range [][]classMember{cf.fields, cf.methods} {
+			for _, m := range members {
+				memberName := cf.utf8At(m.name)
+				if memberName != "<init>" && memberName != "<clinit>" && isObfuscatedName(memberName) {
+					invalid = true
+				}
+				if strings.Contains(memberName, allatoriMarker) {
+					allatori = true
+				}
+			}
+		}
+		if invalid {
+			report.InvalidNames++
+		}
+		if strings.Contains(name, allatoriMarker) {
+			allatori = true
+		}
+		for _, value := range cf.stringConstants() {
+			if strings.Contains(value, allatoriMarker) {
+				allatori = true
+			}
+		}
+
+		for _, m := range cf.methods {
+			if m.access&accStatic != 0 && stringDecryptorDescriptors[cf.utf8At(m.desc)] {
+				declared[memberKey(name, cf.utf8At(m.name), cf.utf8At(m.desc))] = true
+			}
+		}
+		for _, ref := range cf.memberRefs() {
+			if !ref.Method || ref.Owner == name || !stringDecryptorDescriptors[ref.Desc] {
+				continue
+			}
+			key := memberKey(ref.Owner, ref.Name, ref.Desc)
+			if callers[key] == nil {
+				callers[key] = make(map[string]bool)
+			}
+			callers[key][name] = true
+		}
+	}
+
+	for _, count := range lowerNames {
+		if count > 1 {
+			report.CaseCollisions += count - 1
+		}
+	}
+	for key := range declared {
+		parts := strings.Split(key, "\x00")
+		// 正常代码中的工具方法一般有可读的名称，只统计短名称或非法名称的解密方法
+		if len(callers[key]) >= decryptorMinCallerClasses &&
+			(len(parts[1]) <= shortNameMaxLength || isObfuscatedName(parts[1]) || len(simpleClassName(parts[0])) <= shortNameMaxLength) {
+			report.StringDecryptors = append(report.StringDecryptors, strings.ReplaceAll(parts[0], "/", ".")+"."+parts[1]+parts[2])
+		}
+	}
+	sort.Strings(report.StringDecryptors)
+
+	shortNames := report.Classes >= obfuscationMinClasses && report.ShortNames*2 >= report.Classes
+	report.Obfuscated = shortNames || report.InvalidNames > 0 || len(report.StringDecryptors) > 0 || allatori
+	switch {
+	case allatori:
+		report.Tool = "allatori"
+	case shortNames && len(report.StringDecryptors) == 0:
+		report.Tool = "proguard"
+	case report.Obfuscated:
+		report.Tool = "unknown"
+	}
+	return report
+}
+
+// obfuscationRenamer 未提供mapping.txt时的改名规则：类名大小写冲突加序号，非法标识符替换为合法字符；
+// 成员按声明类改名，引用处沿继承关系找到声明类，同一重写链上的方法统一改名
+type obfuscationRenamer struct {
+	classes  map[string]string
+	members  map[string]string   // 声明类\x00成员名\x00描述符 -> 新名称
+	declared map[string]bool     // 声明类\x00成员名\x00描述符
+	supers   map[string][]string // 类 -> 父类和接口
+	known    map[string]bool
+}
+
+// objectMethods java.lang.Object中可被重写的方法，所有类都继承它们
+var objectMethods = map[string]bool{
+	"equals\x00(Ljava/lang/Object;)Z":  true,
+	"hashCode\x00()I":                  true,
+	"toString\x00()Ljava/lang/String;": true,
+	"clone\x00()Ljava/lang/Object;":    true,
+	"finalize\x00()V":                  true,
+}
+
+// newObfuscationRenamer 根据输入中的类计算改名规则
+func newObfuscationRenamer(classes []*classFile) *obfuscationRenamer {
+	r := &obfuscationRenamer{
+		classes:  make(map[string]string),
+		members:  make(map[string]string),
+		declared: make(map[string]bool),
+		supers:   make(map[string][]string),
+		known:    make(map[string]bool),
+	}
+	names := make([]string, 0, len(classes))
+	for _, cf := range classes {
+		r.known[cf.name()] = true
+		names = append(names, cf.name())
+		parents := cf.interfaceNames()
+		if super := cf.superName(); super != "" {
+			parents = append([]string{super}, parents...)
+		}
+		r.supers[cf.name()] = parents
+	}
+
+	// 外部类先于内部类处理，内部类跟随外部类的新名称；同名按字节序排序保证每次结果相同
+	sort.Slice(names, func(i, j int) bool {
+		di, dj := strings.Count(names[i], "$"), strings.Count(names[j], "$")
+		if di != dj {
+			return di < dj
+		}
+		return names[i] < names[j]
+	})
+	final := make(map[string]string, len(names))
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
+		var candidate string
+		if i := strings.LastIndex(name, "$"); i > 0 && r.known[name[:i]] {
+			inner := name[i+1:]
+			if !isAllDigits(inner) {
+				inner = sanitizeIdentifier(inner)
+			}
+			candidate = final[name[:i]] + "$" + inner
+		} else {
+			segments := strings.Split(name, "/")
+			for k, segment := range segments {
+				// package-info、module-info的名称是固定的
+				if k == len(segments)-1 && isPackageOrModuleInfo(segment) {
+					continue
+				}
+				segments[k] = sanitizeIdentifier(segment)
+			}
+			candidate = strings.Join(segments, "/")
+		}
+		unique := candidate
+		for n := 2; used[strings.ToLower(unique)]; n++ {
+			unique = fmt.Sprintf("%s_%d", candidate, n)
+		}
+		used[strings.ToLower(unique)] = true
+		final[name] = unique
+		if unique != name {
+			r.classes[name] = unique
+		}
+	}
+
+	// 同名不同类型的字段、只有返回值不同的方法在Java源码中非法，冲突的成员名附加描述符哈希
+	clashing := make(map[string]bool)
+	byName := make(map[string]*classFile, len(classes))
+	for _, cf := range classes {
+		owner := cf.name()
+		byName[owner] = cf
+		fields := make(map[string][]string)
+		for _, f := range cf.fields {
+			name, desc := cf.utf8At(f.name), cf.utf8At(f.desc)
+			r.declared[memberKey(owner, name, desc)] = true
+			fields[name] = append(fields[name], desc)
+		}
+		for name, descs := range fields {
+			if len(descs) > 1 {
+				for _, desc := range descs {
+					clashing[memberKey(owner, name, desc)] = true
+				}
+			}
+		}
+		methods := make(map[string][]string)
+		for _, m := range cf.methods {
+			name, desc := cf.utf8At(m.name), cf.utf8At(m.desc)
+			if name == "<init>" || name == "<clinit>" {
+				continue
+			}
+			r.declared[memberKey(owner, name, desc)] = true
+			if m.access&(accBridge|accSynthetic) != 0 {
+				continue
+			}
+			params := name + desc[:strings.Index(desc, ")")+1]
+			methods[params] = append(methods[params], desc)
+		}
+		for params, descs := range methods {
+			if len(descs) > 1 {
+				name := params[:strings.Index(params, "(")]
+				for _, desc := range descs {
+					clashing[memberKey(owner, name, desc)] = true
+				}
+			}
+		}
+	}
+
+	// 可被重写的方法按重写关系分组：同一个类能看到的同名同描述符方法（含继承自各个父类和接口的）必须同名。
+	// 祖先中有输入之外的类时，这些方法可能重写或实现了外部类的方法，整组都不改名
+	group := make(map[string]string)
+	var find func(key string) string
+	find = func(key string) string {
+		parent, ok := group[key]
+		if !ok || parent == key {
+			return key
+		}
+		root := find(parent)
+		group[key] = root
+		return root
+	}
+	external := make(map[string]bool)
+	for _, cf := range classes {
+		ancestors, unknown := r.ancestors(cf.name())
+		seen := make(map[string]string)
+		for _, ancestor := range ancestors {
+			declaring := byName[ancestor]
+			for _, m := range declaring.methods {
+				name, desc := declaring.utf8At(m.name), declaring.utf8At(m.desc)
+				if !overridable(m, name) {
+					continue
+				}
+				key := memberKey(ancestor, name, desc)
+				if unknown || objectMethods[name+"\x00"+desc] {
+					external[key] = true
+				}
+				if first, ok := seen[name+"\x00"+desc]; ok {
+					group[find(key)] = find(first)
+				} else {
+					seen[name+"\x00"+desc] = key
+				}
+			}
+		}
+	}
+	externalGroups := make(map[string]bool)
+	clashingGroups := make(map[string]bool)
+	for key := range external {
+		externalGroups[find(key)] = true
+	}
+	for key := range clashing {
+		clashingGroups[find(key)] = true
+	}
+
+	for _, cf := range classes {
+		owner := cf.name()
+		for _, members := range []struct {
+			list   []classMember
+			method bool
+		}{{cf.fields, false}, {cf.methods, true}} {
+			for _, m := range members.list {
+				name, desc := cf.utf8At(m.name), cf.utf8At(m.desc)
+				if name == "<init>" || name == "<clinit>" {
+					continue
+				}
+				key := memberKey(owner, name, desc)
+				clash := clashing[key]
+				if members.method && overridable(m, name) {
+					root := find(key)
+					if externalGroups[root] {
+						continue
+					}
+					clash = clashingGroups[root]
+				}
+				newName := sanitizeIdentifier(name)
+				if clash {
+					newName = fmt.Sprintf("%s_%04x", newName, crc32.ChecksumIEEE([]byte(desc))&0xffff)
+				}
+				if newName != name {
+					r.members[key] = newName
+				}
+			}
+		}
+	}
+	return r
+}
+
+// ancestors 返回类自身和输入中的所有祖先类，第二个返回值表示祖先中是否有输入之外的类（java.lang.Object除外）
+func (r *obfuscationRenamer) ancestors(name string) ([]string, bool) {
+	var known []string
+	unknown := false
+	visited := make(map[string]bool)
+	queue := []string{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		if !r.known[current] {
+			if current != "java/lang/Object" {
+				unknown = true
+			}
+			continue
+		}
+		known = append(known, current)
+		queue = append(queue, r.supers[current]...)
+	}
+	return known, unknown
+}
+
+// overridable 方法是否参与重写（private、static方法和构造方法不参与）
+func overridable(m classMember, name string) bool {
+	return m.access&(accPrivate|accStatic) == 0 && name != "<init>" && name != "<clinit>"
+}
+
+func (r *obfuscationRenamer) renameClass(internal string) (string, bool) {
+	name, ok := r.classes[internal]
+	return name, ok
+}
+
+// renameMember 从引用的类开始沿父类和接口找到声明该成员的类，按声明类的改名规则改名；找不到（声明在输入之外的类）时不改名
+func (r *obfuscationRenamer) renameMember(owner, name, desc string, method bool) (string, bool) {
+	if name == "<init>" || name == "<clinit>" {
+		return "", false
+	}
+	visited := make(map[string]bool)
+	queue := []string{owner}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] || !r.known[current] {
+			continue
+		}
+		visited[current] = true
+		key := memberKey(current, name, desc)
+		if r.declared[key] {
+			newName, ok := r.members[key]
+			return newName, ok
+		}
+		queue = append(queue, r.supers[current]...)
+	}
+	return "", false
+}
+
+// writeRemappedJar 将改名后的类写入工作目录中的新jar，资源文件和无法解析的class原样保留，因重复被排除的类不写入；
+// 没有任何类因改名或引用了改名的类、成员而变化时返回空路径
+func writeRemappedJar(location, input string, loaded []loadedClass, excluded map[string]bool, renamer classRenamer, report *ObfuscationReport) (string, map[string]string, error) {
+	renamed := make(map[string]string)
+	for _, lc := range loaded {
+		name := lc.class.name()
+		if newName, ok := renamer.renameClass(name); ok {
+			renamed[newName] = name
+		}
+		for _, members := range []struct {
+			list   []classMember
+			method bool
+		}{{lc.class.fields, false}, {lc.class.methods, true}} {
+			for _, m := range members.list {
+				if _, ok := renamer.renameMember(name, lc.class.utf8At(m.name), lc.class.utf8At(m.desc), members.method); ok {
+					report.RenamedMembers++
+				}
+			}
+		}
+	}
+	report.RenamedClasses = len(renamed)
+
+	// 先在内存中改写，确认有类变化后再生成新jar
+	type remappedEntry struct {
+		entry string
+		data  []byte
+	}
+	var remapped []remappedEntry
+	written := make(map[string]bool)
+	for _, lc := range loaded {
+		name := lc.class.name()
+		entry := lc.entry
+		// 保留条目的目录前缀（如 META-INF/versions/11/）
+		if newName, ok := renamer.renameClass(name); ok && strings.HasSuffix(entry, name+".class") {
+			entry = strings.TrimSuffix(entry, name+".class") + newName + ".class"
+		}
+		if written[entry] {
+			continue
+		}
+		written[entry] = true
+
+		before := lc.class.bytes()
+		if err := remapClass(lc.class, renamer); err != nil {
+			return "", nil, err
+		}
+		data := lc.class.bytes()
+		if !bytes.Equal(before, data) {
+			report.UpdatedClasses++
+		}
+		remapped = append(remapped, remappedEntry{entry: entry, data: data})
+	}
+	if report.UpdatedClasses == 0 {
+		return "", nil, nil
+	}
+
+	dir, err := os.MkdirTemp(location, "deobf-")
+	if err != nil {
+		return "", nil, fmt.Errorf("创建去混淆目录失败: %v", err)
+	}
+	jarPath := filepath.Join(dir, strings.TrimSuffix(filepath.Base(input), ".jar")+".jar")
+	out, err := os.Create(jarPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("创建去混淆jar失败: %v", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	parsed := make(map[string]bool, len(loaded))
+	for _, lc := range loaded {
+		parsed[lc.entry] = true
+	}
+	for _, rc := range remapped {
+		w, err := zw.Create(rc.entry)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := w.Write(rc.data); err != nil {
+			return "", nil, err
+		}
+	}
+
+	err = copyUnparsedEntries(zw, input, func(entry string) bool {
+		if parsed[entry] || written[entry] {
+			return false
+		}
+		if strings.HasSuffix(entry, ".class") && excluded[topLevelSource(strings.TrimSuffix(unversionedEntry(entry), ".class"))] {
+			return false
+		}
+		written[entry] = true
+		return true
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("复制资源文件失败: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", nil, err
+	}
+	return jarPath, renamed, nil
+}
+
+// copyUnparsedEntries 将jar或目录中keep返回true的文件原样写入zw
+func copyUnparsedEntries(zw *zip.Writer, input string, keep func(entry string) bool) error {
+	if Common.IsDirectory(input) {
+		return filepath.Walk(input, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(input, p)
+			if err != nil || !keep(filepath.ToSlash(rel)) {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			w, err := zw.Create(filepath.ToSlash(rel))
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		})
+	}
+
+	r, err := zip.OpenReader(input)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !keep(f.Name) {
+			continue
+		}
+		if err := copyZipEntry(f, zw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unversionedEntry 去掉多版本jar条目的 META-INF/versions/<n>/ 前缀
+func unversionedEntry(entry string) string {
+	if rest, ok := strings.CutPrefix(entry, "META-INF/versions/"); ok {
+		if i := strings.Index(rest, "/"); i >= 0 {
+			return rest[i+1:]
+		}
+	}
+	return entry
+}
+
+// printObfuscationReport 输出混淆检测结果
+func printObfuscationReport(report *ObfuscationReport) {
+	if report.Obfuscated {
+		color.Yellow("检测到混淆 %s（%s）：%d 个类，短类名 %d，非法标识符 %d，大小写冲突 %d，字符串解密方法 %d",
+			report.Artifact, report.Tool, report.Classes, report.ShortNames, report.InvalidNames,
+			report.CaseCollisions, len(report.StringDecryptors))
+		for _, decryptor := range report.StringDecryptors {
+			fmt.Printf("  字符串解密方法: %s\n", decryptor)
+		}
+	}
+	switch {
+	case report.Mapping != "":
+		color.Green("已按 %s 还原 %s：%d 个类、%d 个成员", report.Mapping, report.Artifact, report.RenamedClasses, report.RenamedMembers)
+	case report.RenamedClasses > 0 || report.RenamedMembers > 0:
+		color.Green("已为 %s 中冲突或非法的名称统一改名：%d 个类、%d 个成员", report.Artifact, report.RenamedClasses, report.RenamedMembers)
+	case report.UpdatedClasses > 0:
+		color.Green("%s 中 %d 个类引用了其他jar中改名的类或成员，已同步改写", report.Artifact, report.UpdatedClasses)
+	}
+	if report.Obfuscated && report.Mapping == "" {
+		color.Yellow("如有ProGuard映射文件，可通过 -mapping mapping.txt 还原真实名称")
+	}
+}
+
+// simpleClassName 返回类的简单名称（内部类取$之后的部分）
+func simpleClassName(internal string) string {
+	simple := internal[strings.LastIndex(internal, "/")+1:]
+	return simple[strings.LastIndex(simple, "$")+1:]
+}
+
+// isPackageOrModuleInfo 是否为package-info或module-info
+func isPackageOrModuleInfo(simple string) bool {
+	return simple == "package-info" || simple == "module-info"
+}
+
+// isObfuscatedClassSegment 包名或类名的一段是否含有混淆器生成的名称（内部类匿名序号允许纯数字）
+func isObfuscatedClassSegment(segment string) bool {
+	for _, part := range strings.Split(segment, "$") {
+		if part != "" && !isAllDigits(part) && isObfuscatedName(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isObfuscatedName 名称是否只可能由混淆器生成：Java关键字，或含有空白、控制、格式（如零宽字符）、私有区和未分配的字符。
+// JVM允许而Java不允许、由编译器正常生成的名称（如Kotlin的 constructor-impl、getFoo-abc123）不算混淆
+func isObfuscatedName(name string) bool {
+	if javaKeywords[name] {
+		return true
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || !unicode.IsGraphic(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// isJavaIdentifier 是否为合法的Java标识符
+func isJavaIdentifier(name string) bool {
+	if name == "" || javaKeywords[name] {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// sanitizeIdentifier 将非法标识符转换为合法标识符，替换了字符的名称附加原名哈希避免改名后重名
+func sanitizeIdentifier(name string) string {
+	if isJavaIdentifier(name) {
+		return name
+	}
+	if javaKeywords[name] {
+		return name + "_"
+	}
+	var b strings.Builder
+	for i, r := range name {
+		if r == '_' || r == '$' || (r < unicode.MaxASCII && unicode.IsLetter(r)) || (i > 0 && r < unicode.MaxASCII && unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		} else if i == 0 && r < unicode.MaxASCII && unicode.IsDigit(r) {
+			b.WriteString("_")
+			b.WriteRune(r)
+		} else {
+			b.WriteString("_")
+		}
+	}
+	return fmt.Sprintf("%s_%04x", b.String(), crc32.ChecksumIEEE([]byte(name))&0xffff)
+}
+
+// isAllDigits 是否为非空的纯数字
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,222 @@
+package Database
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"codeql_n1ght/Common"
+)
+
+func mustParseClass(t *testing.T, name, super string, interfaces []string, fields, methods []testMember) *classFile {
+	t.Helper()
+	cf, err := parseClassFile(newTestClassBuilder().build(name, super, interfaces, fields, methods, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cf
+}
+
+func TestAnalyzeObfuscationIgnoresCompilerGeneratedNames(t *testing.T) {
+	loaded := []loadedClass{
+		{entry: "com/acme/Money.class", class: mustParseClass(t, "com/acme/Money", "java/lang/Object", nil, nil, []testMember{
+			{access: 0x0009, name: "constructor-impl", desc: "(J)J"},
+			{access: 0x0009, name: "toString-impl", desc: "(J)Ljava/lang/String;"},
+		})},
+		{entry: "com/acme/package-info.class", class: mustParseClass(t, "com/acme/package-info", "java/lang/Object", nil, nil, nil)},
+		{entry: "module-info.class", class: mustParseClass(t, "module-info", "", nil, nil, nil)},
+		{entry: "META-INF/versions/11/com/acme/a.class", class: mustParseClass(t, "com/acme/a", "java/lang/Object", nil, nil, nil)},
+	}
+	report := analyzeObfuscation(obfuscationCandidates(loaded))
+	if report.Obfuscated || report.InvalidNames != 0 || report.Classes != 1 {
+		t.Errorf("report = %+v, want one ordinary class", report)
+	}
+
+	loaded = append(loaded, loadedClass{entry: "com/acme/b.class", class: mustParseClass(t, "com/acme/b", "java/lang/Object", nil, nil, []testMember{
+		{access: 0x0001, name: "if", desc: "()V"},
+	})})
+	if report := analyzeObfuscation(obfuscationCandidates(loaded)); !report.Obfuscated || report.InvalidNames != 1 {
+		t.Errorf("report = %+v, want the keyword method detected", report)
+	}
+}
+
+func TestObfuscationRenamerFollowsHierarchy(t *testing.T) {
+	clash := []testMember{
+		{access: 0x0001, name: "m", desc: "()I"},
+		{access: 0x0001, name: "m", desc: "()J"},
+	}
+	classes := []*classFile{
+		mustParseClass(t, "p/A", "java/lang/Object", nil, []testMember{
+			{access: 0x0002, name: "f", desc: "I"},
+			{access: 0x0002, name: "f", desc: "J"},
+		}, clash),
+		mustParseClass(t, "p/B", "p/A", nil, nil, []testMember{{access: 0x0001, name: "m", desc: "()I"}}),
+		mustParseClass(t, "p/C", "lib/Base", nil, nil, clash),
+		mustParseClass(t, "p/D", "java/lang/Object", nil, nil, []testMember{
+			{access: 0x0001, name: "m", desc: "()I"},
+			{access: 0x0001, name: "toString", desc: "()Ljava/lang/String;"},
+			{access: 0x0001, name: "do", desc: "()V"},
+		}),
+	}
+	r := newObfuscationRenamer(classes)
+
+	renamed, ok := r.renameMember("p/A", "m", "()I", true)
+	if !ok || renamed == "m" {
+		t.Fatalf("clashing method in p/A not renamed: %q, %v", renamed, ok)
+	}
+	// 重写链上的方法和经由子类的引用保持同名
+	if got, _ := r.renameMember("p/B", "m", "()I", true); got != renamed {
+		t.Errorf("override in p/B = %q, want %q", got, renamed)
+	}
+	if other, _ := r.renameMember("p/A", "m", "()J", true); other == renamed || other == "" {
+		t.Errorf("m()J = %q, want a different suffix", other)
+	}
+	// 父类在输入之外时可能重写外部方法，不改名；无关的类不受p/A冲突影响
+	for _, owner := range []string{"p/C", "p/D"} {
+		if got, ok := r.renameMember(owner, "m", "()I", true); ok {
+			t.Errorf("%s.m()I renamed to %q", owner, got)
+		}
+	}
+	if got, ok := r.renameMember("p/D", "toString", "()Ljava/lang/String;", true); ok {
+		t.Errorf("toString renamed to %q", got)
+	}
+	if got, ok := r.renameMember("p/D", "do", "()V", true); !ok || got != "do_" {
+		t.Errorf("keyword method = %q, %v", got, ok)
+	}
+	// 字段沿父类找到声明类
+	field, ok := r.renameMember("p/A", "f", "I", false)
+	if !ok {
+		t.Fatal("clashing field not renamed")
+	}
+	if got, _ := r.renameMember("p/B", "f", "I", false); got != field {
+		t.Errorf("inherited field = %q, want %q", got, field)
+	}
+	if _, ok := r.renameMember("lib/Base", "m", "()I", true); ok {
+		t.Error("member of an unknown class renamed")
+	}
+}
+
+func TestWriteRemappedJarKeepsResourcesAndUnparsedClasses(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "app.jar")
+	entries := map[string][]byte{
+		"a/b.class":               newTestClassBuilder().build("a/b", "java/lang/Object", nil, nil, nil, nil),
+		"a/Dup.class":             newTestClassBuilder().build("a/Dup", "java/lang/Object", nil, nil, nil, nil),
+		"a/broken.class":          []byte("not a class"),
+		"META-INF/MANIFEST.MF":    []byte("Manifest-Version: 1.0\n"),
+		"com/acme/app.properties": []byte("key=value\n"),
+	}
+	out, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for name, data := range entries {
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+	out.Close()
+
+	loaded, err := loadClassFiles(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	excluded := map[string]bool{"a/Dup.java": true}
+	var kept []loadedClass
+	for _, lc := range loaded {
+		if !excluded[topLevelSource(lc.class.name())] {
+			kept = append(kept, lc)
+		}
+	}
+	renamer := &obfuscationRenamer{classes: map[string]string{"a/b": "com/acme/B"}}
+	path, renamed, err := writeRemappedJar(dir, input, kept, excluded, renamer, &ObfuscationReport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed["com/acme/B"] != "a/b" {
+		t.Errorf("renamed = %v", renamed)
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := []string{"META-INF/MANIFEST.MF", "a/broken.class", "com/acme/B.class", "com/acme/app.properties"}
+	if len(names) != len(want) {
+		t.Fatalf("entries = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("entries = %v, want %v", names, want)
+		}
+	}
+}
+
+// TestPrepareDeobfuscationRewritesReferencesAcrossInputs 依赖jar中的类改名后，class目录中引用它的类同步改写
+func TestPrepareDeobfuscationRewritesReferencesAcrossInputs(t *testing.T) {
+	defer func(old bool) { Common.Deobfuscate = old }(Common.Deobfuscate)
+	Common.Deobfuscate = true
+
+	location := t.TempDir()
+	defer discardMetadata(location)
+	lib := filepath.Join(location, "output", "WEB-INF", "lib", "lib.jar")
+	writeTestJar(t, lib, map[string][]byte{
+		"a/if.class": newTestClassBuilder().build("a/if", "java/lang/Object", nil, nil, []testMember{{access: 0x0009, name: "do", desc: "()V"}}, nil),
+	})
+	b := newTestClassBuilder()
+	method := b.ref(cpMethodref, "a/if", "do", "()V")
+	code := []byte{0xb8, byte(method >> 8), byte(method), 0xb1} // invokestatic、return
+	app := b.build("com/acme/App", "java/lang/Object", nil, nil, []testMember{{access: 0x0001, name: "run", desc: "()V", attrs: []testAttribute{{"Code", codeAttribute(b, code)}}}}, nil)
+	classes := filepath.Join(location, "output", "WEB-INF", "classes")
+	if err := os.MkdirAll(filepath.Join(classes, "com", "acme"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(classes, "com", "acme", "App.class"), app, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prepareDeobfuscation(location)
+	renamer := artifactRenamer(location)
+	if renamer == nil {
+		t.Fatal("no renamer for the obfuscated jar")
+	}
+	owner, ok := renamer.renameClass("a/if")
+	if !ok {
+		t.Fatal("keyword class not renamed")
+	}
+	member, _ := renamer.renameMember("a/if", "do", "()V", true)
+
+	prepared := prepareDecompileInput(location, classes)
+	if !prepared.Remapped {
+		t.Fatal("class directory referencing the renamed class was not rewritten")
+	}
+	loaded, err := loadClassFiles(prepared.Path)
+	if err != nil || len(loaded) != 1 {
+		t.Fatalf("loaded = %v, %v", loaded, err)
+	}
+	cf := loaded[0].class
+	var refs []string
+	for _, e := range cf.pool {
+		if e != nil && e.tag == cpMethodref {
+			refs = append(refs, cf.utf8At(cf.pool[e.a].a)+"."+cf.utf8At(cf.pool[e.b].a))
+		}
+	}
+	if len(refs) != 1 || refs[0] != owner+"."+member {
+		t.Errorf("App method refs = %v, want %s.%s", refs, owner, member)
+	}
+
+	var reports []ObfuscationReport
+	updateMetadata(location, func(meta *DatabaseMetadata) { reports = meta.Obfuscation })
+	if len(reports) != 1 || reports[0].UpdatedClasses != 1 || reports[0].RenamedClasses != 0 {
+		t.Errorf("reports = %+v", reports)
+	}
+}
@@ -0,0 +1,169 @@
+package Database
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"codeql_n1ght/Common"
+)
+
+// procyonLineComment Procyon -dl 在语句前输出的原始行号注释，如 /*SL:42*/
+var procyonLineComment = regexp.MustCompile(`/\*SL:(\d+)\*/`)
+
+// fernflowerLineMapping Fernflower/Vineflower -__dump_original_lines__ 在文件最后一行输出的行号映射，
+// 依次为 原始行 反编译行 的数对，如 // 14 8 15 9 16 10
+var fernflowerLineMapping = regexp.MustCompile(`^//((?: \d+ \d+)+)\s*$`)
+
+// recordOrigins 记录input（jar或class目录）中每个类反编译到outputDir后的源码路径和行号映射，去混淆改名的类记录原类名
+func recordOrigins(location string, input *decompileInput, outputDir string, used Decompiler) {
+	classes, err := listTopLevelClasses(input.Path)
+	if err != nil {
+		return
+	}
+
+	createDir := filepath.Join(location, "createdabase")
+	artifact := originLabel(location, input.Original)
+	decompiler := ""
+	if used != nil {
+		decompiler = used.Name()
+	}
+
+	var origins []Common.SourceOrigin
+	for _, class := range classes {
+		sourcePath := filepath.Join(outputDir, filepath.FromSlash(strings.TrimSuffix(class, ".class")+".java"))
+		data, err := os.ReadFile(sourcePath)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(createDir, sourcePath)
+		if err != nil {
+			continue
+		}
+		origins = append(origins, Common.SourceOrigin{
+			Source:     filepath.ToSlash(rel),
+			Artifact:   artifact,
+			Class:      input.originalClass(class),
+			Decompiler: decompiler,
+			Lines:      parseLineMapping(data),
+		})
+	}
+	if len(origins) == 0 {
+		return
+	}
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.Origins = append(meta.Origins, origins...)
+	})
+}
+
+// listTopLevelClasses 列出jar或目录中的顶层类（内部类归属于外部类的源码文件）
+func listTopLevelClasses(input string) ([]string, error) {
+	var classes []string
+	add := func(name string) {
+		base := path.Base(name)
+		if !strings.HasSuffix(base, ".class") || strings.Contains(base, "$") ||
+			base == "module-info.class" || base == "package-info.class" {
+			return
+		}
+		classes = append(classes, name)
+	}
+
+	if Common.IsDirectory(input) {
+		err := filepath.Walk(input, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(input, p)
+			if err != nil {
+				return err
+			}
+			add(filepath.ToSlash(rel))
+			return nil
+		})
+		return classes, err
+	}
+
+	r, err := zip.OpenReader(input)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if !strings.HasPrefix(f.Name, "META-INF/") {
+			add(f.Name)
+		}
+	}
+	return classes, nil
+}
+
+// originLabel 返回输入在制品中的路径（如 WEB-INF/lib/foo.jar，嵌套jar为 WEB-INF/lib/fat.jar!/lib/inner.jar），主程序jar返回文件名
+func originLabel(location, input string) string {
+	if label := nestedJarLabel(input); label != "" {
+		return label
+	}
+	rel, err := filepath.Rel(filepath.Join(location, "output"), input)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.Base(input)
+	}
+	rel = filepath.ToSlash(rel)
+	// 内部暂存目录不属于制品本身的路径
+	rel = strings.TrimPrefix(rel, ".modules/")
+	if rel == ".classes" {
+		return "."
+	}
+	return rel
+}
+
+// isArtifactRoot 判断输入是否为制品本身：直接反编译的普通jar（不在解压目录中）或裸class目录的暂存目录
+func isArtifactRoot(location, input string) bool {
+	if nestedJarLabel(input) != "" {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Join(location, "output"), input)
+	return err != nil || strings.HasPrefix(rel, "..") || filepath.ToSlash(rel) == ".classes"
+}
+
+// parseLineMapping 解析反编译器输出的行号信息，返回 反编译行号 -> 原始行号
+func parseLineMapping(data []byte) map[int]int {
+	lines := make(map[int]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	lineNo := 0
+	last := ""
+	for scanner.Scan() {
+		lineNo++
+		text := scanner.Text()
+		if strings.TrimSpace(text) != "" {
+			last = text
+		}
+
+		if match := procyonLineComment.FindStringSubmatch(text); match != nil {
+			if original, err := strconv.Atoi(match[1]); err == nil {
+				lines[lineNo] = original
+			}
+		}
+	}
+
+	// Fernflower系的映射只出现在最后一个非空行，避免把源码中的普通注释当成映射
+	if match := fernflowerLineMapping.FindStringSubmatch(last); match != nil {
+		numbers := strings.Fields(match[1])
+		for i := 0; i+1 < len(numbers); i += 2 {
+			original, err1 := strconv.Atoi(numbers[i])
+			decompiled, err2 := strconv.Atoi(numbers[i+1])
+			// 同一反编译行对应多个原始行时保留第一个
+			if _, ok := lines[decompiled]; err1 == nil && err2 == nil && !ok {
+				lines[decompiled] = original
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return lines
+}
@@ -0,0 +1,37 @@
+package Database
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseLineMappingFernflower(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "origins", "fernflower_UserController.java"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]int{8: 14, 9: 15, 10: 16, 13: 19, 14: 20, 16: 22, 17: 23}
+	if got := parseLineMapping(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLineMapping() = %v, want %v", got, want)
+	}
+}
+
+func TestParseLineMappingProcyon(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "origins", "procyon_UserController.java"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]int{10: 15, 14: 19, 15: 20, 18: 23}
+	if got := parseLineMapping(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLineMapping() = %v, want %v", got, want)
+	}
+}
+
+func TestParseLineMappingIgnoresInlineComments(t *testing.T) {
+	data := []byte("class A {\n   // 1 2\n   void f() {\n   }\n}\n")
+	if got := parseLineMapping(data); got != nil {
+		t.Errorf("parseLineMapping() = %v, want nil", got)
+	}
+}
@@ -0,0 +1,245 @@
+package Database
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// mappingLineRange R8/ProGuard方法行前的行号范围，如 12:15:
+var mappingLineRange = regexp.MustCompile(`^\d+:\d+:`)
+
+// javaPrimitiveDescriptors Java基本类型对应的描述符
+var javaPrimitiveDescriptors = map[string]string{
+	"boolean": "Z",
+	"byte":    "B",
+	"char":    "C",
+	"short":   "S",
+	"int":     "I",
+	"long":    "J",
+	"float":   "F",
+	"double":  "D",
+	"void":    "V",
+}
+
+// proguardMapping 解析后的ProGuard/R8 mapping.txt，键均为混淆后的名称
+type proguardMapping struct {
+	classes map[string]string // 混淆类名 -> 原类名（内部名称）
+	members map[string]string // 混淆类名\x00混淆成员名\x00混淆描述符 -> 原成员名
+}
+
+// mappingMember mapping.txt中的成员，类型为原始类型
+type mappingMember struct {
+	owner      string // 混淆后的类名
+	returnType string
+	name       string
+	args       []string // 为nil表示字段
+	obfName    string
+}
+
+var (
+	loadedMappingOnce sync.Once
+	loadedMapping     *proguardMapping
+)
+
+// customerMapping 返回-mapping指定的映射文件，只解析一次，未指定或解析失败时返回nil
+func customerMapping() *proguardMapping {
+	loadedMappingOnce.Do(func() {
+		if Common.MappingFile == "" {
+			return
+		}
+		mapping, err := parseProguardMapping(Common.MappingFile)
+		if err != nil {
+			color.Red("解析混淆映射文件失败: %v", err)
+			return
+		}
+		color.Green("已加载混淆映射 %s：%d 个类", Common.MappingFile, len(mapping.classes))
+		loadedMapping = mapping
+	})
+	return loadedMapping
+}
+
+// parseProguardMapping 解析ProGuard/R8格式的mapping.txt
+func parseProguardMapping(path string) (*proguardMapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开映射文件失败: %v", err)
+	}
+	defer file.Close()
+
+	mapping := &proguardMapping{
+		classes: make(map[string]string),
+		members: make(map[string]string),
+	}
+	originalToObf := make(map[string]string)
+	var members []mappingMember
+
+	owner := ""
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		parts := strings.SplitN(trimmed, " -> ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		// 类行：com.foo.Bar -> a.b:
+		if line[0] != ' ' && line[0] != '\t' {
+			original := strings.ReplaceAll(parts[0], ".", "/")
+			owner = strings.ReplaceAll(strings.TrimSuffix(parts[1], ":"), ".", "/")
+			mapping.classes[owner] = original
+			originalToObf[original] = owner
+			continue
+		}
+		if owner == "" {
+			continue
+		}
+		if member, ok := parseMappingMember(owner, parts[0], parts[1]); ok {
+			members = append(members, member)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取映射文件失败: %v", err)
+	}
+	if len(mapping.classes) == 0 {
+		return nil, fmt.Errorf("%s 中没有类映射", path)
+	}
+
+	// 成员描述符中的类型是原始名称，转换成混淆后的描述符才能与class文件匹配
+	for _, m := range members {
+		desc := javaTypeDescriptor(m.returnType, originalToObf)
+		if m.args != nil {
+			var args strings.Builder
+			for _, arg := range m.args {
+				args.WriteString(javaTypeDescriptor(arg, originalToObf))
+			}
+			desc = "(" + args.String() + ")" + desc
+		}
+		if m.name != m.obfName {
+			mapping.members[memberKey(m.owner, m.obfName, desc)] = m.name
+		}
+	}
+	return mapping, nil
+}
+
+// parseMappingMember 解析成员行，如 "1:5:void doIt(int,java.lang.String):10:14" 或 "int count"
+func parseMappingMember(owner, left, obfName string) (mappingMember, bool) {
+	left = mappingLineRange.ReplaceAllString(left, "")
+	space := strings.Index(left, " ")
+	if space < 0 {
+		return mappingMember{}, false
+	}
+	member := mappingMember{owner: owner, returnType: left[:space], obfName: obfName}
+	rest := left[space+1:]
+
+	open := strings.Index(rest, "(")
+	if open < 0 {
+		member.name = rest
+		return member, !strings.Contains(rest, ".")
+	}
+	closing := strings.Index(rest, ")")
+	if closing < open {
+		return mappingMember{}, false
+	}
+	member.name = rest[:open]
+	// R8内联方法的名称带有所属类，不是本类声明的方法
+	if strings.Contains(member.name, ".") {
+		return mappingMember{}, false
+	}
+	member.args = []string{}
+	if args := rest[open+1 : closing]; args != "" {
+		member.args = strings.Split(args, ",")
+	}
+	return member, true
+}
+
+// javaTypeDescriptor 将Java源码类型（如 java.lang.String[]）转换为描述符，类名按映射转换
+func javaTypeDescriptor(javaType string, classMap map[string]string) string {
+	javaType = strings.TrimSpace(javaType)
+	dims := 0
+	for strings.HasSuffix(javaType, "[]") {
+		javaType = strings.TrimSuffix(javaType, "[]")
+		dims++
+	}
+	desc, ok := javaPrimitiveDescriptors[javaType]
+	if !ok {
+		internal := strings.ReplaceAll(javaType, ".", "/")
+		if mapped, ok := classMap[internal]; ok {
+			internal = mapped
+		}
+		desc = "L" + internal + ";"
+	}
+	return strings.Repeat("[", dims) + desc
+}
+
+// memberKey 成员索引键
+func memberKey(owner, name, desc string) string {
+	return owner + "\x00" + name + "\x00" + desc
+}
+
+// covers 判断映射是否包含这些类中的任意一个
+func (m *proguardMapping) covers(classes []*classFile) bool {
+	for _, cf := range classes {
+		if _, ok := m.classes[cf.name()]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingRenamer 按mapping.txt改名，成员沿当前输入中的继承关系查找
+type mappingRenamer struct {
+	mapping *proguardMapping
+	supers  map[string][]string
+}
+
+// renamerFor 返回用于指定类集合的改名规则
+func (m *proguardMapping) renamerFor(classes []*classFile) *mappingRenamer {
+	supers := make(map[string][]string, len(classes))
+	for _, cf := range classes {
+		parents := cf.interfaceNames()
+		if super := cf.superName(); super != "" {
+			parents = append([]string{super}, parents...)
+		}
+		supers[cf.name()] = parents
+	}
+	return &mappingRenamer{mapping: m, supers: supers}
+}
+
+func (r *mappingRenamer) renameClass(internal string) (string, bool) {
+	name, ok := r.mapping.classes[internal]
+	return name, ok && name != internal
+}
+
+func (r *mappingRenamer) renameMember(owner, name, desc string, method bool) (string, bool) {
+	if name == "<init>" || name == "<clinit>" {
+		return "", false
+	}
+	visited := make(map[string]bool)
+	queue := []string{owner}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		if original, ok := r.mapping.members[memberKey(current, name, desc)]; ok {
+			return original, true
+		}
+		queue = append(queue, r.supers[current]...)
+	}
+	return "", false
+}
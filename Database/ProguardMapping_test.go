@@ -0,0 +1,63 @@
+package Database
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func loadTestMapping(t *testing.T) *proguardMapping {
+	t.Helper()
+	mapping, err := parseProguardMapping(filepath.Join("testdata", "mapping", "r8-mapping.txt"))
+	if err != nil {
+		t.Fatalf("parseProguardMapping: %v", err)
+	}
+	return mapping
+}
+
+func TestParseProguardMappingWithR8InlineFrames(t *testing.T) {
+	mapping := loadTestMapping(t)
+
+	wantClasses := map[string]string{
+		"a/b":   "com/acme/Outer",
+		"a/b$c": "com/acme/Outer$Inner",
+		"a/a":   "com/acme/Helper",
+	}
+	if !reflect.DeepEqual(mapping.classes, wantClasses) {
+		t.Errorf("classes = %v, want %v", mapping.classes, wantClasses)
+	}
+
+	// 内联帧（com.acme.Helper.log）不是a.b声明的方法，同一方法的多个行号范围只记录一次
+	wantMembers := map[string]string{
+		memberKey("a/b", "c", "La/b$c;"):                      "inner",
+		memberKey("a/b", "f", "Ljava/util/List;"):             "items",
+		memberKey("a/b", "d", "(La/b$c;I)Ljava/lang/String;"): "name",
+		memberKey("a/b", "a", "()V"):                          "reset",
+		memberKey("a/b$c", "e", "()V"):                        "run",
+		memberKey("a/a", "a", "(Ljava/lang/String;)V"):        "log",
+	}
+	if !reflect.DeepEqual(mapping.members, wantMembers) {
+		t.Errorf("members = %v, want %v", mapping.members, wantMembers)
+	}
+}
+
+func TestMappingRenamerResolvesInheritedMembers(t *testing.T) {
+	mapping := loadTestMapping(t)
+	b := newTestClassBuilder()
+	sub, err := parseClassFile(b.build("x/y", "a/b", nil, nil, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamer := mapping.renamerFor([]*classFile{sub})
+
+	// x.y没有出现在映射中，继承自a.b的方法按父类的映射还原
+	if name, ok := renamer.renameMember("x/y", "d", "(La/b$c;I)Ljava/lang/String;", true); !ok || name != "name" {
+		t.Errorf("inherited method = %q, %v", name, ok)
+	}
+	if _, ok := renamer.renameMember("x/y", "z", "()V", true); ok {
+		t.Error("unmapped member was renamed")
+	}
+	if _, ok := renamer.renameClass("x/y"); ok {
+		t.Error("unmapped class was renamed")
+	}
+}
@@ -0,0 +1,83 @@
+package Database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// resourceDirName 非Java资源在源码根目录下的存放目录
+const resourceDirName = "resources"
+
+// maxResourceSize 单个资源文件的大小上限，避免把超大的数据文件送进提取器
+const maxResourceSize = 10 << 20
+
+// resourceExts 需要随源码一起提取的资源类型（Spring/Struts/MyBatis配置、web.xml、JSP等）
+var resourceExts = map[string]bool{
+	".xml":        true,
+	".properties": true,
+	".yml":        true,
+	".yaml":       true,
+	".jsp":        true,
+	".jspx":       true,
+	".jspf":       true,
+	".tag":        true,
+	".tagx":       true,
+	".tld":        true,
+	".ftl":        true,
+	".vm":         true,
+}
+
+// resourceSkipDirs 不复制资源的目录（依赖jar目录和内部暂存目录）
+var resourceSkipDirs = []string{
+	"WEB-INF/lib",
+	"BOOT-INF/lib",
+	".classes",
+}
+
+// copyResources 将解压目录中的配置文件和JSP按原路径复制到createdabase/resources，供CodeQL提取XML和properties
+func copyResources(outputDir, createDir string) (int, error) {
+	destDir := filepath.Join(createDir, resourceDirName)
+	count := 0
+
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		relSlash := filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			for _, skip := range resourceSkipDirs {
+				if relSlash == skip || strings.HasSuffix(relSlash, "/"+skip) {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		if !resourceExts[strings.ToLower(filepath.Ext(path))] || info.Size() > maxResourceSize {
+			return nil
+		}
+		count++
+		return Common.CopyFile(path, filepath.Join(destDir, rel))
+	})
+	return count, err
+}
+
+// collectResources 复制资源文件并输出统计
+func collectResources(location string) {
+	count, err := copyResources(filepath.Join(location, "output"), filepath.Join(location, "createdabase"))
+	if err != nil {
+		color.Red("复制资源文件失败: %v", err)
+		return
+	}
+	color.Green("已复制 %d 个资源文件（XML/properties/YAML/JSP）到源码根目录", count)
+}
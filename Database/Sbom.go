@@ -0,0 +1,363 @@
+package Database
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// jarFileVersion 从jar文件名中解析版本号，如foo-bar-1.2.3.RELEASE.jar
+var jarFileVersion = regexp.MustCompile(`^(.+?)-(\d[\w.\-]*)\.jar$`)
+
+// sbomComponent 一个依赖组件的识别结果
+type sbomComponent struct {
+	Path     string // 相对解压目录的路径
+	FileName string
+	Group    string
+	Name     string
+	Version  string
+	Supplier string
+	SHA1     string
+	SHA256   string
+	Source   string // 识别依据：pom.properties|MANIFEST.MF|filename
+}
+
+// PURL 返回Maven的package URL，无groupId时为空
+func (c sbomComponent) PURL() string {
+	if c.Group == "" || c.Name == "" {
+		return ""
+	}
+	purl := fmt.Sprintf("pkg:maven/%s/%s", c.Group, c.Name)
+	if c.Version != "" {
+		purl += "@" + c.Version
+	}
+	return purl
+}
+
+// identifyJar 根据pom.properties、MANIFEST.MF和文件名识别jar的坐标，并计算哈希
+func identifyJar(jarPath string) (sbomComponent, error) {
+	component := sbomComponent{
+		Path:     jarPath,
+		FileName: filepath.Base(jarPath),
+	}
+
+	h1, h256 := sha1.New(), sha256.New()
+	if _, err := hashFile(jarPath, h1, h256); err != nil {
+		return component, err
+	}
+	component.SHA1 = hex.EncodeToString(h1.Sum(nil))
+	component.SHA256 = hex.EncodeToString(h256.Sum(nil))
+
+	info, err := inspectJar(jarPath)
+	if err != nil {
+		// 无法作为zip读取时只按文件名和哈希识别
+		inferFromFileName(&component, "")
+		return component, nil
+	}
+	component.Supplier = manifestVendor(info.Manifest)
+
+	// 优先使用pom.properties；shaded jar可能有多个，取与文件名匹配的那个
+	if len(info.Coordinates) > 0 {
+		coord := info.Coordinates[0]
+		for _, candidate := range info.Coordinates {
+			if strings.HasPrefix(component.FileName, candidate.ArtifactID) {
+				coord = candidate
+				break
+			}
+		}
+		component.Group = coord.GroupID
+		component.Name = coord.ArtifactID
+		component.Version = coord.Version
+		component.Source = "pom.properties"
+		return component, nil
+	}
+
+	// 其次使用MANIFEST.MF
+	name := firstNonEmpty(info.Manifest["Bundle-SymbolicName"], info.Manifest["Implementation-Title"], info.Manifest["Automatic-Module-Name"])
+	version := firstNonEmpty(info.Manifest["Bundle-Version"], info.Manifest["Implementation-Version"])
+	if name != "" {
+		// Bundle-SymbolicName可能带有;singleton:=true等指令
+		component.Name = strings.TrimSpace(strings.Split(name, ";")[0])
+		component.Version = version
+		component.Group = info.Manifest["Implementation-Vendor-Id"]
+		component.Source = "MANIFEST.MF"
+		return component, nil
+	}
+
+	// 最后从文件名推断
+	inferFromFileName(&component, version)
+	return component, nil
+}
+
+// inferFromFileName 从jar文件名推断名称和版本
+func inferFromFileName(component *sbomComponent, version string) {
+	component.Source = "filename"
+	if match := jarFileVersion.FindStringSubmatch(component.FileName); match != nil {
+		component.Name = match[1]
+		component.Version = firstNonEmpty(version, match[2])
+	} else {
+		component.Name = strings.TrimSuffix(component.FileName, filepath.Ext(component.FileName))
+		component.Version = version
+	}
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// collectSbomComponents 递归查找解压目录中的所有jar并识别
+func collectSbomComponents(outputDir string) []sbomComponent {
+	var components []sbomComponent
+	filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".jar") {
+			return nil
+		}
+		component, err := identifyJar(path)
+		if err != nil {
+			color.Yellow("识别依赖 %s 失败: %v", info.Name(), err)
+			return nil
+		}
+		if rel, err := filepath.Rel(outputDir, path); err == nil {
+			component.Path = filepath.ToSlash(rel)
+		}
+		components = append(components, component)
+		return nil
+	})
+
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].Path < components[j].Path
+	})
+	return components
+}
+
+// newUUID 生成随机UUID（v4）
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// writeCycloneDX 生成CycloneDX 1.5 JSON格式的SBOM
+func writeCycloneDX(path string, artifact sbomComponent, components []sbomComponent) error {
+	type cdxHash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+	type cdxProperty struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	type cdxComponent struct {
+		Type       string        `json:"type"`
+		BomRef     string        `json:"bom-ref"`
+		Group      string        `json:"group,omitempty"`
+		Name       string        `json:"name"`
+		Version    string        `json:"version,omitempty"`
+		Publisher  string        `json:"publisher,omitempty"`
+		Purl       string        `json:"purl,omitempty"`
+		Hashes     []cdxHash     `json:"hashes,omitempty"`
+		Properties []cdxProperty `json:"properties,omitempty"`
+	}
+
+	toCdx := func(c sbomComponent, typ, ref string) cdxComponent {
+		component := cdxComponent{
+			Type:      typ,
+			BomRef:    ref,
+			Group:     c.Group,
+			Name:      c.Name,
+			Version:   c.Version,
+			Publisher: c.Supplier,
+			Purl:      c.PURL(),
+			Properties: []cdxProperty{
+				{Name: "n1ght:path", Value: c.Path},
+				{Name: "n1ght:identifiedBy", Value: c.Source},
+			},
+		}
+		// 未能计算的哈希不写入，空值不是合法的摘要
+		if c.SHA1 != "" {
+			component.Hashes = append(component.Hashes, cdxHash{Alg: "SHA-1", Content: c.SHA1})
+		}
+		if c.SHA256 != "" {
+			component.Hashes = append(component.Hashes, cdxHash{Alg: "SHA-256", Content: c.SHA256})
+		}
+		return component
+	}
+
+	var cdxComponents []cdxComponent
+	var refs []string
+	for i, c := range components {
+		ref := fmt.Sprintf("component-%d", i+1)
+		refs = append(refs, ref)
+		cdxComponents = append(cdxComponents, toCdx(c, "library", ref))
+	}
+
+	bom := map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + newUUID(),
+		"version":      1,
+		"metadata": map[string]interface{}{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools":     []map[string]string{{"vendor": "codeql_n1ght", "name": "codeql_n1ght"}},
+			"component": toCdx(artifact, "application", "root"),
+		},
+		"components": cdxComponents,
+		"dependencies": []map[string]interface{}{
+			{"ref": "root", "dependsOn": refs},
+		},
+	}
+	return writeJSONFile(path, bom)
+}
+
+// writeSPDX 生成SPDX 2.3 JSON格式的SBOM
+func writeSPDX(path string, artifact sbomComponent, components []sbomComponent) error {
+	type spdxChecksum struct {
+		Algorithm     string `json:"algorithm"`
+		ChecksumValue string `json:"checksumValue"`
+	}
+	type spdxExternalRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+	type spdxPackage struct {
+		SPDXID           string            `json:"SPDXID"`
+		Name             string            `json:"name"`
+		VersionInfo      string            `json:"versionInfo,omitempty"`
+		PackageFileName  string            `json:"packageFileName"`
+		Supplier         string            `json:"supplier"`
+		DownloadLocation string            `json:"downloadLocation"`
+		FilesAnalyzed    bool              `json:"filesAnalyzed"`
+		Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+		ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	}
+	type spdxRelationship struct {
+		SpdxElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSpdxElement string `json:"relatedSpdxElement"`
+	}
+
+	toSpdx := func(c sbomComponent, id string) spdxPackage {
+		pkg := spdxPackage{
+			SPDXID:           id,
+			Name:             c.Name,
+			VersionInfo:      c.Version,
+			PackageFileName:  c.Path,
+			Supplier:         "NOASSERTION",
+			DownloadLocation: "NOASSERTION",
+		}
+		if c.SHA1 != "" {
+			pkg.Checksums = append(pkg.Checksums, spdxChecksum{Algorithm: "SHA1", ChecksumValue: c.SHA1})
+		}
+		if c.SHA256 != "" {
+			pkg.Checksums = append(pkg.Checksums, spdxChecksum{Algorithm: "SHA256", ChecksumValue: c.SHA256})
+		}
+		if c.Supplier != "" {
+			pkg.Supplier = "Organization: " + c.Supplier
+		}
+		if purl := c.PURL(); purl != "" {
+			pkg.ExternalRefs = []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  purl,
+			}}
+		}
+		return pkg
+	}
+
+	packages := []spdxPackage{toSpdx(artifact, "SPDXRef-Package-root")}
+	relationships := []spdxRelationship{{
+		SpdxElementID:      "SPDXRef-DOCUMENT",
+		RelationshipType:   "DESCRIBES",
+		RelatedSpdxElement: "SPDXRef-Package-root",
+	}}
+	for i, c := range components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		packages = append(packages, toSpdx(c, id))
+		relationships = append(relationships, spdxRelationship{
+			SpdxElementID:      "SPDXRef-Package-root",
+			RelationshipType:   "CONTAINS",
+			RelatedSpdxElement: id,
+		})
+	}
+
+	doc := map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              artifact.FileName,
+		"documentNamespace": "https://github.com/yezere/codeql_n1ght/spdx/" + newUUID(),
+		"creationInfo": map[string]interface{}{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: codeql_n1ght"},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+	return writeJSONFile(path, doc)
+}
+
+// writeJSONFile 以缩进格式写入JSON文件
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// generateSbom 为输入制品生成CycloneDX和SPDX格式的SBOM，写在数据库旁边
+func generateSbom(jar, dbPath string, components []sbomComponent) error {
+	artifact := sbomComponent{
+		Path:     filepath.Base(jar),
+		FileName: filepath.Base(jar),
+		Name:     strings.TrimSuffix(filepath.Base(jar), filepath.Ext(jar)),
+		Source:   "filename",
+	}
+	if Common.IsDirectory(jar) {
+		// 目录没有文件哈希，使用与Manifest相同的目录摘要
+		if sum, _, err := directoryHash(jar); err == nil {
+			artifact.SHA256 = sum
+		}
+	} else if identified, err := identifyJar(jar); err == nil {
+		artifact = identified
+		artifact.Path = filepath.Base(jar)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return err
+	}
+
+	cdxPath := dbPath + ".cdx.json"
+	if err := writeCycloneDX(cdxPath, artifact, components); err != nil {
+		return fmt.Errorf("写入CycloneDX失败: %v", err)
+	}
+	spdxPath := dbPath + ".spdx.json"
+	if err := writeSPDX(spdxPath, artifact, components); err != nil {
+		return fmt.Errorf("写入SPDX失败: %v", err)
+	}
+
+	color.Green("SBOM已生成（%d 个组件）: %s, %s", len(components), cdxPath, spdxPath)
+	return nil
+}
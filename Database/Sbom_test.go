@@ -0,0 +1,47 @@
+package Database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSbomOmitsMissingHashes(t *testing.T) {
+	dir := t.TempDir()
+	classes := filepath.Join(dir, "classes")
+	if err := os.MkdirAll(filepath.Join(classes, "com", "acme"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(classes, "com", "acme", "App.class"), []byte("class"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 无法读取的jar只有文件名，没有哈希
+	components := []sbomComponent{{Path: "WEB-INF/lib/broken.jar", FileName: "broken.jar", Name: "broken", Source: "filename"}}
+	dbPath := filepath.Join(dir, "databases", "classes")
+	if err := generateSbom(classes, dbPath, components); err != nil {
+		t.Fatal(err)
+	}
+	sum, _, err := directoryHash(classes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, suffix := range []string{".cdx.json", ".spdx.json"} {
+		data, err := os.ReadFile(dbPath + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := string(data)
+		if strings.Contains(text, `"content": ""`) || strings.Contains(text, `"checksumValue": ""`) {
+			t.Errorf("%s contains an empty hash:\n%s", suffix, text)
+		}
+		// 目录制品使用目录摘要，依赖没有哈希时不写hashes/checksums
+		if strings.Count(text, sum) != 1 {
+			t.Errorf("%s does not carry the directory hash once:\n%s", suffix, text)
+		}
+		if strings.Contains(text, "SHA-1") || strings.Contains(text, `"SHA1"`) {
+			t.Errorf("%s contains a SHA-1 entry without a value:\n%s", suffix, text)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package Database
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// springBootLayout Spring Boot可执行包的目录结构
+type springBootLayout struct {
+	ClassesDir string // 应用自身的class目录，如BOOT-INF/classes
+	LibDir     string // 依赖jar目录，如BOOT-INF/lib
+}
+
+// parseManifest 解析MANIFEST.MF内容，处理以空格开头的续行
+func parseManifest(data []byte) map[string]string {
+	attrs := make(map[string]string)
+	var lastKey string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			// 只读取主属性段
+			if len(attrs) > 0 {
+				break
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") {
+			if lastKey != "" {
+				attrs[lastKey] += line[1:]
+			}
+			continue
+		}
+		if idx := strings.Index(line, ":"); idx > 0 {
+			lastKey = strings.TrimSpace(line[:idx])
+			attrs[lastKey] = strings.TrimSpace(line[idx+1:])
+		}
+	}
+	return attrs
+}
+
+// readManifestFile 读取解压目录下的META-INF/MANIFEST.MF
+func readManifestFile(dir string) map[string]string {
+	data, err := os.ReadFile(filepath.Join(dir, "META-INF", "MANIFEST.MF"))
+	if err != nil {
+		return nil
+	}
+	return parseManifest(data)
+}
+
+// detectSpringBootLayout 根据MANIFEST.MF中的Spring-Boot-Classes/Spring-Boot-Lib识别Spring Boot包，与扩展名无关
+func detectSpringBootLayout(outputDir string) (*springBootLayout, bool) {
+	attrs := readManifestFile(outputDir)
+	classes := strings.TrimRight(attrs["Spring-Boot-Classes"], "/")
+	lib := strings.TrimRight(attrs["Spring-Boot-Lib"], "/")
+	if classes == "" && lib == "" {
+		return nil, false
+	}
+
+	// MANIFEST.MF来自被分析的制品，不可信：与EAR模块路径一样拒绝绝对路径和包含..的路径
+	if classes != "" && !safeEarURI(classes) {
+		color.Red("忽略不安全的Spring-Boot-Classes: %s", classes)
+		classes = ""
+	}
+	if lib != "" && !safeEarURI(lib) {
+		color.Red("忽略不安全的Spring-Boot-Lib: %s", lib)
+		lib = ""
+	}
+
+	// 只声明了其中一项时，另一项使用Spring Boot默认值
+	if classes == "" {
+		classes = "BOOT-INF/classes"
+	}
+	if lib == "" {
+		lib = "BOOT-INF/lib"
+	}
+	return &springBootLayout{
+		ClassesDir: filepath.Join(outputDir, filepath.FromSlash(classes)),
+		LibDir:     filepath.Join(outputDir, filepath.FromSlash(lib)),
+	}, true
+}
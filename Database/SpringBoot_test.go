@@ -0,0 +1,38 @@
+package Database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectSpringBootLayoutRejectsUnsafePaths(t *testing.T) {
+	tests := []struct {
+		name, manifest, classes, lib string
+	}{
+		{"default layout", "Spring-Boot-Classes: BOOT-INF/classes/\nSpring-Boot-Lib: BOOT-INF/lib/\n", "BOOT-INF/classes", "BOOT-INF/lib"},
+		{"custom layout", "Spring-Boot-Classes: app/classes/\nSpring-Boot-Lib: app/lib/\n", "app/classes", "app/lib"},
+		{"parent directory", "Spring-Boot-Classes: ../../home/user/classes\nSpring-Boot-Lib: BOOT-INF/../../lib\n", "BOOT-INF/classes", "BOOT-INF/lib"},
+		{"absolute path", "Spring-Boot-Classes: /etc/classes\nSpring-Boot-Lib: \\\\lib\n", "BOOT-INF/classes", "BOOT-INF/lib"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dir, "META-INF"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "META-INF", "MANIFEST.MF"), []byte("Manifest-Version: 1.0\n"+tt.manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		layout, ok := detectSpringBootLayout(dir)
+		if !ok {
+			t.Errorf("%s: not detected", tt.name)
+			continue
+		}
+		if want := filepath.Join(dir, filepath.FromSlash(tt.classes)); layout.ClassesDir != want {
+			t.Errorf("%s: classes = %s, want %s", tt.name, layout.ClassesDir, want)
+		}
+		if want := filepath.Join(dir, filepath.FromSlash(tt.lib)); layout.LibDir != want {
+			t.Errorf("%s: lib = %s, want %s", tt.name, layout.LibDir, want)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package Database
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"codeql_n1ght/Common"
+
+	"github.com/fatih/color"
+)
+
+// osvEntry OSV格式的漏洞记录（只解析用到的字段）
+type osvEntry struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases"`
+	Summary  string   `json:"summary"`
+	Affected []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string              `json:"type"`
+			Events []map[string]string `json:"events"`
+		} `json:"ranges"`
+		Versions []string `json:"versions"`
+	} `json:"affected"`
+}
+
+// VulnerabilityFinding 依赖jar命中的一条漏洞
+type VulnerabilityFinding struct {
+	Jar            string   `json:"jar"`
+	Component      string   `json:"component"`
+	ID             string   `json:"id"`
+	CVEs           []string `json:"cves,omitempty"`
+	Summary        string   `json:"summary,omitempty"`
+	AffectedRanges []string `json:"affectedRanges,omitempty"`
+	FixedVersions  []string `json:"fixedVersions,omitempty"`
+}
+
+// osvFeed 按Maven的groupId:artifactId索引的漏洞库
+type osvFeed map[string][]*osvEntry
+
+// 漏洞库只加载一次，批量模式下各制品共用
+var (
+	feedOnce   sync.Once
+	loadedFeed osvFeed
+	feedErr    error
+)
+
+// getVulnerabilityFeed 加载-vuln-db指定的本地漏洞库，未指定时返回nil
+func getVulnerabilityFeed() (osvFeed, error) {
+	if Common.VulnFeedPath == "" {
+		return nil, nil
+	}
+	feedOnce.Do(func() {
+		loadedFeed, feedErr = loadOsvFeed(Common.VulnFeedPath)
+		if feedErr == nil {
+			color.Green("已加载本地漏洞库: %s（%d 个Maven构件）", Common.VulnFeedPath, len(loadedFeed))
+		}
+	})
+	return loadedFeed, feedErr
+}
+
+// loadOsvFeed 从OSV导出目录（*.json）或导出压缩包（如Maven的all.zip）加载漏洞库
+func loadOsvFeed(path string) (osvFeed, error) {
+	feed := make(osvFeed)
+
+	if Common.IsDirectory(path) {
+		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			feed.add(data)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("读取漏洞库目录失败: %v", err)
+		}
+		return feed, nil
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开漏洞库失败: %v", err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		feed.add(data)
+	}
+	return feed, nil
+}
+
+// add 解析一条OSV记录并按Maven构件建立索引，无法解析的记录忽略
+func (f osvFeed) add(data []byte) {
+	var entry osvEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, affected := range entry.Affected {
+		if !strings.EqualFold(affected.Package.Ecosystem, "Maven") {
+			continue
+		}
+		name := affected.Package.Name
+		if !seen[name] {
+			seen[name] = true
+			f[name] = append(f[name], &entry)
+		}
+	}
+}
+
+// match 返回组件版本命中的漏洞
+func (f osvFeed) match(component sbomComponent) []VulnerabilityFinding {
+	if component.Group == "" || component.Version == "" {
+		return nil
+	}
+	key := component.Group + ":" + component.Name
+
+	var findings []VulnerabilityFinding
+	for _, entry := range f[key] {
+		finding := VulnerabilityFinding{
+			Jar:       component.Path,
+			Component: key + ":" + component.Version,
+			ID:        entry.ID,
+			Summary:   entry.Summary,
+		}
+		affected := false
+		for _, a := range entry.Affected {
+			if a.Package.Name != key {
+				continue
+			}
+			for _, version := range a.Versions {
+				if version == component.Version {
+					affected = true
+				}
+			}
+			for _, r := range a.Ranges {
+				if r.Type != "ECOSYSTEM" {
+					continue
+				}
+				desc, fixed, hit := evaluateOsvRange(r.Events, component.Version)
+				finding.AffectedRanges = append(finding.AffectedRanges, desc...)
+				finding.FixedVersions = append(finding.FixedVersions, fixed...)
+				affected = affected || hit
+			}
+		}
+		if !affected {
+			continue
+		}
+		for _, alias := range append([]string{entry.ID}, entry.Aliases...) {
+			if strings.HasPrefix(alias, "CVE-") {
+				finding.CVEs = append(finding.CVEs, alias)
+			}
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// evaluateOsvRange 计算OSV区间事件，返回区间描述、修复版本以及版本是否受影响
+func evaluateOsvRange(events []map[string]string, version string) ([]string, []string, bool) {
+	var ranges, fixedVersions []string
+	affected := false
+	introduced := ""
+	open := false
+
+	closeRange := func(upper string, inclusive bool) {
+		desc := ">=" + introduced
+		if introduced == "0" {
+			desc = ""
+		}
+		op := "<"
+		if inclusive {
+			op = "<="
+		}
+		if desc != "" {
+			desc += ", "
+		}
+		desc += op + upper
+		ranges = append(ranges, desc)
+
+		if compareMavenVersions(version, introduced) >= 0 {
+			cmp := compareMavenVersions(version, upper)
+			if cmp < 0 || (inclusive && cmp == 0) {
+				affected = true
+			}
+		}
+		open = false
+	}
+
+	for _, event := range events {
+		switch {
+		case event["introduced"] != "":
+			introduced = event["introduced"]
+			open = true
+		case event["fixed"] != "" && open:
+			fixedVersions = append(fixedVersions, event["fixed"])
+			closeRange(event["fixed"], false)
+		case event["last_affected"] != "" && open:
+			closeRange(event["last_affected"], true)
+		}
+	}
+
+	// 没有修复版本的区间
+	if open {
+		ranges = append(ranges, ">="+introduced)
+		if compareMavenVersions(version, introduced) >= 0 {
+			affected = true
+		}
+	}
+	return ranges, fixedVersions, affected
+}
+
+// mavenQualifierOrder Maven版本限定符的先后顺序，未知限定符排在snapshot和release之间并按字母比较
+var mavenQualifierOrder = map[string]int{
+	"alpha": 10, "a": 10,
+	"beta": 20, "b": 20,
+	"milestone": 30, "m": 30,
+	"rc": 40, "cr": 40,
+	"snapshot": 50,
+	"":         60, "ga": 60, "final": 60, "release": 60,
+	"sp": 70,
+}
+
+// unknownQualifierOrder 未知限定符的顺序
+const unknownQualifierOrder = 55
+
+// splitMavenVersion 按分隔符以及数字/字母交界拆分版本号
+func splitMavenVersion(version string) []string {
+	var tokens []string
+	var current strings.Builder
+	lastDigit := false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, strings.ToLower(current.String()))
+			current.Reset()
+		}
+	}
+	for i, r := range version {
+		if r == '.' || r == '-' || r == '_' || r == '+' {
+			flush()
+			continue
+		}
+		isDigit := unicode.IsDigit(r)
+		if i > 0 && current.Len() > 0 && isDigit != lastDigit {
+			flush()
+		}
+		current.WriteRune(r)
+		lastDigit = isDigit
+	}
+	flush()
+	return tokens
+}
+
+// trimMavenZeros 去掉每段数字末尾的0（保留段首），与Maven一致使1.0.0-rc1与1.0-rc1相同
+func trimMavenZeros(tokens []string) []string {
+	isNum := func(i int) bool {
+		_, err := strconv.Atoi(tokens[i])
+		return err == nil
+	}
+	var result []string
+	for i, token := range tokens {
+		if token == "0" && i > 0 && isNum(i-1) && len(result) > 0 {
+			// 之后直到限定符或结尾都是0时才去掉
+			trailing := true
+			for j := i + 1; j < len(tokens) && isNum(j); j++ {
+				if tokens[j] != "0" {
+					trailing = false
+					break
+				}
+			}
+			if trailing {
+				continue
+			}
+		}
+		result = append(result, token)
+	}
+	return result
+}
+
+// compareMavenVersions 比较两个Maven版本号，返回-1、0、1
+func compareMavenVersions(a, b string) int {
+	ta, tb := trimMavenZeros(splitMavenVersion(a)), trimMavenZeros(splitMavenVersion(b))
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		var x, y string
+		if i < len(ta) {
+			x = ta[i]
+		}
+		if i < len(tb) {
+			y = tb[i]
+		}
+		if c := compareMavenToken(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareMavenToken 比较版本号中的单个片段，缺失的片段视为0或release
+func compareMavenToken(x, y string) int {
+	nx, errX := strconv.Atoi(x)
+	ny, errY := strconv.Atoi(y)
+	xNum, yNum := errX == nil, errY == nil
+
+	switch {
+	case xNum && yNum:
+		return compareInt(nx, ny)
+	case xNum && y == "":
+		return compareInt(nx, 0)
+	case yNum && x == "":
+		return compareInt(0, ny)
+	case xNum:
+		// 数字比限定符新，如1.0.1 > 1.0-rc1
+		return 1
+	case yNum:
+		return -1
+	}
+
+	ox, ok := mavenQualifierOrder[x]
+	if !ok {
+		ox = unknownQualifierOrder
+	}
+	oy, ok := mavenQualifierOrder[y]
+	if !ok {
+		oy = unknownQualifierOrder
+	}
+	if ox != oy {
+		return compareInt(ox, oy)
+	}
+	if ox == unknownQualifierOrder {
+		return strings.Compare(x, y)
+	}
+	return 0
+}
+
+// compareInt 比较两个整数
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// matchVulnerabilities 对一组组件匹配本地漏洞库
+func matchVulnerabilities(feed osvFeed, components []sbomComponent) []VulnerabilityFinding {
+	var findings []VulnerabilityFinding
+	for _, component := range components {
+		findings = append(findings, feed.match(component)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Jar < findings[j].Jar
+	})
+	return findings
+}
+
+// writeVulnerabilityReport 输出依赖漏洞报告到数据库旁的<db>.vulns.json并打印摘要
+func writeVulnerabilityReport(location, dbPath string, components []sbomComponent) error {
+	feed, err := getVulnerabilityFeed()
+	if err != nil || feed == nil {
+		return err
+	}
+
+	findings := matchVulnerabilities(feed, components)
+	updateMetadata(location, func(meta *DatabaseMetadata) {
+		meta.Vulnerabilities = findings
+	})
+
+	reportPath := dbPath + ".vulns.json"
+	if err := os.MkdirAll(filepath.Dir(reportPath), 0755); err != nil {
+		return err
+	}
+	if err := writeJSONFile(reportPath, findings); err != nil {
+		return err
+	}
+
+	if len(findings) == 0 {
+		color.Green("未在本地漏洞库中发现受影响的依赖")
+		return nil
+	}
+	color.Red("发现 %d 条依赖漏洞：", len(findings))
+	for _, finding := range findings {
+		ids := finding.ID
+		if len(finding.CVEs) > 0 {
+			ids = strings.Join(finding.CVEs, ",")
+		}
+		fmt.Printf("  %s (%s) %s 受影响区间: %s 修复版本: %s\n",
+			finding.Jar, finding.Component, ids,
+			strings.Join(finding.AffectedRanges, "; "), strings.Join(finding.FixedVersions, ", "))
+	}
+	color.Green("漏洞报告已写入: %s", reportPath)
+	return nil
+}
+
+// vulnerableJars 识别依赖jar并返回命中漏洞的文件名及对应漏洞编号，供依赖选择时标记
+func vulnerableJars(jarFiles []string) map[string][]string {
+	feed, err := getVulnerabilityFeed()
+	if err != nil {
+		color.Red("加载本地漏洞库失败: %v", err)
+		return nil
+	}
+	if feed == nil {
+		return nil
+	}
+
+	result := make(map[string][]string)
+	for _, jarFile := range jarFiles {
+		component, err := identifyJar(jarFile)
+		if err != nil {
+			continue
+		}
+		for _, finding := range feed.match(component) {
+			id := finding.ID
+			if len(finding.CVEs) > 0 {
+				id = finding.CVEs[0]
+			}
+			name := dependencyName(jarFile)
+			result[name] = append(result[name], id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package Database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareMavenVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0.0", 0},
+		{"1.0", "1", 0},
+		{"1.0-ga", "1.0", 0},
+		{"1.0.0-rc1", "1.0-rc1", 0},
+		{"1.10", "1.9", 1},
+		{"2.0", "1.10.5", 1},
+		{"1.0-alpha1", "1.0-beta1", -1},
+		{"1.0-beta1", "1.0-M1", -1},
+		{"1.0-M1", "1.0-rc1", -1},
+		{"1.0-rc1", "1.0-rc2", -1},
+		{"1.0-rc1", "1.0-SNAPSHOT", -1},
+		{"1.0-SNAPSHOT", "1.0", -1},
+		{"1.0-rc1", "1.0", -1},
+		{"1.0", "1.0-sp1", -1},
+		{"1.0-sp1", "1.0.1", -1},
+		{"1.0.1", "1.0-rc1", 1},
+		{"2.17.1", "2.17.0", 1},
+	}
+	for _, tt := range tests {
+		if got := compareMavenVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareMavenVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareMavenVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareMavenVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestSplitMavenVersion(t *testing.T) {
+	tests := map[string][]string{
+		"1.2.3":           {"1", "2", "3"},
+		"2.0.0-RC1":       {"2", "0", "0", "rc", "1"},
+		"1.0.0.Final":     {"1", "0", "0", "final"},
+		"5.3.18_sp2":      {"5", "3", "18", "sp", "2"},
+		"1.0-SNAPSHOT+b7": {"1", "0", "snapshot", "b", "7"},
+	}
+	for version, want := range tests {
+		if got := splitMavenVersion(version); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitMavenVersion(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestEvaluateOsvRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []map[string]string
+		version  string
+		ranges   []string
+		fixed    []string
+		affected bool
+	}{
+		{"before fixed", []map[string]string{{"introduced": "0"}, {"fixed": "2.0"}}, "1.5", []string{"<2.0"}, []string{"2.0"}, true},
+		{"fixed version", []map[string]string{{"introduced": "0"}, {"fixed": "2.0"}}, "2.0.0", []string{"<2.0"}, []string{"2.0"}, false},
+		{"release candidate of fixed version", []map[string]string{{"introduced": "0"}, {"fixed": "2.0"}}, "2.0-rc1", []string{"<2.0"}, []string{"2.0"}, true},
+		{"last affected", []map[string]string{{"introduced": "1.0"}, {"last_affected": "1.5"}}, "1.5", []string{">=1.0, <=1.5"}, nil, true},
+		{"after last affected", []map[string]string{{"introduced": "1.0"}, {"last_affected": "1.5"}}, "1.5.1", []string{">=1.0, <=1.5"}, nil, false},
+		{"before introduced", []map[string]string{{"introduced": "1.0"}, {"last_affected": "1.5"}}, "0.9", []string{">=1.0, <=1.5"}, nil, false},
+		{"introduced without fixed", []map[string]string{{"introduced": "3.0"}}, "3.1", []string{">=3.0"}, nil, true},
+		{"before open range", []map[string]string{{"introduced": "3.0"}}, "2.9", []string{">=3.0"}, nil, false},
+		{"between two ranges", []map[string]string{{"introduced": "1.0"}, {"fixed": "1.2"}, {"introduced": "2.0"}, {"fixed": "2.3"}}, "1.5", []string{">=1.0, <1.2", ">=2.0, <2.3"}, []string{"1.2", "2.3"}, false},
+		{"second range", []map[string]string{{"introduced": "1.0"}, {"fixed": "1.2"}, {"introduced": "2.0"}, {"fixed": "2.3"}}, "2.1", []string{">=1.0, <1.2", ">=2.0, <2.3"}, []string{"1.2", "2.3"}, true},
+	}
+	for _, tt := range tests {
+		ranges, fixed, affected := evaluateOsvRange(tt.events, tt.version)
+		if !reflect.DeepEqual(ranges, tt.ranges) || !reflect.DeepEqual(fixed, tt.fixed) || affected != tt.affected {
+			t.Errorf("%s: evaluateOsvRange(%s) = %v, %v, %v, want %v, %v, %v", tt.name, tt.version, ranges, fixed, affected, tt.ranges, tt.fixed, tt.affected)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package com.example.web;
+
+import java.util.List;
+
+public class UserController {
+   private final UserService service;
+
+   public UserController(UserService service) {
+      this.service = service;
+   }
+
+   public String find(String name) {
+      if (name == null) {
+         return "";
+      } else {
+         List users = this.service.search(name);
+         return users.isEmpty() ? "none" : (String)users.get(0);
+      }
+   }
+}
+// 14 8 15 9 16 10 19 13 20 14 22 16 23 17
@@ -0,0 +1,20 @@
+package com.example.web;
+
+import java.util.List;
+
+public class UserController
+{
+    private final UserService service;
+    
+    public UserController(final UserService service) {
+        /*SL:15*/this.service = service;
+    }
+    
+    public String find(final String name) {
+        /*SL:19*/if (name == null) {
+            /*SL:20*/return "";
+        }
+        final List<String> users = /*EL:22*/this.service.search(name);
+        /*SL:23*/return users.isEmpty() ? "none" : users.get(0);
+    }
+}
@@ -2,6 +2,7 @@ package Install
 
 import (
 	"codeql_n1ght/Common"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -20,7 +21,7 @@ func CheckAntInstalled() bool {
 }
 
 // DownloadAnt 下载并安装Apache Ant到tools目录
-func DownloadAnt() error {
+func DownloadAnt(ctx context.Context) error {
 	if CheckAntInstalled() {
 		return nil
 	}
@@ -45,7 +46,7 @@ func DownloadAnt() error {
 
 	fileName := "apache-ant-1.10.14-bin.zip"
 	filePath := filepath.Join(toolsDir, fileName)
-	if err := Common.DownloadFile(downloadURL, filePath); err != nil {
+	if err := Common.DownloadFile(ctx, downloadURL, filePath); err != nil {
 		return fmt.Errorf("下载Apache Ant失败: %v", err)
 	}
 
@@ -65,38 +66,33 @@ func DownloadAnt() error {
 	return nil
 }
 
-// InstallAllTools 安装所有工具的便捷函数
-func InstallAllTools() error {
+// InstallAllTools 安装所有工具的便捷函数，ctx取消时中止正在进行的下载并跳过后续工具
+func InstallAllTools(ctx context.Context) error {
 	fmt.Println("=== 开始安装开发工具 ===")
 
-	// 检查并安装JDK8
-	fmt.Println("\n1. 检查JDK8...")
-	if err := DownloadJDK(); err != nil {
-		fmt.Printf("JDK安装失败: %v\n", err)
+	steps := []struct {
+		title   string
+		name    string
+		install func(context.Context) error
+	}{
+		{"\n1. 检查JDK8...", "JDK", DownloadJDK},
+		{"\n2. 检查CodeQL...", "CodeQL", DownloadCodeQL},
+		{"\n3. 检查Apache Ant...", "Apache Ant", DownloadAnt},
+		{"\n4. 检查Procyon...", "Procyon", DownloadProcyon},
+		// CFR和Vineflower只在被-decompiler选中时安装
+		{"", Common.DecompilerType, func(ctx context.Context) error { return DownloadDecompiler(ctx, Common.DecompilerType) }},
+		{"\n5. 检查Apache Tomcat...", "Apache Tomcat", DownloadTomcat},
 	}
-
-	// 检查并安装CodeQL
-	fmt.Println("\n2. 检查CodeQL...")
-	if err := DownloadCodeQL(); err != nil {
-		fmt.Printf("CodeQL安装失败: %v\n", err)
-	}
-
-	// 检查并安装Apache Ant
-	fmt.Println("\n3. 检查Apache Ant...")
-	if err := DownloadAnt(); err != nil {
-		fmt.Printf("Apache Ant安装失败: %v\n", err)
-	}
-
-	// 检查并安装Procyon
-	fmt.Println("\n4. 检查Procyon...")
-	if err := DownloadProcyon(); err != nil {
-		fmt.Printf("Procyon安装失败: %v\n", err)
-	}
-
-	// 检查并安装Apache Tomcat
-	fmt.Println("\n5. 检查Apache Tomcat...")
-	if err := DownloadTomcat(); err != nil {
-		fmt.Printf("Apache Tomcat安装失败: %v\n", err)
+	for _, step := range steps {
+		if step.title != "" {
+			fmt.Println(step.title)
+		}
+		if err := step.install(ctx); err != nil {
+			fmt.Printf("%s安装失败: %v\n", step.name, err)
+		}
+		if Common.Interrupted(ctx) {
+			return context.Cause(ctx)
+		}
 	}
 
 	fmt.Println("\n=== 工具安装检查完成 ===")
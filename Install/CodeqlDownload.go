@@ -2,6 +2,7 @@ package Install
 
 import (
 	"codeql_n1ght/Common"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -21,7 +22,7 @@ func CheckCodeQLInstalled() bool {
 }
 
 // DownloadCodeQL 下载并安装CodeQL到tools目录
-func DownloadCodeQL() error {
+func DownloadCodeQL(ctx context.Context) error {
 	if CheckCodeQLInstalled() {
 		return nil
 	}
@@ -65,7 +66,7 @@ func DownloadCodeQL() error {
 
 	// 下载文件
 	filePath := filepath.Join(toolsDir, fileName)
-	if err := Common.DownloadFile(downloadURL, filePath); err != nil {
+	if err := Common.DownloadFile(ctx, downloadURL, filePath); err != nil {
 		return fmt.Errorf("下载CodeQL失败: %v", err)
 	}
 
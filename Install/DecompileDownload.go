@@ -2,9 +2,11 @@ package Install
 
 import (
 	"codeql_n1ght/Common"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // CheckDecompileInstalled 检查反编译器是否已安装在tools目录下
@@ -37,7 +39,7 @@ func CheckDecompileInstalled() bool {
 }
 
 // DownloadDecompilers 下载反编译器到tools目录
-func DownloadDecompilers() error {
+func DownloadDecompilers(ctx context.Context) error {
 	if CheckDecompileInstalled() {
 		return nil
 	}
@@ -53,7 +55,7 @@ func DownloadDecompilers() error {
 	if _, err := os.Stat(procyonPath); os.IsNotExist(err) {
 		fmt.Println("开始下载procyon-decompiler-0.6.0.jar...")
 		procyonURL := "https://raw.githubusercontent.com/yezere/codeql_n1ght_dp/refs/heads/main/procyon-decompiler-0.6.0.jar"
-		if err := Common.DownloadFile(procyonURL, procyonPath); err != nil {
+		if err := Common.DownloadFile(ctx, procyonURL, procyonPath); err != nil {
 			return fmt.Errorf("下载procyon-decompiler-0.6.0.jar失败: %v", err)
 		}
 		fmt.Printf("procyon-decompiler-0.6.0.jar下载完成: %s\n", procyonPath)
@@ -64,7 +66,7 @@ func DownloadDecompilers() error {
 	if _, err := os.Stat(fernflowerPath); os.IsNotExist(err) {
 		fmt.Println("开始下载java-decompiler.jar...")
 		fernflowerURL := "https://raw.githubusercontent.com/yezere/codeql_n1ght_dp/refs/heads/main/java-decompiler.jar"
-		if err := Common.DownloadFile(fernflowerURL, fernflowerPath); err != nil {
+		if err := Common.DownloadFile(ctx, fernflowerURL, fernflowerPath); err != nil {
 			return fmt.Errorf("下载java-decompiler.jar失败: %v", err)
 		}
 		fmt.Printf("java-decompiler.jar下载完成: %s\n", fernflowerPath)
@@ -75,7 +77,7 @@ func DownloadDecompilers() error {
 	if _, err := os.Stat(jsp2classPath); os.IsNotExist(err) {
 		fmt.Println("开始下载jsp2class.jar...")
 		jsp2classURL := "https://raw.githubusercontent.com/yezere/codeql_n1ght_dp/refs/heads/main/jsp2class.jar"
-		if err := Common.DownloadFile(jsp2classURL, jsp2classPath); err != nil {
+		if err := Common.DownloadFile(ctx, jsp2classURL, jsp2classPath); err != nil {
 			return fmt.Errorf("下载jsp2class.jar失败: %v", err)
 		}
 		fmt.Printf("jsp2class.jar下载完成: %s\n", jsp2classPath)
@@ -84,7 +86,50 @@ func DownloadDecompilers() error {
 	return nil
 }
 
+// downloadToolJar 下载单个反编译器jar到tools目录，已存在时跳过
+func downloadToolJar(ctx context.Context, fileName, url string) error {
+	toolsDir := "./tools"
+	if err := os.MkdirAll(toolsDir, 0755); err != nil {
+		return fmt.Errorf("创建tools目录失败: %v", err)
+	}
+
+	jarPath := filepath.Join(toolsDir, fileName)
+	if _, err := os.Stat(jarPath); err == nil {
+		fmt.Printf("%s 已经安装在 ./tools 目录下\n", fileName)
+		return nil
+	}
+
+	fmt.Printf("开始下载%s...\n", fileName)
+	if err := Common.DownloadFile(ctx, url, jarPath); err != nil {
+		return fmt.Errorf("下载%s失败: %v", fileName, err)
+	}
+	fmt.Printf("%s下载完成: %s\n", fileName, jarPath)
+	return nil
+}
+
+// DownloadCFR 下载CFR反编译器
+func DownloadCFR(ctx context.Context) error {
+	return downloadToolJar(ctx, "cfr-0.152.jar", "https://repo1.maven.org/maven2/org/benf/cfr/0.152/cfr-0.152.jar")
+}
+
+// DownloadVineflower 下载Vineflower反编译器（运行需要Java 11及以上）
+func DownloadVineflower(ctx context.Context) error {
+	return downloadToolJar(ctx, "vineflower-1.10.1.jar", "https://repo1.maven.org/maven2/org/vineflower/vineflower/1.10.1/vineflower-1.10.1.jar")
+}
+
+// DownloadDecompiler 确保-decompiler指定的反编译器已安装，CFR和Vineflower按需下载
+func DownloadDecompiler(ctx context.Context, name string) error {
+	switch strings.ToLower(name) {
+	case "cfr":
+		return DownloadCFR(ctx)
+	case "vineflower":
+		return DownloadVineflower(ctx)
+	default:
+		return DownloadDecompilers(ctx)
+	}
+}
+
 // DownloadProcyon 保持向后兼容性
-func DownloadProcyon() error {
-	return DownloadDecompilers()
+func DownloadProcyon(ctx context.Context) error {
+	return DownloadDecompilers(ctx)
 }
@@ -2,6 +2,7 @@ package Install
 
 import (
 	"codeql_n1ght/Common"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -21,7 +22,7 @@ func CheckJDKInstalled() bool {
 }
 
 // DownloadJDK 下载并安装JDK8到tools目录
-func DownloadJDK() error {
+func DownloadJDK(ctx context.Context) error {
 	if CheckJDKInstalled() {
 		return nil
 	}
@@ -69,7 +70,7 @@ func DownloadJDK() error {
 
 	// 下载文件
 	filePath := filepath.Join(toolsDir, fileName)
-	if err := Common.DownloadFile(downloadURL, filePath); err != nil {
+	if err := Common.DownloadFile(ctx, downloadURL, filePath); err != nil {
 		return fmt.Errorf("下载JDK失败: %v", err)
 	}
 
@@ -2,6 +2,7 @@ package Install
 
 import (
 	"codeql_n1ght/Common"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -21,7 +22,7 @@ func CheckTomcatInstalled() bool {
 }
 
 // DownloadTomcat 下载并安装Apache Tomcat到tools目录
-func DownloadTomcat() error {
+func DownloadTomcat(ctx context.Context) error {
 	if CheckTomcatInstalled() {
 		return nil
 	}
@@ -48,7 +49,7 @@ func DownloadTomcat() error {
 
 	// 下载文件
 	filePath := filepath.Join(toolsDir, fileName)
-	if err := Common.DownloadFile(downloadURL, filePath); err != nil {
+	if err := Common.DownloadFile(ctx, downloadURL, filePath); err != nil {
 		return fmt.Errorf("下载Apache Tomcat失败: %v", err)
 	}
 
@@ -74,9 +75,9 @@ func DownloadTomcat() error {
 }
 
 // InstallTomcat 安装Apache Tomcat的便捷函数
-func InstallTomcat() error {
+func InstallTomcat(ctx context.Context) error {
 	fmt.Println("=== 安装Apache Tomcat ===")
-	return DownloadTomcat()
+	return DownloadTomcat(ctx)
 }
 
 // CheckTomcatAvailability 检查Tomcat可用性
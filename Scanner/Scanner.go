@@ -2,9 +2,9 @@ package Scanner
 
 import (
 	"codeql_n1ght/Common"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -24,7 +24,8 @@ type ScanResult struct {
 }
 
 // RunScan 执行CodeQL扫描
-func RunScan() error {
+func RunScan(ctx context.Context) error {
+	started := time.Now()
 	// 显示带边框的扫描开始提示
 	displayScanHeader()
 
@@ -41,6 +42,7 @@ func RunScan() error {
 	// 显示扫描配置信息
 	Common.LogInfo("数据库路径: %s", Common.DatabasePath)
 	Common.LogInfo("QL库路径: %s", Common.QLLibsPath)
+	dbInfo := loadDatabaseInfo()
 
 	// 验证扫描相关目录
 	if err := validateScanDirectory(); err != nil {
@@ -63,9 +65,29 @@ func RunScan() error {
 	// 执行查询
 	results := make([]ScanResult, 0, len(qlFiles))
 	if Common.UseGoroutine {
-		results = executeConcurrentQueries(qlFiles)
+		results = executeConcurrentQueries(ctx, qlFiles)
 	} else {
-		results = executeSequentialQueries(qlFiles)
+		results = executeSequentialQueries(ctx, qlFiles)
+	}
+	if Common.Interrupted(ctx) {
+		writeScanSnapshot(ctx, "results.sarif", qlFiles, results, started)
+		return context.Cause(ctx)
+	}
+	// 之前中断留下的快照已经没有意义
+	os.Remove(Common.InterruptedStatePath("results.sarif"))
+
+	// 将反编译源码中的位置映射回原始jar、类和行号
+	if err := annotateFindingOrigins("results.sarif"); err != nil {
+		Common.LogWarn("映射结果原始位置失败: %v", err)
+	}
+	if err := annotateDatabaseInfo("results.sarif", dbInfo); err != nil {
+		Common.LogWarn("写入建库清单到SARIF失败: %v", err)
+	}
+	// 只保留涉及新增、变化代码的结果
+	if Common.ChangedOnlyDiff != "" {
+		if err := filterChangedFindings("results.sarif", Common.ChangedOnlyDiff, dbInfo); err != nil {
+			Common.LogWarn("按对比报告过滤结果失败: %v", err)
+		}
 	}
 
 	// 显示扫描总结
@@ -131,7 +153,7 @@ func findQLFiles() ([]string, error) {
 }
 
 // executeConcurrentQueries 并发执行查询
-func executeConcurrentQueries(qlFiles []string) []ScanResult {
+func executeConcurrentQueries(ctx context.Context, qlFiles []string) []ScanResult {
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, Common.MaxGoroutines)
 	results := make(chan ScanResult, len(qlFiles))
@@ -139,9 +161,14 @@ func executeConcurrentQueries(qlFiles []string) []ScanResult {
 	Common.LogInfo("使用并发模式执行查询 (最大并发数: %d)", Common.MaxGoroutines)
 
 	for _, qlFile := range qlFiles {
-		wg.Add(1)
 		semaphore <- struct{}{} // 获取信号量
-		go executeQuery(qlFile, &wg, semaphore, results)
+		if Common.Interrupted(ctx) {
+			// 中断后不再启动剩余的查询
+			<-semaphore
+			break
+		}
+		wg.Add(1)
+		go executeQuery(ctx, qlFile, &wg, semaphore, results)
 	}
 
 	// 等待所有查询完成
@@ -158,12 +185,15 @@ func executeConcurrentQueries(qlFiles []string) []ScanResult {
 }
 
 // executeSequentialQueries 顺序执行查询
-func executeSequentialQueries(qlFiles []string) []ScanResult {
+func executeSequentialQueries(ctx context.Context, qlFiles []string) []ScanResult {
 	var results []ScanResult
 
 	Common.LogInfo("使用顺序模式执行查询")
 
 	for _, qlFile := range qlFiles {
+		if Common.Interrupted(ctx) {
+			break
+		}
 		startTime := time.Now()
 		result := ScanResult{
 			QueryFile: qlFile,
@@ -174,10 +204,11 @@ func executeSequentialQueries(qlFiles []string) []ScanResult {
 		Common.SetupEnvironment()
 
 		// 构建CodeQL命令
-		cmd := exec.Command("codeql", "database", "analyze",
+		cmd := Common.WatchCommand(ctx, Common.QueryTimeout, "codeql", "database", "analyze",
 			Common.DatabasePath, // 数据库路径
 			qlFile,              // 查询文件
-			fmt.Sprintf("--threads=%d", Common.CodeQLThreads),
+			fmt.Sprintf("--threads=%d", Common.QueryThreads()),
+			fmt.Sprintf("--ram=%d", Common.QueryRAM()),
 			"--format=sarifv2.1.0",
 			"--output=results.sarif",
 		)
@@ -187,13 +218,20 @@ func executeSequentialQueries(qlFiles []string) []ScanResult {
 		result.Duration = time.Since(startTime)
 		result.Output = string(output)
 
+		if Common.Interrupted(ctx) {
+			result.Error = context.Cause(ctx)
+			results = append(results, result)
+			break
+		}
 		if err != nil {
 			result.Error = err
 			Common.LogError("执行查询 %s 失败 (耗时: %v): %v", filepath.Base(qlFile), result.Duration, err)
 			if len(result.Output) > 0 {
 				Common.LogError("错误输出: %s", result.Output)
 			}
-			showPackInstallHint()
+			if !Common.IsTimeout(err) {
+				showPackInstallHint()
+			}
 		} else {
 			result.Success = true
 			Common.LogInfo("查询 %s 完成 (耗时: %v)", filepath.Base(qlFile), result.Duration)
@@ -209,7 +247,7 @@ func executeSequentialQueries(qlFiles []string) []ScanResult {
 }
 
 // executeQuery 执行单个查询
-func executeQuery(qlFile string, wg *sync.WaitGroup, semaphore chan struct{}, results chan<- ScanResult) {
+func executeQuery(ctx context.Context, qlFile string, wg *sync.WaitGroup, semaphore chan struct{}, results chan<- ScanResult) {
 	defer wg.Done()
 	defer func() { <-semaphore }() // 释放信号量
 
@@ -222,10 +260,11 @@ func executeQuery(qlFile string, wg *sync.WaitGroup, semaphore chan struct{}, re
 	Common.LogInfo("正在执行查询: %s", filepath.Base(qlFile))
 	Common.SetupEnvironment()
 	// 构建CodeQL命令
-	cmd := exec.Command("codeql", "database", "analyze",
+	cmd := Common.WatchCommand(ctx, Common.QueryTimeout, "codeql", "database", "analyze",
 		Common.DatabasePath, // 数据库路径
 		qlFile,              // 查询文件
-		fmt.Sprintf("--threads=%d", Common.CodeQLThreads),
+		fmt.Sprintf("--threads=%d", Common.QueryThreads()),
+		fmt.Sprintf("--ram=%d", Common.QueryRAM()),
 		"--format=sarifv2.1.0",
 		"--output=results.sarif",
 	)
@@ -235,13 +274,21 @@ func executeQuery(qlFile string, wg *sync.WaitGroup, semaphore chan struct{}, re
 	result.Duration = time.Since(startTime)
 	result.Output = string(output)
 
+	if Common.Interrupted(ctx) {
+		// 被中断的查询不算失败，不打印错误输出
+		result.Error = context.Cause(ctx)
+		results <- result
+		return
+	}
 	if err != nil {
 		result.Error = err
 		Common.LogError("执行查询 %s 失败 (耗时: %v): %v", filepath.Base(qlFile), result.Duration, err)
 		if len(result.Output) > 0 {
 			Common.LogError("错误输出: %s", result.Output)
 		}
-		showPackInstallHint()
+		if !Common.IsTimeout(err) {
+			showPackInstallHint()
+		}
 	} else {
 		result.Success = true
 		Common.LogInfo("查询 %s 完成 (耗时: %v)", filepath.Base(qlFile), result.Duration)
@@ -256,11 +303,14 @@ func executeQuery(qlFile string, wg *sync.WaitGroup, semaphore chan struct{}, re
 // displayScanSummary 显示扫描总结
 func displayScanSummary(results []ScanResult) {
 	successCount := 0
+	timeoutCount := 0
 	totalDuration := time.Duration(0)
 
 	for _, result := range results {
 		if result.Success {
 			successCount++
+		} else if Common.IsTimeout(result.Error) {
+			timeoutCount++
 		}
 		totalDuration += result.Duration
 	}
@@ -270,6 +320,9 @@ func displayScanSummary(results []ScanResult) {
 	Common.LogInfo("总查询数: %d", len(results))
 	color.Green("成功: %d", successCount)
 	color.Red("失败: %d", len(results)-successCount)
+	if timeoutCount > 0 {
+		color.Yellow("其中超时或卡死: %d", timeoutCount)
+	}
 	Common.LogInfo("总耗时: %v", totalDuration)
 	fmt.Println(strings.Repeat("=", 60))
 }
@@ -0,0 +1,112 @@
+package Scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"codeql_n1ght/Common"
+)
+
+// changedOnlyPropertyKey 写入SARIF run properties中的过滤说明字段
+const changedOnlyPropertyKey = "n1ght/changedOnly"
+
+// filterChangedFindings 只保留位置、相关位置或数据流步骤落在新增、变化代码上的结果，源码位置按n1ght-origins.json映射回原始类和行号后与对比报告比较
+func filterChangedFindings(sarifPath, reportPath string, info *Common.BuildInfo) error {
+	diff, err := Common.LoadArtifactDiff(reportPath)
+	if err != nil {
+		return err
+	}
+	if info != nil && info.Artifact != nil && diff.NewSHA256 != "" && info.Artifact.SHA256 != diff.NewSHA256 {
+		Common.LogWarn("数据库的制品（%s）不是对比报告中的新版本（%s），过滤结果可能不准确", info.Artifact.Name, diff.New)
+	}
+	origins, err := Common.LoadOrigins(Common.DatabasePath)
+	if err != nil {
+		return err
+	}
+	if len(origins) == 0 {
+		return fmt.Errorf("数据库中没有%s，无法判断结果是否涉及变化的代码", Common.OriginsFileName)
+	}
+
+	data, err := os.ReadFile(sarifPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var sarif map[string]interface{}
+	if err := json.Unmarshal(data, &sarif); err != nil {
+		return fmt.Errorf("解析SARIF失败: %v", err)
+	}
+
+	touches := func(loc interface{}) bool {
+		physical := jsonObject(jsonObject(loc)["physicalLocation"])
+		uri, _ := jsonObject(physical["artifactLocation"])["uri"].(string)
+		line, _ := jsonObject(physical["region"])["startLine"].(float64)
+		origin, ok := origins[uri]
+		return ok && diff.Touches(origin, origin.OriginalLine(int(line)))
+	}
+
+	kept, dropped := 0, 0
+	for _, run := range jsonArray(sarif["runs"]) {
+		runObject := jsonObject(run)
+		if runObject == nil {
+			continue
+		}
+		results := []interface{}{}
+		all := jsonArray(runObject["results"])
+		for _, result := range all {
+			if resultTouches(jsonObject(result), touches) {
+				results = append(results, result)
+			}
+		}
+		runObject["results"] = results
+		kept += len(results)
+		dropped += len(all) - len(results)
+
+		properties := jsonObject(runObject["properties"])
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties[changedOnlyPropertyKey] = map[string]interface{}{
+			"report":  reportPath,
+			"old":     diff.Old,
+			"new":     diff.New,
+			"summary": diff.Summary,
+			"dropped": len(all) - len(results),
+		}
+		runObject["properties"] = properties
+	}
+
+	output, err := json.MarshalIndent(sarif, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(sarifPath, output, 0644); err != nil {
+		return err
+	}
+	Common.LogInfo("只报告涉及变化代码的结果: 保留 %d 个，过滤 %d 个（对比 %s -> %s）", kept, dropped, diff.Old, diff.New)
+	return nil
+}
+
+// resultTouches 判断结果的位置、相关位置和数据流中的任一步骤是否涉及变化的代码
+func resultTouches(result map[string]interface{}, touches func(loc interface{}) bool) bool {
+	for _, key := range []string{"locations", "relatedLocations"} {
+		for _, loc := range jsonArray(result[key]) {
+			if touches(loc) {
+				return true
+			}
+		}
+	}
+	for _, flow := range jsonArray(result["codeFlows"]) {
+		for _, thread := range jsonArray(jsonObject(flow)["threadFlows"]) {
+			for _, step := range jsonArray(jsonObject(thread)["locations"]) {
+				if touches(jsonObject(step)["location"]) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
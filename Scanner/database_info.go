@@ -0,0 +1,88 @@
+package Scanner
+
+import (
+	"encoding/json"
+	"os"
+
+	"codeql_n1ght/Common"
+)
+
+// databasePropertyKey 写入SARIF run properties中的建库清单字段
+const databasePropertyKey = "n1ght/database"
+
+// loadDatabaseInfo 读取数据库的建库清单并打印扫描对应的制品、工具版本和编译覆盖率，没有清单时返回nil
+func loadDatabaseInfo() *Common.BuildInfo {
+	info, err := Common.LoadBuildInfo(Common.DatabasePath)
+	if err != nil {
+		Common.LogWarn("读取%s失败: %v", Common.MetadataFileName, err)
+		return nil
+	}
+	if info == nil || info.Artifact == nil {
+		return nil
+	}
+
+	Common.LogInfo("数据库制品: %s (sha256 %s)", info.Artifact.Name, info.Artifact.SHA256)
+	Common.LogInfo("建库时间: %s", info.CreatedAt.Format("2006-01-02 15:04:05"))
+	if version, ok := info.ToolVersions["CodeQL"]; ok {
+		Common.LogInfo("建库时CodeQL版本: %s", version)
+	}
+	if len(info.Decompiled) > 0 {
+		counts := make(map[string]int)
+		for _, d := range info.Decompiled {
+			counts[d.Decompiler+" "+d.Version]++
+		}
+		for decompiler, count := range counts {
+			Common.LogInfo("反编译器: %s（%d 个输入）", decompiler, count)
+		}
+	}
+	if info.Coverage != nil {
+		Common.LogInfo("编译覆盖率: %d/%d（%.1f%%）", info.Coverage.CompiledFiles, info.Coverage.SourceFiles, info.Coverage.Percent)
+	}
+	return info
+}
+
+// annotateDatabaseInfo 将建库清单写入SARIF每个run的properties，报告可以说明结果对应的数据库
+func annotateDatabaseInfo(sarifPath string, info *Common.BuildInfo) error {
+	if info == nil {
+		return nil
+	}
+	data, err := os.ReadFile(sarifPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var sarif map[string]interface{}
+	if err := json.Unmarshal(data, &sarif); err != nil {
+		return err
+	}
+
+	// 配置项较多，只写入制品、时间、工具版本、反编译器和覆盖率
+	summary := map[string]interface{}{
+		"metadata":     Common.MetadataFileName,
+		"artifact":     info.Artifact,
+		"createdAt":    info.CreatedAt,
+		"toolVersions": info.ToolVersions,
+		"decompiled":   info.Decompiled,
+		"coverage":     info.Coverage,
+	}
+	for _, run := range jsonArray(sarif["runs"]) {
+		runObject := jsonObject(run)
+		if runObject == nil {
+			continue
+		}
+		properties := jsonObject(runObject["properties"])
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties[databasePropertyKey] = summary
+		runObject["properties"] = properties
+	}
+
+	output, err := json.MarshalIndent(sarif, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sarifPath, output, 0644)
+}
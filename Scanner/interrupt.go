@@ -0,0 +1,41 @@
+package Scanner
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"codeql_n1ght/Common"
+)
+
+// writeScanSnapshot 扫描被中断时把已完成和未完成的查询写入 results.sarif.interrupted.json，方便只重跑剩余的查询
+func writeScanSnapshot(ctx context.Context, sarifPath string, qlFiles []string, results []ScanResult, started time.Time) {
+	snapshot := &Common.StageSnapshot{
+		Operation:     "scan",
+		Target:        Common.DatabasePath,
+		Output:        sarifPath,
+		Stage:         "query",
+		Reason:        Common.InterruptReason(ctx),
+		StartedAt:     started,
+		InterruptedAt: time.Now(),
+	}
+	finished := make(map[string]bool, len(results))
+	for _, result := range results {
+		if result.Success {
+			finished[result.QueryFile] = true
+			snapshot.Finished = append(snapshot.Finished, result.QueryFile)
+		}
+	}
+	for _, qlFile := range qlFiles {
+		if !finished[qlFile] {
+			snapshot.Pending = append(snapshot.Pending, qlFile)
+		}
+	}
+
+	statePath := Common.InterruptedStatePath(sarifPath)
+	if err := Common.WriteStageSnapshot(statePath, snapshot); err != nil {
+		Common.LogWarn("写入中断快照失败: %v", err)
+		return
+	}
+	Common.LogWarn("扫描被中断: 已完成 %d 个查询，剩余 %d 个，快照: %s", len(snapshot.Finished), len(snapshot.Pending), filepath.Clean(statePath))
+}
@@ -0,0 +1,92 @@
+package Scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"codeql_n1ght/Common"
+)
+
+// originPropertyKey 写入SARIF位置properties中的原始位置字段
+const originPropertyKey = "n1ght/origin"
+
+// annotateFindingOrigins 根据数据库中的n1ght-origins.json，为SARIF结果补充原始jar、class和行号，并打印两种位置
+func annotateFindingOrigins(sarifPath string) error {
+	origins, err := Common.LoadOrigins(Common.DatabasePath)
+	if err != nil || len(origins) == 0 {
+		return err
+	}
+
+	data, err := os.ReadFile(sarifPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var sarif map[string]interface{}
+	if err := json.Unmarshal(data, &sarif); err != nil {
+		return fmt.Errorf("解析SARIF失败: %v", err)
+	}
+
+	mapped := 0
+	for _, run := range jsonArray(sarif["runs"]) {
+		for _, result := range jsonArray(jsonObject(run)["results"]) {
+			for _, loc := range jsonArray(jsonObject(result)["locations"]) {
+				location := jsonObject(loc)
+				physical := jsonObject(location["physicalLocation"])
+				uri, _ := jsonObject(physical["artifactLocation"])["uri"].(string)
+				line, _ := jsonObject(physical["region"])["startLine"].(float64)
+
+				origin, ok := origins[uri]
+				if !ok {
+					continue
+				}
+				properties := jsonObject(location["properties"])
+				if properties == nil {
+					properties = make(map[string]interface{})
+				}
+				file, original := origin.OriginalPosition(int(line))
+				property := map[string]interface{}{
+					"artifact": origin.Artifact,
+					"class":    file,
+					"location": origin.Location(int(line)),
+				}
+				if original > 0 {
+					property["originalLine"] = original
+				}
+				properties[originPropertyKey] = property
+				location["properties"] = properties
+
+				fmt.Printf("  %s:%d -> %s\n", uri, int(line), origin.Location(int(line)))
+				mapped++
+			}
+		}
+	}
+	if mapped == 0 {
+		return nil
+	}
+
+	output, err := json.MarshalIndent(sarif, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(sarifPath, output, 0644); err != nil {
+		return err
+	}
+	Common.LogInfo("已将 %d 个结果位置映射回原始jar和类", mapped)
+	return nil
+}
+
+// jsonObject 将JSON值转换为对象，类型不符时返回nil
+func jsonObject(value interface{}) map[string]interface{} {
+	object, _ := value.(map[string]interface{})
+	return object
+}
+
+// jsonArray 将JSON值转换为数组，类型不符时返回nil
+func jsonArray(value interface{}) []interface{} {
+	array, _ := value.([]interface{})
+	return array
+}
@@ -5,8 +5,10 @@ import (
 	"codeql_n1ght/Database"
 	"codeql_n1ght/Install"
 	"codeql_n1ght/Scanner"
+	"context"
 	"fmt"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -22,8 +24,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Ctrl-C或SIGTERM时终止子进程树、写入中断快照并清理临时目录
+	ctx, stop := Common.SignalContext()
+	defer stop()
+
 	// 执行相应的功能
-	if err := executeCommand(); err != nil {
+	if err := executeCommand(ctx); err != nil {
+		if Common.Interrupted(ctx) {
+			Common.LogWarn("已中断: %s", Common.InterruptReason(ctx))
+			os.Exit(130)
+		}
 		Common.LogError("执行失败: %v", err)
 		os.Exit(1)
 	}
@@ -33,9 +43,14 @@ func main() {
 
 // validateArguments 验证命令行参数
 func validateArguments() error {
+	// 子命令只校验自己的参数
+	if Common.Command != "" {
+		return validateCommand()
+	}
+
 	// 检查是否指定了操作
-	if !Common.IsInstall && Common.CreateJar == "" && !Common.ScanMode {
-		return fmt.Errorf("请指定要执行的操作: -install, -database 或 -scan")
+	if !Common.IsInstall && Common.CreateJar == "" && !Common.ScanMode && Common.BatchSource == "" {
+		return fmt.Errorf("请指定要执行的操作: -install, -database, -batch 或 -scan")
 	}
 
 	// 验证下载URL参数只能在install模式下使用
@@ -52,10 +67,48 @@ func validateArguments() error {
 	}
 
 	// 验证额外源码目录参数只能在database模式下使用
-	if Common.ExtraSourceDir != "" && Common.CreateJar == "" {
+	if Common.ExtraSourceDir != "" && Common.CreateJar == "" && Common.BatchSource == "" {
 		return fmt.Errorf("-dir 参数只能在 -database 模式下使用")
 	}
 
+	// 验证输出路径参数只能在database模式下使用
+	if Common.DatabaseOutPath != "" && Common.CreateJar == "" {
+		return fmt.Errorf("-out 参数只能在 -database 模式下使用，批量模式请使用 -batch-out")
+	}
+
+	// 验证增量重建参数
+	if Common.IncrementalBase != "" {
+		if Common.CreateJar == "" {
+			return fmt.Errorf("-incremental 参数只能在 -database 模式下使用")
+		}
+		if !Common.IsCodeQLDatabase(Common.IncrementalBase) {
+			return fmt.Errorf("-incremental 指定的不是有效的CodeQL数据库: %s", Common.IncrementalBase)
+		}
+	}
+
+	// 验证只报告变化代码的参数只能在scan模式下使用
+	if Common.ChangedOnlyDiff != "" && !Common.ScanMode {
+		return fmt.Errorf("-changed-only 参数只能在 -scan 模式下使用")
+	}
+
+	// 验证批量模式参数
+	if Common.BatchSource != "" {
+		if !Common.FileExists(Common.BatchSource) {
+			return fmt.Errorf("指定的批量制品目录或清单不存在: %s", Common.BatchSource)
+		}
+		if Common.CreateJar != "" || Common.ScanMode || Common.IsInstall {
+			return fmt.Errorf("批量模式不能与 -database、-scan 或 -install 同时使用")
+		}
+		if Common.BatchWorkers <= 0 {
+			return fmt.Errorf("批量并发数必须大于0")
+		}
+		// 并发建库时无法进行交互选择
+		if Common.DependencySelection == "" {
+			Common.LogWarn("批量模式不支持交互式依赖选择，已按 -deps none 处理")
+			Common.DependencySelection = "none"
+		}
+	}
+
 	// 验证扫描模式参数
 	if Common.ScanMode {
 		// 向后兼容处理：如果使用了旧的-d参数，给出提示
@@ -77,6 +130,11 @@ func validateArguments() error {
 			return fmt.Errorf("指定的QL库路径不是有效目录: %s", Common.QLLibsPath)
 		}
 
+		// 验证对比报告
+		if Common.ChangedOnlyDiff != "" && !Common.FileExists(Common.ChangedOnlyDiff) {
+			return fmt.Errorf("指定的对比报告不存在: %s", Common.ChangedOnlyDiff)
+		}
+
 		// 扫描模式下不能同时使用install或database
 		if Common.IsInstall {
 			return fmt.Errorf("扫描模式不能与安装模式同时使用")
@@ -88,8 +146,11 @@ func validateArguments() error {
 
 	// 验证数据库模式参数
 	if Common.CreateJar != "" {
-		if err := Common.ValidateFile(Common.CreateJar); err != nil {
-			return fmt.Errorf("JAR文件验证失败: %v", err)
+		// 允许传入解压后的目录（webapp目录或class目录）
+		if !Common.IsDirectory(Common.CreateJar) {
+			if err := Common.ValidateFile(Common.CreateJar); err != nil {
+				return fmt.Errorf("JAR文件验证失败: %v", err)
+			}
 		}
 
 		// 验证额外源码目录
@@ -99,44 +160,107 @@ func validateArguments() error {
 			}
 		}
 
+		// 验证混淆映射文件
+		if Common.MappingFile != "" && !Common.FileExists(Common.MappingFile) {
+			return fmt.Errorf("指定的混淆映射文件不存在: %s", Common.MappingFile)
+		}
+
 		// 数据库模式下不能同时使用install
 		if Common.IsInstall {
 			return fmt.Errorf("数据库创建模式不能与安装模式同时使用")
 		}
 	}
 
+	// 验证依赖选择模式
+	switch strings.ToLower(Common.DependencySelection) {
+	case "", "none", "all", "rules", "first-party":
+	default:
+		return fmt.Errorf("不支持的依赖选择模式: %s（可选 none|all|rules|first-party）", Common.DependencySelection)
+	}
+
+	// 验证反编译器
+	if err := Database.ValidateDecompilerName(Common.DecompilerType); err != nil {
+		return err
+	}
+
+	// 验证重复类处理策略
+	if err := Database.ValidateDuplicatePolicy(Common.DuplicatePolicy); err != nil {
+		return err
+	}
+
 	// 验证并发参数
 	if Common.UseGoroutine && Common.MaxGoroutines <= 0 {
 		return fmt.Errorf("最大goroutine数量必须大于0")
 	}
 
-	// 验证线程数参数
-	if Common.CodeQLThreads <= 0 {
-		return fmt.Errorf("线程数必须大于0")
+	// 验证时间限制
+	if Common.DecompileTimeout < 0 || Common.QueryTimeout < 0 || Common.CreateTimeout < 0 || Common.HangTimeout < 0 {
+		return fmt.Errorf("-decompile-timeout、-query-timeout、-create-timeout 和 -hang-timeout 不能为负数")
+	}
+
+	// 验证资源参数，未指定的内存和线程数按cgroup和主机资源推导
+	if err := Common.ResolveResources(); err != nil {
+		return err
 	}
+	Common.LogInfo("资源设置: %s", Common.ResourceSummary())
 
 	return nil
 }
 
+// validateCommand 验证子命令的位置参数
+func validateCommand() error {
+	if Common.IsInstall || Common.CreateJar != "" || Common.ScanMode || Common.BatchSource != "" {
+		return fmt.Errorf("子命令 %s 不能与 -install、-database、-batch 或 -scan 同时使用", Common.Command)
+	}
+	if Common.Command == "diff" {
+		if len(Common.CommandArgs) != 2 {
+			return fmt.Errorf("diff 需要旧版本和新版本两个制品路径")
+		}
+	} else if len(Common.CommandArgs) != 1 {
+		return fmt.Errorf("%s 需要且只需要一个路径参数", Common.Command)
+	}
+	for _, arg := range Common.CommandArgs {
+		if !Common.FileExists(arg) {
+			return fmt.Errorf("指定的路径不存在: %s", arg)
+		}
+	}
+	if (Common.BundleArtifact || Common.BundleSources) && Common.Command != "db bundle" {
+		return fmt.Errorf("-bundle-artifact 和 -bundle-sources 只能与 db bundle 一起使用")
+	}
+	return nil
+}
+
 // executeCommand 执行相应的命令
-func executeCommand() error {
+func executeCommand(ctx context.Context) error {
+	// 子命令
+	if Common.Command != "" {
+		return runSubcommand(ctx)
+	}
+
 	// 安装工具
 	if Common.IsInstall {
-		if err := installTools(); err != nil {
+		if err := installTools(ctx); err != nil {
 			return err
 		}
 	}
 
 	// 创建数据库
 	if Common.CreateJar != "" {
-		if err := createDatabase(); err != nil {
+		if err := createDatabase(ctx); err != nil {
+			return err
+		}
+	}
+
+	// 批量创建数据库
+	if Common.BatchSource != "" {
+		if err := createBatchDatabases(ctx); err != nil {
 			return err
 		}
 	}
 
 	// 执行扫描
 	if Common.ScanMode {
-		if err := runScan(); err != nil {
+		if err := runScan(ctx); err != nil {
 			return err
 		}
 	}
@@ -145,12 +269,12 @@ func executeCommand() error {
 }
 
 // installTools 安装工具
-func installTools() error {
+func installTools(ctx context.Context) error {
 	return Common.SafeExecute(func() error {
 		Common.LogInfo("开始安装工具...")
 
 		// 安装必要的工具
-		if err := Install.InstallAllTools(); err != nil {
+		if err := Install.InstallAllTools(ctx); err != nil {
 			return err
 		}
 
@@ -168,23 +292,78 @@ func installTools() error {
 }
 
 // createDatabase 创建数据库
-func createDatabase() error {
+func createDatabase(ctx context.Context) error {
 	return Common.SafeExecute(func() error {
 		Common.LogInfo("开始创建数据库: %s", Common.CreateJar)
-		Database.Init(Common.CreateJar)
+		if err := ensureDecompiler(ctx); err != nil {
+			return err
+		}
+		if err := Database.Init(ctx, Common.CreateJar); err != nil {
+			return err
+		}
 		Common.LogInfo("数据库创建完成")
 		return nil
 	}, "数据库创建失败")
 }
 
+// createBatchDatabases 批量创建数据库
+func createBatchDatabases(ctx context.Context) error {
+	return Common.SafeExecute(func() error {
+		Common.LogInfo("开始批量创建数据库: %s", Common.BatchSource)
+		if err := ensureDecompiler(ctx); err != nil {
+			return err
+		}
+		results, err := Database.RunBatch(ctx, Common.BatchSource, Common.BatchOutDir)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if !result.Success {
+				return fmt.Errorf("部分制品建库失败")
+			}
+		}
+		Common.LogInfo("批量数据库创建完成")
+		return nil
+	}, "批量数据库创建失败")
+}
+
+// ensureDecompiler 按需安装-decompiler选择的CFR或Vineflower
+func ensureDecompiler(ctx context.Context) error {
+	switch strings.ToLower(Common.DecompilerType) {
+	case "cfr", "vineflower":
+		return Install.DownloadDecompiler(ctx, Common.DecompilerType)
+	}
+	return nil
+}
+
 // runScan 执行扫描
-func runScan() error {
+func runScan(ctx context.Context) error {
 	return Common.SafeExecute(func() error {
 		Common.LogInfo("开始扫描 - 数据库: %s, QL库: %s", Common.DatabasePath, Common.QLLibsPath)
-		if err := Scanner.RunScan(); err != nil {
+		if err := Scanner.RunScan(ctx); err != nil {
 			return err
 		}
 		Common.LogInfo("扫描完成")
 		return nil
 	}, "扫描执行失败")
-}
\ No newline at end of file
+}
+
+// runSubcommand 执行子命令
+func runSubcommand(ctx context.Context) error {
+	switch Common.Command {
+	case "db bundle":
+		return Common.SafeExecute(func() error {
+			return Database.BundleDatabase(ctx, Common.CommandArgs[0], Common.DatabaseOutPath, Common.BundleArtifact, Common.BundleSources)
+		}, "数据库打包失败")
+	case "db import":
+		return Common.SafeExecute(func() error {
+			_, err := Database.ImportBundle(ctx, Common.CommandArgs[0], Common.DatabaseOutPath)
+			return err
+		}, "数据库导入失败")
+	case "diff":
+		return Common.SafeExecute(func() error {
+			return Database.RunDiff(Common.CommandArgs[0], Common.CommandArgs[1], Common.DatabaseOutPath)
+		}, "制品对比失败")
+	}
+	return fmt.Errorf("未知子命令: %s", Common.Command)
+}